@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"log"
+	"os"
+	"time"
+)
+
+// Backtest reports the forecast error over the stored price history.
+func main() {
+	from := flag.String("from", "2000-01-01", "first day to forecast (YYYY-MM-DD)")
+	till := flag.String("till", time.Now().Format("2006-01-02"), "day to stop before (YYYY-MM-DD)")
+	flag.Parse()
+
+	cfg := &app.ConfigApp{}
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Fatal("Error loading .env file")
+		}
+	}
+	if err := envconfig.Process("", cfg); err != nil {
+		log.Fatalf("Error processing environment variables: %v", err)
+	}
+	if err := cfg.BacktestSelfCheck(); err != nil {
+		log.Fatalf("Error checking configuration: %v", err)
+	}
+
+	fromDay, err := time.ParseInLocation("2006-01-02", *from, cfg.Location())
+	if err != nil {
+		log.Fatalf("Invalid from value: %v", err)
+	}
+	tillDay, err := time.ParseInLocation("2006-01-02", *till, cfg.Location())
+	if err != nil {
+		log.Fatalf("Invalid till value: %v", err)
+	}
+
+	res, err := app.BacktestForecast(&cfg.Forecast, cfg.History(), fromDay, tillDay)
+	if err != nil {
+		log.Fatalf("Error running backtest: %v", err)
+	}
+	fmt.Printf("Days: %d\nMAE: %.5f\nRMSE: %.5f\nBand coverage: %.1f%%\n", res.Days, res.MAE, res.RMSE, res.Coverage*100)
+}
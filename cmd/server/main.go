@@ -1,27 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-echarts/go-echarts/v2/charts"
-	"github.com/go-echarts/go-echarts/v2/opts"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
 	"github.com/oitimon/day-ahead-prices-notificator/internal/controller"
 	appMiddleware "github.com/oitimon/day-ahead-prices-notificator/internal/middleware"
-	"github.com/shopspring/decimal"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
 	"log"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
-const chartHtmlFilename = "/tmp/epex_nl_da_prices_chart.html"
-
 func main() {
 	cfg := &app.ConfigApp{}
 	if _, err := os.Stat(".env"); err == nil {
@@ -46,6 +41,49 @@ func main() {
 	}
 	cfg.Analytics.Version = string(data)
 
+	// Start the P1 reader.
+	var p1Tracker *app.P1Tracker
+	if cfg.P1.Driver != "" {
+		p1Tracker = app.NewP1Tracker(cfg, func(day time.Time, status models.P1Status) {
+			if err := app.Deliver(cfg, "p1-"+day.Format("2006-01-02"), app.Message{
+				Class:    app.MessageClassSummary,
+				Document: app.TextDocument(app.P1DayText(day, status)),
+			}); err != nil {
+				log.Printf("Error sending P1 message: %v\n", err)
+			}
+		})
+		go app.RunP1Reader(context.Background(), &cfg.P1, cfg.Location(), p1Tracker.Update)
+	}
+
+	// Start the outbox worker delivering the notifications.
+	if cfg.Outbox.Enabled {
+		go cfg.Queue().Run(context.Background())
+	}
+
+	// Start the bot answering the commands.
+	var bot *app.Bot
+	if cfg.Messenger.Telegram.Updates != "" {
+		bot, err = app.NewBot(cfg)
+		if err != nil {
+			log.Fatalf("Error creating bot: %v", err)
+		}
+		go bot.Run(context.Background())
+	}
+
+	// Start the scheduler.
+	scheduler, err := createScheduler(cfg)
+	if err != nil {
+		log.Fatalf("Error creating scheduler: %v", err)
+	}
+	go scheduler.Run(context.Background())
+	if cfg.MQTT.Broker != "" {
+		go func() {
+			if err := app.PublishMQTT(cfg, time.Now()); err != nil {
+				log.Printf("Error publishing to MQTT: %v\n", err)
+			}
+		}()
+	}
+
 	// Start the server.
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -55,117 +93,76 @@ func main() {
 	r.Get("/", controller.IndexHandler)
 	r.Get("/api/v1/healthcheck", controller.HealthCheckHandler)
 	r.With(appMiddleware.DateMiddleware).Get("/day-prices/{year}-{month}-{day}", controller.DayPricesHandler)
+	r.Post("/cost-report", controller.CostReportHandler)
+	r.With(appMiddleware.P1Middleware(p1Tracker)).Get("/api/v1/p1", controller.P1Handler)
+	r.Get("/reports/{period}", controller.ReportsHandler)
+	// The webhook only queues the updates, the bot workers answer them out of the request timeout.
+	r.With(appMiddleware.BotMiddleware(bot)).Post("/telegram/webhook/{secret}", controller.TelegramWebhookHandler)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(appMiddleware.AdminMiddleware(cfg.Server.AdminToken))
+		r.Get("/outbox", controller.OutboxHandler)
+		r.Post("/outbox/{id}/retry", controller.OutboxRetryHandler)
+	})
 	log.Printf("Starting server on :%s\n", cfg.Server.Port)
 	if err := http.ListenAndServe(":"+cfg.Server.Port, r); err != nil {
 		log.Fatal(err)
 	}
 }
 
-// createChart function generates a bar chart of prices
-func createChart(prices []decimal.Decimal, day time.Time) (err error) {
-	// Saving the chart image as HTML file
-	log.Printf("Generating charts for: %s\n", day.Format("2006-01-02"))
-
-	bar := charts.NewBar()
-	xAxis := make([]string, len(prices))
-	for i := 0; i < len(prices); i++ {
-		xAxis[i] = fmt.Sprintf("%02d:00", i)
-	}
-	yAxis := make([]opts.BarData, len(prices))
-	for i, price := range prices {
-		yAxis[i] = opts.BarData{Value: price}
-	}
-
-	bar.SetXAxis(xAxis).
-		AddSeries(
-			"", yAxis,
-			charts.WithAnimationOpts(opts.Animation{Animation: opts.Bool(false)}),
-		).
-		SetGlobalOptions(
-			charts.WithTitleOpts(opts.Title{Title: fmt.Sprintf("EPEX NL %s", day.Format("2006-01-02")), Left: "36%"}),
-			charts.WithXAxisOpts(
-				opts.XAxis{
-					AxisLabel: &opts.AxisLabel{
-						Rotate: 90,
-					},
-				},
-			),
-		).
-		SetSeriesOptions(
-			charts.WithLabelOpts(
-				opts.Label{
-					Show:     opts.Bool(true),
-					Position: "inside",
-				},
-			),
-		)
+// createScheduler adds the jobs sending tomorrow's prices and the reports
+func createScheduler(cfg *app.ConfigApp) (*app.Scheduler, error) {
+	scheduler := app.NewScheduler(cfg.Location())
 
-	f, err := os.Create(chartHtmlFilename)
+	due, err := app.Daily(fmt.Sprintf("%02d:00", cfg.TomorrowHourMin()))
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer f.Close()
-	if err = bar.Render(f); err != nil {
-		return
+	// The failed prices are retried, so an escalation comes within the day.
+	scheduler.Add("notify-day", app.NotifyDayDue(cfg, due), func(now time.Time) {
+		_ = app.NotifyDay(cfg, time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, cfg.Location()))
+	})
+
+	// The states change every hour, tomorrow's prices are caught at 15:00 after notify-day.
+	if cfg.MQTT.Broker != "" {
+		scheduler.Add("mqtt", app.Hourly, func(now time.Time) {
+			if err := app.PublishMQTT(cfg, now); err != nil {
+				log.Printf("Error publishing to MQTT: %v\n", err)
+			}
+		})
 	}
 
-	return
-}
-
-// sendPriceMessage sends a price chart to the Telegram bot with a message
-func sendPriceMessage(cfg *app.ConfigApp, prices []decimal.Decimal, day time.Time) (err error) {
-	message := fmt.Sprintf("EPEX NL Day-Ahead %s", day.Format("2006-01-02"))
-	var priceMessage []string
-
-	// Check for high/low prices
-	highDetected := false
-	lowDetected := false
-
-	for _, priceEntry := range prices {
-		price := decimal.NewFromFloat(priceEntry.InexactFloat64())
-		if price.GreaterThanOrEqual(cfg.Analytics.HighPrice) {
-			highDetected = true
-		}
-		if price.LessThanOrEqual(cfg.Analytics.LowPrice) {
-			lowDetected = true
-		}
+	// The published prices are sometimes corrected, the sent messages are edited then.
+	if cfg.Loader.Revisions {
+		scheduler.Add("revisions", app.Hourly, func(now time.Time) {
+			app.CheckRevisions(cfg, now)
+		})
 	}
 
-	if highDetected && lowDetected {
-		priceMessage = append(priceMessage, "There are High/Low prices")
-	} else if highDetected {
-		priceMessage = append(priceMessage, "There are High prices")
-	} else if lowDetected {
-		priceMessage = append(priceMessage, "There are Low prices")
-	}
+	// Subscribers choose their own time, so they are checked every minute.
+	scheduler.Add("notify-subscribers", func(time.Time) bool { return true }, func(now time.Time) {
+		app.NotifySubscribers(cfg, now)
+	})
 
-	// Send Telegram message with chart
-	client, err := tgbotapi.NewBotAPI(cfg.Messenger.Telegram.Token)
-	if err != nil {
-		log.Fatal("Error Telegram Bot creating:", err)
+	if cfg.Reports.Weekly {
+		if due, err = app.Weekly(time.Monday, cfg.Reports.Time); err != nil {
+			return nil, err
+		}
+		scheduler.Add("weekly-report", due, func(now time.Time) {
+			if err := app.SendPeriodReport(cfg, app.ReportPeriodWeek, now); err != nil {
+				log.Printf("Error sending weekly report: %v\n", err)
+			}
+		})
 	}
-
-	message += "\n" + strings.Join(priceMessage, "\n")
-	log.Printf("Sending messages to Telegram: %s\n", strings.Replace(message, "\n", " ", -1))
-
-	if _, err = client.Send(tgbotapi.NewMessage(cfg.Messenger.Telegram.ChatID, message)); err != nil {
-		return
+	if cfg.Reports.Monthly {
+		if due, err = app.Monthly(1, cfg.Reports.Time); err != nil {
+			return nil, err
+		}
+		scheduler.Add("monthly-report", due, func(now time.Time) {
+			if err := app.SendPeriodReport(cfg, app.ReportPeriodMonth, now); err != nil {
+				log.Printf("Error sending monthly report: %v\n", err)
+			}
+		})
 	}
 
-	return err
-}
-
-// sendTelegramMessage sends a general message to the Telegram bot
-func sendTelegramMessage(cfg *app.ConfigTelegram, message string) {
-	log.Printf("Sending message to Telegram: %s\n", message)
-	client, err := tgbotapi.NewBotAPI(cfg.Token)
-	if err != nil {
-		log.Printf("Failed to initialize Telegram bot: %v\n", err)
-		return
-	}
-	msg := tgbotapi.NewMessage(cfg.ChatID, message)
-	if _, err = client.Send(msg); err != nil {
-		log.Printf("Error sending message to Telegram: %v\n", err)
-		return
-	}
+	return scheduler, nil
 }
@@ -1,6 +1,6 @@
 module github.com/oitimon/day-ahead-prices-notificator
 
-go 1.23
+go 1.23.0
 
 toolchain go1.23.6
 
@@ -13,6 +13,7 @@ require (
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/shopspring/decimal v1.4.0
 	github.com/stretchr/testify v1.6.0
+	golang.org/x/image v0.25.0
 )
 
 require (
@@ -0,0 +1,463 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Long polling timeout of getUpdates and the pause after a failed request.
+const botPollingTimeout = 30
+const botRetryDelay = 5 * time.Second
+
+// botWebhookQueue is the number of the received updates waiting for a worker.
+const botWebhookQueue = 100
+
+// botWebhookPath is the route of the webhook updates, the secret follows it.
+const botWebhookPath = "/telegram/webhook/"
+
+var webhookSecretRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// ErrBotBusy is returned when the webhook queue is full.
+var ErrBotBusy = errors.New("bot is busy")
+
+// errBotDayAfterTomorrow is returned for the days which prices can't be published yet.
+var errBotDayAfterTomorrow = errors.New("day is after tomorrow")
+
+const botHelp = `/today - today's prices
+/tomorrow - tomorrow's prices, published after 15:00
+/now - the price of the current and the next hour
+/cheapest 3h - the cheapest hours from now
+/chart 2025-02-28 - the chart of the day
+/stats week - the stats of the week or month
+/subscribe - the daily message with your own settings
+/settings - your settings
+/set high 0.25 - change a setting
+/unsubscribe - stop the daily message
+/help - this help`
+
+// Bot answers the commands in private chats and groups.
+type Bot struct {
+	cfg     *ConfigApp
+	client  *tgbotapi.BotAPI
+	now     func() time.Time
+	updates chan tgbotapi.Update
+}
+
+func NewBot(cfg *ConfigApp) (*Bot, error) {
+	client, err := newTelegramClient(&cfg.Messenger.Telegram)
+	if err != nil {
+		return nil, err
+	}
+	return &Bot{cfg: cfg, client: client, now: time.Now, updates: make(chan tgbotapi.Update, botWebhookQueue)}, nil
+}
+
+// Run handles the updates until the context is done, they are polled or received by the webhook.
+func (bot *Bot) Run(ctx context.Context) {
+	log.Printf("Starting Telegram bot @%s with %s updates\n", bot.client.Self.UserName, bot.cfg.Messenger.Telegram.Updates)
+	if bot.cfg.Messenger.Telegram.Updates == telegramUpdatesWebhook {
+		bot.serveWebhook(ctx)
+		return
+	}
+	bot.poll(ctx)
+}
+
+// poll gets the updates with long polling, the webhook is removed first as Telegram allows only one way.
+func (bot *Bot) poll(ctx context.Context) {
+	if _, err := bot.client.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Error deleting Telegram webhook: %v\n", err)
+	}
+	offset := 0
+	for ctx.Err() == nil {
+		updates, err := bot.client.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: botPollingTimeout})
+		if err != nil {
+			log.Printf("Error getting Telegram updates: %v\n", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(botRetryDelay):
+			}
+			continue
+		}
+		for _, update := range updates {
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+			}
+			if err := bot.HandleUpdate(update); err != nil {
+				log.Printf("Error handling Telegram update %d: %v\n", update.UpdateID, err)
+			}
+		}
+	}
+}
+
+// serveWebhook registers the webhook and handles the received updates with the workers.
+func (bot *Bot) serveWebhook(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := bot.SetWebhook()
+		if err == nil {
+			break
+		}
+		log.Printf("Error setting Telegram webhook: %v\n", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(botRetryDelay):
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < bot.cfg.Messenger.Telegram.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case update := <-bot.updates:
+					if err := bot.HandleUpdate(update); err != nil {
+						log.Printf("Error handling Telegram update %d: %v\n", update.UpdateID, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SetWebhook registers the webhook URL of the server with the secret token.
+func (bot *Bot) SetWebhook() error {
+	cfg := &bot.cfg.Messenger
+	params := tgbotapi.Params{
+		"url":             strings.TrimSuffix(cfg.PublicURL, "/") + botWebhookPath + cfg.Telegram.WebhookSecret,
+		"secret_token":    cfg.Telegram.WebhookSecret,
+		"max_connections": strconv.Itoa(cfg.Telegram.Workers),
+	}
+	if err := params.AddInterface("allowed_updates", []string{"message", "callback_query"}); err != nil {
+		return err
+	}
+	if _, err := bot.client.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("error setting Telegram webhook: %w", err)
+	}
+	return nil
+}
+
+// Enqueue passes the webhook update to the workers without waiting, ErrBotBusy when the queue is full.
+func (bot *Bot) Enqueue(update tgbotapi.Update) error {
+	select {
+	case bot.updates <- update:
+		return nil
+	default:
+		return ErrBotBusy
+	}
+}
+
+// WebhookSecret tells if the secret of the request is the configured one.
+func (bot *Bot) WebhookSecret(secret string) bool {
+	expected := bot.cfg.Messenger.Telegram.WebhookSecret
+	return expected != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
+
+// HandleUpdate answers the command of the message or the pressed keyboard button, other updates are ignored.
+func (bot *Bot) HandleUpdate(update tgbotapi.Update) error {
+	if update.CallbackQuery != nil {
+		return bot.handleCallback(update.CallbackQuery)
+	}
+	message := update.Message
+	if message == nil || !message.IsCommand() {
+		return nil
+	}
+	// In groups the command can be addressed to another bot.
+	if command := message.CommandWithAt(); strings.Contains(command, "@") &&
+		!strings.EqualFold(command[strings.Index(command, "@")+1:], bot.client.Self.UserName) {
+		return nil
+	}
+
+	reply, err := BotReply(bot.cfg, message.Chat.ID, message.Command(), message.CommandArguments(), bot.now())
+	if err != nil {
+		log.Printf("Error answering /%s: %v\n", message.Command(), err)
+		reply = botHint("Something went wrong, please try again later")
+	}
+	_, err = sendTelegramMessage(bot.client, message.Chat.ID, bot.cfg.Messenger.Telegram.ParseMode, reply, Receipt{})
+	return err
+}
+
+// handleCallback replaces the message of the pressed button with its view and answers the callback query,
+// the answer shows the reason when the view can't be built.
+func (bot *Bot) handleCallback(query *tgbotapi.CallbackQuery) (err error) {
+	answer := tgbotapi.NewCallback(query.ID, "")
+	if query.Message != nil {
+		var view Message
+		if view, err = BotCallbackReply(bot.cfg, query.Message.Chat.ID, query.Data, bot.now()); err == nil {
+			err = bot.editMessage(query.Message, view)
+		}
+	}
+	if errors.Is(err, ErrNoPrices) {
+		answer.Text, err = botNoPricesText(bot.cfg, query.Message.Chat.ID)
+	} else if err != nil {
+		log.Printf("Error answering callback %q: %v\n", query.Data, err)
+		answer.Text = "Something went wrong, please try again later"
+	}
+	if _, errAnswer := bot.client.Request(answer); errAnswer != nil {
+		return fmt.Errorf("error answering Telegram callback: %w", errAnswer)
+	}
+	return
+}
+
+// editMessage replaces the photo and its caption or the text of the message,
+// the text chart is dropped from the caption when it doesn't fit, the photo shows it.
+func (bot *Bot) editMessage(message *tgbotapi.Message, view Message) error {
+	receipt := Receipt{ChatID: message.Chat.ID}
+	if len(message.Photo) > 0 {
+		receipt.PhotoID = message.MessageID
+	} else {
+		receipt.TextIDs = []int{message.MessageID}
+		view.Attachments = nil
+	}
+	parseMode := bot.cfg.Messenger.Telegram.ParseMode
+	err := editTelegramMessage(bot.client, receipt, parseMode, view)
+	if errors.Is(err, ErrNotEditable) && receipt.PhotoID != 0 {
+		view.Document = view.Document.Without(BlockChart)
+		err = editTelegramMessage(bot.client, receipt, parseMode, view)
+	}
+	return err
+}
+
+// BotReply builds the answer to the command, the wrong arguments are answered with a hint.
+func BotReply(cfg *ConfigApp, chatID int64, command, args string, now time.Time) (reply Message, err error) {
+	now = now.In(cfg.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, cfg.Location())
+	args = strings.TrimSpace(args)
+
+	switch command {
+	case "start", "help":
+		reply.Document = TextDocument(botHelp)
+	case "today":
+		reply, err = botDayReply(cfg, chatID, today, now)
+	case "tomorrow":
+		reply, err = botDayReply(cfg, chatID, today.AddDate(0, 0, 1), now)
+	case "now":
+		reply, err = botNowReply(cfg, today, now)
+	case "cheapest":
+		reply, err = botCheapestReply(cfg, today, now, args)
+	case "chart":
+		day := today
+		if args != "" {
+			if day, err = time.ParseInLocation("2006-01-02", args, cfg.Location()); err != nil {
+				return botHint("Usage: /chart 2025-02-28"), nil
+			}
+		}
+		reply, err = botChartReply(cfg, day, now)
+	case "stats":
+		reply, err = botStatsReply(cfg, now, args)
+	case "subscribe", "settings", "set", "unsubscribe":
+		reply, err = botSubscriptionReply(cfg, chatID, command, args)
+	default:
+		reply.Document = TextDocument("Unknown command, try:\n" + botHelp)
+	}
+
+	if errors.Is(err, ErrNoPrices) {
+		text, errText := botNoPricesText(cfg, chatID)
+		return botHint(text), errText
+	} else if errors.Is(err, errBotDayAfterTomorrow) {
+		return botHint("No prices after tomorrow"), nil
+	}
+	return
+}
+
+func botHint(text string) Message {
+	return Message{Document: TextDocument(text)}
+}
+
+// botNoPricesText tells in the language of the chat when tomorrow's prices come.
+func botNoPricesText(cfg *ConfigApp, chatID int64) (string, error) {
+	subscription, _, err := cfg.Subscriptions().Load(chatID)
+	if err != nil {
+		return "", err
+	}
+	return cfg.MessageTemplates(subscription.Language).Render("no-prices-yet", TemplateData{})
+}
+
+// botLoadPrices loads the prices of the day the bot can show, tomorrow's aren't fetched before
+// they are published, so the incomplete answer isn't stored in the history.
+func botLoadPrices(cfg *ConfigApp, day, now time.Time) ([]decimal.Decimal, error) {
+	now = now.In(cfg.Location())
+	if day.After(time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, cfg.Location())) {
+		return nil, errBotDayAfterTomorrow
+	}
+	if !botKnownDay(cfg, day, now) {
+		return nil, ErrNoPrices
+	}
+	return LoadPrices(cfg, day)
+}
+
+// botDayReply builds the day message in the language and the tariff of the subscribed chat.
+func botDayReply(cfg *ConfigApp, chatID int64, day, now time.Time) (reply Message, err error) {
+	prices, err := botLoadPrices(cfg, day, now)
+	if err != nil {
+		return
+	}
+	subscription, _, err := cfg.Subscriptions().Load(chatID)
+	if err != nil {
+		return
+	}
+	analytics := subscription.Analytics(&cfg.Analytics)
+	templates := cfg.MessageTemplates(subscription.Language)
+	if reply, err = BuildDayMessage(&analytics, templates, subscription.Prices(&cfg.Tariff, prices), day); err != nil {
+		return
+	}
+	reply.Keyboard, err = DayKeyboard(&cfg.Messenger, templates, day)
+	return
+}
+
+func botChartReply(cfg *ConfigApp, day, now time.Time) (reply Message, err error) {
+	prices, err := botLoadPrices(cfg, day, now)
+	if err != nil {
+		return
+	}
+	image, err := ChartPNG(&cfg.Analytics, prices, day)
+	if err != nil {
+		return
+	}
+	date := day.Format("2006-01-02")
+	reply.Document.Heading("EPEX NL Day-Ahead " + date)
+	reply.Attachments = []Attachment{{
+		Kind:     AttachmentPhoto,
+		Name:     fmt.Sprintf("epex_nl_%s.png", date),
+		MimeType: "image/png",
+		Data:     image,
+	}}
+	return
+}
+
+func botNowReply(cfg *ConfigApp, today, now time.Time) (reply Message, err error) {
+	slots, err := botUpcomingSlots(cfg, today, now)
+	if err != nil {
+		return
+	}
+	lines := []string{}
+	for i, label := range []string{"Now", "Next"} {
+		if i >= len(slots) {
+			break
+		}
+		tier := cfg.Analytics.Tier(slots[i].price)
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf(
+			"%s %s %s %s %s", label, slots[i].start.Format("15:04"), slots[i].price.StringFixed(3), tier.Emoji, tier.Label,
+		)))
+	}
+	reply.Document = TextDocument(strings.Join(lines, "\n"))
+	return
+}
+
+func botCheapestReply(cfg *ConfigApp, today, now time.Time, args string) (reply Message, err error) {
+	hours := 1
+	if args != "" {
+		if hours, err = strconv.Atoi(strings.TrimSuffix(strings.ToLower(args), "h")); err != nil || hours < 1 || hours > 24 {
+			return botHint("Usage: /cheapest 3h, from 1h to 24h"), nil
+		}
+	}
+	slots, err := botUpcomingSlots(cfg, today, now)
+	if err != nil {
+		return
+	}
+	if len(slots) < hours {
+		return botHint(fmt.Sprintf("Not enough known prices for %dh", hours)), nil
+	}
+
+	prices := make([]decimal.Decimal, len(slots))
+	for i, slot := range slots {
+		prices[i] = slot.price
+	}
+	best, average := cheapestWindow(prices, hours)
+	from, till := slots[best].start, slots[best+hours-1].start.Add(time.Hour)
+	reply.Document = TextDocument(fmt.Sprintf(
+		"Cheapest %dh: %s %s-%s, average %s",
+		hours, from.Format("2006-01-02"), from.Format("15:04"), till.Format("15:04"),
+		average.StringFixed(3),
+	))
+	return
+}
+
+func botStatsReply(cfg *ConfigApp, now time.Time, args string) (reply Message, err error) {
+	period := ReportPeriodWeek
+	if args != "" {
+		period = strings.ToLower(args)
+	}
+	report, err := BuildPeriodReport(cfg.History(), period, now)
+	if errors.Is(err, ErrUnknownReportPeriod) {
+		return botHint("Usage: /stats week or /stats month"), nil
+	} else if errors.Is(err, ErrNotEnoughHistory) {
+		return botHint("No stored prices for this " + period + " yet"), nil
+	} else if err != nil {
+		return
+	}
+	return PeriodReportMessage(&cfg.Analytics, cfg.MessageTemplates("").Locale(), &report)
+}
+
+func botSubscriptionReply(cfg *ConfigApp, chatID int64, command, args string) (reply Message, err error) {
+	subscription, ok, err := cfg.Subscriptions().Load(chatID)
+	if err != nil {
+		return
+	}
+	switch {
+	case command == "unsubscribe":
+		if ok {
+			err = cfg.Subscriptions().Delete(chatID)
+		}
+		return botHint("Unsubscribed, /subscribe to get the daily message again"), err
+	case command == "subscribe" && !ok:
+		subscription = cfg.NewSubscription(chatID)
+		if err = cfg.Subscriptions().Save(subscription); err != nil {
+			return
+		}
+	case !ok:
+		return botHint("Not subscribed yet, /subscribe first"), nil
+	case command == "set":
+		name, value, _ := strings.Cut(args, " ")
+		if errSet := subscription.Set(strings.ToLower(name), value); errors.Is(errSet, ErrUnknownSetting) {
+			return botHint("Usage: /set high|low|time|timezone|quiet|language|tariff|alerts value"), nil
+		} else if errSet != nil {
+			return botHint(errSet.Error()), nil
+		}
+		if err = cfg.Subscriptions().Save(subscription); err != nil {
+			return
+		}
+	}
+	reply.Document = TextDocument(SubscriptionText(&cfg.Analytics, &subscription))
+	return
+}
+
+type botSlot struct {
+	start time.Time
+	price decimal.Decimal
+}
+
+// botUpcomingSlots returns the prices from the current hour till the end of tomorrow when it's known.
+func botUpcomingSlots(cfg *ConfigApp, today, now time.Time) (slots []botSlot, err error) {
+	prices, err := LoadPrices(cfg, today)
+	if err != nil {
+		return
+	}
+	for i, price := range prices {
+		start := today.Add(time.Duration(i) * time.Hour)
+		if !start.Add(time.Hour).After(now) {
+			continue
+		}
+		slots = append(slots, botSlot{start: start, price: price})
+	}
+
+	tomorrow := today.AddDate(0, 0, 1)
+	prices = loadTomorrowPrices(cfg, tomorrow, now)
+	for i, price := range prices {
+		slots = append(slots, botSlot{start: tomorrow.Add(time.Duration(i) * time.Hour), price: price})
+	}
+	return
+}
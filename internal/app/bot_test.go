@@ -0,0 +1,287 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateBotTestConfig() *ConfigApp {
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	return cfg
+}
+
+func TestBotReply_Now(t *testing.T) {
+	cfg := generateBotTestConfig()
+
+	reply, err := BotReply(cfg, 42, "now", "", time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "Now 13:00 0\\.000 🟢 Low\nNext 14:00 \\-0\\.020 🟢 Low", reply.Document.Format(FormatMarkdownV2))
+}
+
+func TestBotReply_Cheapest(t *testing.T) {
+	cfg := generateBotTestConfig()
+
+	// Tomorrow's prices aren't published yet.
+	reply, err := BotReply(cfg, 42, "cheapest", "3h", time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "Cheapest 3h: 2025\\-02\\-28 13:00\\-16:00, average 0\\.013", reply.Document.Format(FormatMarkdownV2))
+
+	reply, err = BotReply(cfg, 42, "cheapest", "3", time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "Cheapest 3h: 2025\\-03\\-01 12:00\\-15:00, average 0\\.007", reply.Document.Format(FormatMarkdownV2))
+
+	reply, err = BotReply(cfg, 42, "cheapest", "25h", time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "Usage: /cheapest 3h")
+
+	// Tomorrow's prices are stored by now, so another day.
+	reply, err = BotReply(cfg, 42, "cheapest", "12h", time.Date(2025, 3, 3, 14, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "Not enough known prices for 12h", reply.Document.Format(FormatMarkdownV2))
+}
+
+func TestBotReply_Day(t *testing.T) {
+	cfg := generateBotTestConfig()
+	now := time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location())
+
+	reply, err := BotReply(cfg, 42, "tomorrow", "", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "*EPEX NL Day\\-Ahead 2025\\-03\\-01*")
+	require.Len(t, reply.Attachments, 1)
+
+	reply, err = BotReply(cfg, 42, "chart", "2025-02-27", now)
+	require.NoError(t, err)
+	assert.Equal(t, "*EPEX NL Day\\-Ahead 2025\\-02\\-27*", reply.Document.Format(FormatMarkdownV2))
+	require.Len(t, reply.Attachments, 1)
+	assert.Equal(t, "epex_nl_2025-02-27.png", reply.Attachments[0].Name)
+
+	reply, err = BotReply(cfg, 42, "chart", "yesterday", now)
+	require.NoError(t, err)
+	assert.Equal(t, "Usage: /chart 2025\\-02\\-28", reply.Document.Format(FormatMarkdownV2))
+}
+
+func TestBotReply_NoPrices(t *testing.T) {
+	server := generateFakeServerWithBody(`{"Prices":[]}`)
+	defer server.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.API.Endpoint = server.URL
+
+	reply, err := BotReply(cfg, 42, "tomorrow", "", time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "No prices yet")
+}
+
+func TestBotReply_TomorrowTooEarly(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"prices":[{"price":100.0},{"price":200.0}]}`))
+	}))
+	defer server.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.API.Endpoint = server.URL
+	tomorrow := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+
+	for _, command := range []string{"tomorrow", "chart"} {
+		reply, err := BotReply(cfg, 42, command, "2025-03-01", time.Date(2025, 2, 28, 14, 59, 0, 0, cfg.Location()))
+		require.NoError(t, err)
+		assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "No prices yet")
+	}
+	assert.Equal(t, 0, requests)
+	_, ok, err := cfg.History().Load(tomorrow)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Published by now.
+	reply, err := BotReply(cfg, 42, "tomorrow", "", time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "2025\\-03\\-01")
+	assert.Equal(t, 1, requests)
+}
+
+func TestBotReply_FutureDay(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"prices":[{"price":100.0},{"price":200.0}]}`))
+	}))
+	defer server.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.API.Endpoint = server.URL
+
+	reply, err := BotReply(cfg, 42, "chart", "2025-03-02", time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "No prices after tomorrow", reply.Document.Format(FormatMarkdownV2))
+	assert.Equal(t, 0, requests)
+	_, ok, err := cfg.History().Load(time.Date(2025, 3, 2, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBotReply_Stats(t *testing.T) {
+	cfg := generateBotTestConfig()
+	now := time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location())
+
+	reply, err := BotReply(cfg, 42, "stats", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, "No stored prices for this week yet", reply.Document.Format(FormatMarkdownV2))
+
+	saveTestHistory(t, cfg, now, 14)
+	reply, err = BotReply(cfg, 42, "stats", "month", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "*EPEX NL month 2025\\-02\\-01 – 2025\\-02\\-28*")
+
+	reply, err = BotReply(cfg, 42, "stats", "year", now)
+	require.NoError(t, err)
+	assert.Equal(t, "Usage: /stats week or /stats month", reply.Document.Format(FormatMarkdownV2))
+}
+
+func TestBotReply_Help(t *testing.T) {
+	cfg := generateBotTestConfig()
+
+	reply, err := BotReply(cfg, 42, "help", "", time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "/cheapest 3h")
+
+	reply, err = BotReply(cfg, 42, "weather", "", time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "Unknown command")
+}
+
+func TestBot_Run(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	bot, err := NewBot(cfg)
+	require.NoError(t, err)
+	bot.now = func() time.Time {
+		return time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())
+	}
+	command := func(id int, text string) string {
+		return fmt.Sprintf(
+			`{"update_id":%d,"message":{"message_id":%d,"date":0,"chat":{"id":42,"type":"group"},"text":%q,`+
+				`"entities":[{"type":"bot_command","offset":0,"length":%d}]}}`,
+			id, id, text, len(text),
+		)
+	}
+	telegram.AddUpdate(command(1, "/now@otherbot"))
+	telegram.AddUpdate(`{"update_id":2,"message":{"message_id":2,"date":0,"chat":{"id":42,"type":"group"},"text":"hello"}}`)
+	telegram.AddUpdate(command(3, "/now@bot"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(telegram.Requests()) > 1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "deleteWebhook", requests[0].Method)
+	assert.Equal(t, "sendMessage", requests[1].Method)
+	assert.Equal(t, "42", requests[1].Values["chat_id"])
+	assert.Equal(t, "Now 13:00 0\\.000 🟢 Low\nNext 14:00 \\-0\\.020 🟢 Low", requests[1].Values["text"])
+}
+
+func TestBot_Webhook(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Messenger.Telegram.Updates = telegramUpdatesWebhook
+	cfg.Messenger.Telegram.WebhookSecret = "secret"
+	cfg.Messenger.Telegram.Workers = 2
+	cfg.Messenger.PublicURL = "https://prices.example.com/"
+
+	bot, err := NewBot(cfg)
+	require.NoError(t, err)
+	bot.now = func() time.Time {
+		return time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())
+	}
+	assert.True(t, bot.WebhookSecret("secret"))
+	assert.False(t, bot.WebhookSecret("other"))
+
+	var update tgbotapi.Update
+	require.NoError(t, json.Unmarshal([]byte(
+		`{"update_id":1,"message":{"message_id":1,"date":0,"chat":{"id":42,"type":"private"},"text":"/now",`+
+			`"entities":[{"type":"bot_command","offset":0,"length":4}]}}`,
+	), &update))
+	require.NoError(t, bot.Enqueue(update))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(telegram.Requests()) > 1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "setWebhook", requests[0].Method)
+	assert.Equal(t, "https://prices.example.com/telegram/webhook/secret", requests[0].Values["url"])
+	assert.Equal(t, "secret", requests[0].Values["secret_token"])
+	assert.Equal(t, `["message","callback_query"]`, requests[0].Values["allowed_updates"])
+	assert.Equal(t, "sendMessage", requests[1].Method)
+	assert.Equal(t, "42", requests[1].Values["chat_id"])
+
+	// The full queue refuses the updates instead of blocking the request.
+	for i := 0; i < botWebhookQueue; i++ {
+		require.NoError(t, bot.Enqueue(update))
+	}
+	assert.True(t, errors.Is(bot.Enqueue(update), ErrBotBusy))
+}
+
+func TestBotReply_Subscription(t *testing.T) {
+	cfg := generateBotTestConfig()
+	now := time.Date(2025, 2, 28, 16, 0, 0, 0, cfg.Location())
+
+	reply, err := BotReply(cfg, 42, "settings", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, "Not subscribed yet, /subscribe first", reply.Document.Format(FormatMarkdownV2))
+
+	reply, err = BotReply(cfg, 42, "subscribe", "", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "High: 0\\.200\n")
+
+	reply, err = BotReply(cfg, 42, "set", "high 0.3", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "High: 0\\.300\n")
+	subscription, ok, err := cfg.Subscriptions().Load(42)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "0.3", subscription.HighPrice.String())
+
+	reply, err = BotReply(cfg, 42, "set", "time 7", now)
+	require.NoError(t, err)
+	assert.Equal(t, "invalid time \"7\", expected HH:MM", reply.Document.Format(FormatMarkdownV2))
+
+	reply, err = BotReply(cfg, 42, "unsubscribe", "", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatMarkdownV2), "Unsubscribed")
+	_, ok, err = cfg.Subscriptions().Load(42)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
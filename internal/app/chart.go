@@ -6,6 +6,7 @@ import (
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/guptarohit/asciigraph"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
 	"github.com/shopspring/decimal"
 	"log"
 	"math"
@@ -19,8 +20,9 @@ const (
 	htmlPageTitle = "EPEX NL %s"
 )
 
-func ChartText(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) (message string, err error) {
-	return drawLinesBarChartHtml(cfg, prices, 30, true)
+// ChartText returns the text bar chart of the day, every messenger renders it with its own markup.
+func ChartText(cfg *ConfigAnalytics, locale *Locale, prices []decimal.Decimal, day time.Time) (chart Block, err error) {
+	return drawLinesBarChart(cfg, locale, prices, 30)
 }
 
 func ChartHtml(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) (html []byte, err error) {
@@ -47,7 +49,11 @@ func ChartHtml(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) (h
 			charts.WithAnimationOpts(opts.Animation{Animation: opts.Bool(true)}),
 		).
 		SetGlobalOptions(
-			charts.WithTitleOpts(opts.Title{Title: fmt.Sprintf("EPEX NL %s", day.Format("2006-01-02")), Left: "36%"}),
+			charts.WithTitleOpts(opts.Title{
+				Title:    fmt.Sprintf("EPEX NL %s", day.Format("2006-01-02")),
+				Subtitle: strings.Join(cfg.TierLegend(LocaleFor("en")), "   "),
+				Left:     "36%",
+			}),
 			charts.WithXAxisOpts(
 				opts.XAxis{
 					AxisLabel: &opts.AxisLabel{
@@ -94,17 +100,162 @@ func ChartHtml(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) (h
 	return
 }
 
-func getColor(value decimal.Decimal, cfg *ConfigAnalytics) string {
-	if value.LessThanOrEqual(cfg.LowPrice) {
-		return "green"
-	} else if value.GreaterThanOrEqual(cfg.HighPrice) {
-		return "red"
-	} else {
-		return ""
+// ChartForecastHtml generates the chart of estimated prices with the confidence band.
+func ChartForecastHtml(cfg *ConfigAnalytics, forecast *Forecast) (html []byte, err error) {
+	log.Printf("Generating forecast charts for: %s\n", forecast.Day.Format("2006-01-02"))
+
+	bar := charts.NewBar()
+	line := charts.NewLine()
+	xAxis := make([]string, len(forecast.Prices))
+	yAxis := make([]opts.BarData, len(forecast.Prices))
+	lower := make([]opts.LineData, len(forecast.Prices))
+	upper := make([]opts.LineData, len(forecast.Prices))
+	for i, price := range forecast.Prices {
+		xAxis[i] = strconv.Itoa(i)
+		yAxis[i] = opts.BarData{Value: price, ItemStyle: &opts.ItemStyle{Color: getColor(price, cfg), Opacity: 0.5}}
+		lower[i] = opts.LineData{Value: forecast.Lower[i]}
+		upper[i] = opts.LineData{Value: forecast.Upper[i]}
+	}
+
+	line.SetXAxis(xAxis).
+		AddSeries("Lower", lower, charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed", Color: "grey"})).
+		AddSeries("Upper", upper, charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed", Color: "grey"}))
+	bar.SetXAxis(xAxis).
+		AddSeries("Estimate", yAxis).
+		SetGlobalOptions(
+			charts.WithTitleOpts(opts.Title{
+				Title:    fmt.Sprintf("EPEX NL %s (estimate)", forecast.Day.Format("2006-01-02")),
+				Subtitle: "Forecast from the price history, real prices are not published yet",
+				Left:     "30%",
+			}),
+			charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+			charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+			charts.WithXAxisOpts(
+				opts.XAxis{
+					AxisLabel: &opts.AxisLabel{
+						Rotate:    90,
+						Formatter: opts.FuncOpts(`function (value) { return value.padStart(2, '0')+':00'; }`),
+					},
+				},
+			),
+		).
+		SetSeriesOptions(
+			charts.WithLabelOpts(
+				opts.Label{
+					Show:     opts.Bool(true),
+					Position: "inside",
+				},
+			),
+		)
+	bar.Overlap(line)
+
+	var buf bytes.Buffer
+	if err = bar.Render(&buf); err != nil {
+		err = fmt.Errorf("bar.Render(w): %w", err)
+		return
+	}
+	html = bytes.Replace(buf.Bytes(), []byte("Awesome go-echarts"), []byte(fmt.Sprintf(htmlPageTitle, cfg.Version)), -1)
+
+	return
+}
+
+// ChartPeriodHtml generates a bar chart of the hour averages of the period and the previous one.
+func ChartPeriodHtml(cfg *ConfigAnalytics, report *models.PeriodReport) (html []byte, err error) {
+	bar := charts.NewBar()
+	xAxis := make([]string, len(report.Current.HourAverages))
+	current := make([]opts.BarData, len(report.Current.HourAverages))
+	for i, price := range report.Current.HourAverages {
+		xAxis[i] = strconv.Itoa(i)
+		current[i] = opts.BarData{Value: price, ItemStyle: &opts.ItemStyle{Color: getColor(price, cfg)}}
 	}
+	bar.SetXAxis(xAxis).AddSeries(fmt.Sprintf("%s – %s", report.Current.From, report.Current.Till), current)
+	if report.Previous != nil {
+		previous := make([]opts.BarData, len(report.Previous.HourAverages))
+		for i, price := range report.Previous.HourAverages {
+			previous[i] = opts.BarData{Value: price, ItemStyle: &opts.ItemStyle{Color: "grey"}}
+		}
+		bar.AddSeries(fmt.Sprintf("%s – %s", report.Previous.From, report.Previous.Till), previous)
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: fmt.Sprintf("EPEX NL %s %s – %s", report.Period, report.Current.From, report.Current.Till),
+			Subtitle: fmt.Sprintf(
+				"Base %s, peak %s, negative hours %d",
+				report.Current.BaseAverage.StringFixed(3), report.Current.PeakAverage.StringFixed(3), report.Current.NegativeHours,
+			),
+			Left: "30%",
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				AxisLabel: &opts.AxisLabel{
+					Rotate:    90,
+					Formatter: opts.FuncOpts(`function (value) { return value.padStart(2, '0')+':00'; }`),
+				},
+			},
+		),
+	)
+
+	var buf bytes.Buffer
+	if err = bar.Render(&buf); err != nil {
+		err = fmt.Errorf("bar.Render(w): %w", err)
+		return
+	}
+	html = bytes.Replace(buf.Bytes(), []byte("Awesome go-echarts"), []byte(fmt.Sprintf(htmlPageTitle, cfg.Version)), -1)
+
+	return
+}
+
+// ChartCostHtml generates a bar chart comparing dynamic and fixed costs per day.
+func ChartCostHtml(cfg *ConfigAnalytics, report *models.CostReport) (html []byte, err error) {
+	bar := charts.NewBar()
+	xAxis := make([]string, len(report.Days))
+	dynamic := make([]opts.BarData, len(report.Days))
+	fixed := make([]opts.BarData, len(report.Days))
+	for i, day := range report.Days {
+		xAxis[i] = day.Date
+		dynamic[i] = opts.BarData{Value: day.DynamicCost}
+		fixed[i] = opts.BarData{Value: day.FixedCost}
+	}
+
+	bar.SetXAxis(xAxis).
+		AddSeries("Dynamic", dynamic).
+		AddSeries("Fixed", fixed).
+		SetGlobalOptions(
+			charts.WithTitleOpts(opts.Title{
+				Title: fmt.Sprintf(
+					"Dynamic %s € / Fixed %s €",
+					report.Total.DynamicCost.StringFixed(2), report.Total.FixedCost.StringFixed(2),
+				),
+				Left: "30%",
+			}),
+			charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+			charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+			charts.WithXAxisOpts(opts.XAxis{AxisLabel: &opts.AxisLabel{Rotate: 90}}),
+		)
+
+	var buf bytes.Buffer
+	if err = bar.Render(&buf); err != nil {
+		err = fmt.Errorf("bar.Render(w): %w", err)
+		return
+	}
+	html = bytes.Replace(buf.Bytes(), []byte("Awesome go-echarts"), []byte(fmt.Sprintf(htmlPageTitle, cfg.Version)), -1)
+
+	return
 }
 
-func drawLinesBarChartHtml(cfg *ConfigAnalytics, prices []decimal.Decimal, width int, markDown bool) (message string, err error) {
+func getColor(value decimal.Decimal, cfg *ConfigAnalytics) string {
+	return cfg.Tier(value).Color
+}
+
+// drawLinesBarChart returns a row per price with the bar, the tier emoji and the price in the locale.
+func drawLinesBarChart(cfg *ConfigAnalytics, locale *Locale, prices []decimal.Decimal, width int) (chart Block, err error) {
+	chart.Kind = BlockChart
+	if len(prices) == 0 {
+		return
+	}
 	maxVal, minVal := prices[0], prices[0]
 	for _, price := range prices {
 		if maxVal.LessThan(price) {
@@ -124,20 +275,12 @@ func drawLinesBarChartHtml(cfg *ConfigAnalytics, prices []decimal.Decimal, width
 	}
 
 	for i, price := range prices {
-		bar := strings.Repeat(barChar, int((price.InexactFloat64()-minVal.InexactFloat64()+scale)/scale))
-		marker := ""
-		markerFont := ""
-		priceString := price.StringFixed(2)
-		if markDown {
-			if price.LessThanOrEqual(cfg.LowPrice) {
-				marker = "_"
-			} else if price.GreaterThan(cfg.HighPrice) {
-				marker = "*"
-			}
-			priceString = strings.Replace(priceString, ".", "\\.", -1)
-			markerFont = "`"
-		}
-		message += fmt.Sprintf("%s%02d:00%s %s %s%s%s\n", markerFont, i, markerFont, bar, marker, priceString, marker)
+		chart.Rows = append(chart.Rows, ChartRow{
+			Label:  fmt.Sprintf("%02d:00", i),
+			Bar:    strings.Repeat(barChar, int((price.InexactFloat64()-minVal.InexactFloat64()+scale)/scale)),
+			Marker: cfg.Tier(price).Emoji,
+			Value:  locale.Number(price, 2),
+		})
 	}
 
 	return
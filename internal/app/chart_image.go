@@ -0,0 +1,301 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartImageWidth  = 960
+	chartImageHeight = 540
+	chartBarColor    = "#5470c6"
+	chartAxisColor   = "#6e7079"
+	chartGridColor   = "#e0e6f1"
+)
+
+// Named colours we can meet in tiers, any other colour must be "#rrggbb" or "#rgb".
+var chartNamedColors = map[string]color.RGBA{
+	"black":     {0, 0, 0, 255},
+	"blue":      {0, 0, 255, 255},
+	"darkgreen": {0, 100, 0, 255},
+	"darkred":   {139, 0, 0, 255},
+	"gold":      {255, 215, 0, 255},
+	"gray":      {128, 128, 128, 255},
+	"green":     {0, 128, 0, 255},
+	"grey":      {128, 128, 128, 255},
+	"lime":      {0, 255, 0, 255},
+	"orange":    {255, 165, 0, 255},
+	"purple":    {128, 0, 128, 255},
+	"red":       {255, 0, 0, 255},
+	"white":     {255, 255, 255, 255},
+	"yellow":    {255, 255, 0, 255},
+}
+
+type chartRect struct {
+	x, y, w, h int
+}
+
+type chartBar struct {
+	chartRect
+	color  string
+	value  string
+	valueY int
+	xLabel string
+}
+
+type chartTick struct {
+	y     int
+	label string
+}
+
+type chartLine struct {
+	y     int
+	color string
+	label string
+}
+
+// chartLayout is the chart geometry shared by the PNG and SVG renderers.
+type chartLayout struct {
+	width, height int
+	title         string
+	legend        []string
+	plot          chartRect
+	bars          []chartBar
+	ticks         []chartTick
+	lines         []chartLine
+}
+
+// ChartPNG draws the coloured bar chart of the prices as PNG.
+func ChartPNG(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) ([]byte, error) {
+	return drawChartPNG(buildChartLayout(cfg, prices, day))
+}
+
+// PeriodChartPNG draws the chart of the hour averages of the period report as PNG.
+func PeriodChartPNG(cfg *ConfigAnalytics, report *models.PeriodReport) ([]byte, error) {
+	layout := buildChartLayout(cfg, report.Current.HourAverages, time.Time{})
+	layout.title = fmt.Sprintf("EPEX NL %s %s - %s", report.Period, report.Current.From, report.Current.Till)
+	return drawChartPNG(layout)
+}
+
+func drawChartPNG(layout chartLayout) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, layout.width, layout.height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// basicfont has ASCII glyphs only, so the legend goes without emoji and symbols.
+	textWidth := func(text string) int {
+		return font.MeasureString(basicfont.Face7x13, text).Ceil()
+	}
+	drawText := func(text string, x, y int, c color.RGBA) {
+		drawer := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: basicfont.Face7x13, Dot: fixed.P(x, y)}
+		drawer.DrawString(text)
+	}
+	fillRect := func(r chartRect, c color.RGBA) {
+		draw.Draw(img, image.Rect(r.x, r.y, r.x+r.w, r.y+r.h), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	for _, tick := range layout.ticks {
+		fillRect(chartRect{layout.plot.x, tick.y, layout.plot.w, 1}, parseChartColor(chartGridColor))
+		drawText(tick.label, layout.plot.x-8-textWidth(tick.label), tick.y+4, parseChartColor(chartAxisColor))
+	}
+	for _, bar := range layout.bars {
+		fillRect(bar.chartRect, parseChartColor(bar.color))
+		drawText(bar.value, bar.x+(bar.w-textWidth(bar.value))/2, bar.valueY, parseChartColor(chartAxisColor))
+		drawText(bar.xLabel, bar.x+(bar.w-textWidth(bar.xLabel))/2, layout.plot.y+layout.plot.h+16, parseChartColor(chartAxisColor))
+	}
+	for _, line := range layout.lines {
+		c := parseChartColor(line.color)
+		for x := layout.plot.x; x < layout.plot.x+layout.plot.w; x += 8 {
+			fillRect(chartRect{x, line.y, 4, 1}, c)
+		}
+		drawText(asciiChartText(line.label), layout.plot.x+layout.plot.w+4, line.y+4, c)
+	}
+	fillRect(chartRect{layout.plot.x, layout.plot.y, 1, layout.plot.h}, parseChartColor(chartAxisColor))
+
+	drawText(layout.title, (layout.width-textWidth(layout.title))/2, 24, color.RGBA{A: 255})
+	legend := asciiChartText(strings.Join(layout.legend, "   "))
+	drawText(legend, (layout.width-textWidth(legend))/2, 44, parseChartColor(chartAxisColor))
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("png.Encode(w): %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ChartSVG draws the coloured bar chart of the prices as SVG.
+func ChartSVG(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) ([]byte, error) {
+	layout := buildChartLayout(cfg, prices, day)
+	var buf bytes.Buffer
+	text := func(value string, x, y int, anchor, fill string, size int) {
+		fmt.Fprintf(
+			&buf, `<text x="%d" y="%d" text-anchor="%s" fill="%s" font-size="%d">%s</text>`+"\n",
+			x, y, anchor, html.EscapeString(fill), size, html.EscapeString(value),
+		)
+	}
+
+	fmt.Fprintf(
+		&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`+"\n",
+		layout.width, layout.height, layout.width, layout.height,
+	)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	text(layout.title, layout.width/2, 26, "middle", "black", 18)
+	text(strings.Join(layout.legend, "   "), layout.width/2, 46, "middle", chartAxisColor, 12)
+	for _, tick := range layout.ticks {
+		fmt.Fprintf(
+			&buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+			layout.plot.x, tick.y, layout.plot.x+layout.plot.w, tick.y, chartGridColor,
+		)
+		text(tick.label, layout.plot.x-8, tick.y+4, "end", chartAxisColor, 12)
+	}
+	for _, bar := range layout.bars {
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", bar.x, bar.y, bar.w, bar.h, html.EscapeString(bar.color))
+		text(bar.value, bar.x+bar.w/2, bar.valueY, "middle", chartAxisColor, 11)
+		text(bar.xLabel, bar.x+bar.w/2, layout.plot.y+layout.plot.h+16, "middle", chartAxisColor, 12)
+	}
+	for _, line := range layout.lines {
+		fmt.Fprintf(
+			&buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4 4"/>`+"\n",
+			layout.plot.x, line.y, layout.plot.x+layout.plot.w, line.y, html.EscapeString(line.color),
+		)
+		text(line.label, layout.plot.x+layout.plot.w+4, line.y+4, "start", line.color, 11)
+	}
+	fmt.Fprintf(
+		&buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+		layout.plot.x, layout.plot.y, layout.plot.x, layout.plot.y+layout.plot.h, chartAxisColor,
+	)
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes(), nil
+}
+
+func buildChartLayout(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time) chartLayout {
+	layout := chartLayout{
+		width:  chartImageWidth,
+		height: chartImageHeight,
+		title:  fmt.Sprintf("EPEX NL %s", day.Format("2006-01-02")),
+		legend: cfg.TierLegend(LocaleFor("en")),
+		plot:   chartRect{x: 60, y: 70, w: chartImageWidth - 150, h: chartImageHeight - 110},
+	}
+
+	minVal, maxVal := 0.0, 0.0
+	for _, price := range prices {
+		minVal = math.Min(minVal, price.InexactFloat64())
+		maxVal = math.Max(maxVal, price.InexactFloat64())
+	}
+	step := chartTickStep(maxVal - minVal)
+	minVal = math.Floor(minVal/step) * step
+	maxVal = math.Ceil(maxVal/step) * step
+	if maxVal == minVal {
+		maxVal = minVal + step
+	}
+	toY := func(value float64) int {
+		return layout.plot.y + int(math.Round((maxVal-value)/(maxVal-minVal)*float64(layout.plot.h)))
+	}
+
+	for value := minVal; value <= maxVal+step/2; value += step {
+		layout.ticks = append(layout.ticks, chartTick{y: toY(value), label: strconv.FormatFloat(value, 'f', 2, 64)})
+	}
+
+	zeroY := toY(0)
+	slot := float64(layout.plot.w) / math.Max(float64(len(prices)), 1)
+	for i, price := range prices {
+		y := toY(price.InexactFloat64())
+		bar := chartBar{
+			chartRect: chartRect{x: layout.plot.x + int(float64(i)*slot+slot*0.15), w: int(slot * 0.7)},
+			color:     cfg.Tier(price).Color,
+			value:     price.StringFixed(2),
+			xLabel:    fmt.Sprintf("%02d", i),
+		}
+		// Values go above positive bars and below negative ones.
+		if price.IsNegative() {
+			bar.y, bar.h, bar.valueY = zeroY, y-zeroY, y+14
+		} else {
+			bar.y, bar.h, bar.valueY = y, zeroY-y, y-4
+		}
+		if bar.color == "" {
+			bar.color = chartBarColor
+		}
+		layout.bars = append(layout.bars, bar)
+	}
+
+	// Threshold lines are drawn for the tier boundaries within the value range.
+	for _, tier := range cfg.PriceTiers() {
+		if tier.Boundary == nil {
+			continue
+		}
+		boundary := tier.Boundary.InexactFloat64()
+		if boundary < minVal || boundary > maxVal {
+			continue
+		}
+		line := chartLine{y: toY(boundary), color: tier.Color, label: tier.Label}
+		if line.color == "" {
+			line.color = chartAxisColor
+		}
+		layout.lines = append(layout.lines, line)
+	}
+
+	return layout
+}
+
+// chartTickStep returns a 1, 2 or 5 based step for about 5 ticks.
+func chartTickStep(span float64) float64 {
+	if span <= 0 {
+		return 0.05
+	}
+	raw := span / 5
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, factor := range []float64{1, 2, 5} {
+		if raw <= factor*magnitude {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func parseChartColor(value string) color.RGBA {
+	if c, ok := chartColor(value); ok {
+		return c
+	}
+	return parseChartColor(chartBarColor)
+}
+
+// chartColor parses the named colour or "#rrggbb"/"#rgb", ok is false for anything else.
+func chartColor(value string) (c color.RGBA, ok bool) {
+	if c, ok = chartNamedColors[strings.ToLower(value)]; ok {
+		return
+	}
+	hex, found := strings.CutPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil && found && len(hex) == 6 {
+		return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, true
+	}
+	return
+}
+
+// asciiChartText replaces what basicfont can't draw.
+func asciiChartText(text string) string {
+	text = strings.NewReplacer("≤", "<=", "≥", ">=", "€", "EUR").Replace(text)
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r > 126 {
+			return -1
+		}
+		return r
+	}, text))
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartPNG(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+
+	data, err := ChartPNG(&cfg.Analytics, prices, day)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, chartImageWidth, img.Bounds().Dx())
+	assert.Equal(t, chartImageHeight, img.Bounds().Dy())
+
+	// The middle of the 13:00 bar (0.00, low) must be green, of the 19:00 bar (0.18, normal) default blue.
+	layout := buildChartLayout(&cfg.Analytics, prices, day)
+	bar := layout.bars[19]
+	assert.Equal(t, parseChartColor(chartBarColor), color.RGBAModel.Convert(img.At(bar.x+bar.w/2, bar.y+bar.h/2)))
+	bar = layout.bars[12]
+	assert.Equal(t, parseChartColor("green"), color.RGBAModel.Convert(img.At(bar.x+bar.w/2, bar.y+bar.h/2)))
+}
+
+func TestChartSVG(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+
+	data, err := ChartSVG(&cfg.Analytics, prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, "EPEX NL 2025-02-28")
+	assert.Equal(t, 25, strings.Count(svg, "<rect "))
+	assert.Contains(t, svg, `fill="green"`)
+	assert.Contains(t, svg, ">-0.02</text>")
+	assert.Contains(t, svg, "🔴 High ≥ 0.20")
+	// Both thresholds are within the value range.
+	assert.Equal(t, 2, strings.Count(svg, "stroke-dasharray"))
+
+	// The colours are escaped even when they skip the config check.
+	cfg.Analytics.Tiers = PriceTiers{{Name: "normal", Color: `red"/><script>`, Label: "Normal"}}
+	data, err = ChartSVG(&cfg.Analytics, prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "<script>")
+	assert.Contains(t, string(data), `fill="red&#34;/&gt;&lt;script&gt;"`)
+}
+
+func TestBuildChartLayout(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+
+	layout := buildChartLayout(&cfg.Analytics, prices, time.Now())
+	require.Len(t, layout.bars, 24)
+	zeroBar := layout.bars[13]
+	negativeBar := layout.bars[14]
+	assert.Equal(t, 0, zeroBar.h)
+	assert.Equal(t, zeroBar.y, negativeBar.y)
+	assert.Greater(t, negativeBar.h, 0)
+	assert.Equal(t, "-0.05", layout.ticks[0].label)
+	assert.Equal(t, "0.20", layout.ticks[len(layout.ticks)-1].label)
+}
+
+func TestChartTickStep(t *testing.T) {
+	assert.Equal(t, 0.05, chartTickStep(0))
+	assert.InDelta(t, 0.05, chartTickStep(0.2), 1e-9)
+	assert.InDelta(t, 0.02, chartTickStep(0.1), 1e-9)
+	assert.InDelta(t, 50.0, chartTickStep(240), 1e-9)
+}
+
+func TestParseChartColor(t *testing.T) {
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, parseChartColor("red"))
+	assert.Equal(t, color.RGBA{0x54, 0x70, 0xc6, 255}, parseChartColor("#5470c6"))
+	assert.Equal(t, color.RGBA{0xff, 0xaa, 0x00, 255}, parseChartColor("#fa0"))
+	assert.Equal(t, parseChartColor(chartBarColor), parseChartColor("unknown"))
+	assert.Equal(t, parseChartColor(chartBarColor), parseChartColor("5470c6"))
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartText(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+
+	chart, err := ChartText(&cfg.Analytics, LocaleFor("en"), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	lines := strings.Split(FormatMarkdownV2.Format(Document{Blocks: []Block{chart}}), "\n")
+	require.Len(t, lines, 24)
+	assert.True(t, strings.HasPrefix(lines[0], "`00:00` "))
+	assert.True(t, strings.HasSuffix(lines[3], "█ 0\\.11"))
+	assert.True(t, strings.HasSuffix(lines[12], "█ 🟢 0\\.04"))
+	assert.True(t, strings.HasSuffix(lines[14], "█ 🟢 \\-0\\.02"))
+
+	chart, err = ChartText(&cfg.Analytics, LocaleFor("nl"), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	assert.Equal(t, "-0,02", chart.Rows[14].Value)
+}
+
+func TestChartHtml(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Analytics.Version = "v1"
+
+	html, err := ChartHtml(&cfg.Analytics, []decimal.Decimal{decimal.NewFromFloat(0.05), decimal.NewFromFloat(0.3)}, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "EPEX NL v1")
+	assert.Contains(t, string(html), "🔴 High ≥ 0.20")
+	assert.Contains(t, string(html), `"color":"green"`)
+}
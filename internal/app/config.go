@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/shopspring/decimal"
 )
 
@@ -15,6 +17,20 @@ const tomorrowHourMin = 15
 const loaderDriverStub = "stub"
 const loaderDriverEnergyZero = "energyzero"
 const messengerDriverTelegram = "telegram"
+const messengerDriverSlack = "slack"
+const messengerDriverEmail = "email"
+const messengerDriverWebhook = "webhook"
+const messengerDriverNtfy = "ntfy"
+const messengerDriverGotify = "gotify"
+const messengerDriverMatrix = "matrix"
+const messengerDriverConsole = "console"
+const messengerDriverFile = "file"
+const telegramUpdatesPolling = "polling"
+const telegramUpdatesWebhook = "webhook"
+const p1DriverTCP = "tcp"
+const p1DriverSerial = "serial"
+const storageDriverMemory = "memory"
+const storageDriverFile = "file"
 
 type ConfigAPI struct {
 	Endpoint string
@@ -24,37 +40,234 @@ type ConfigLoader struct {
 	InclBtw bool
 	Driver  string
 	API     ConfigAPI
+	// Revisions fetches today's and tomorrow's stored prices every hour to catch the corrections.
+	Revisions bool `default:"true"`
 }
 
 type ConfigServer struct {
 	Port string
+	// AdminToken is the bearer token of the /admin endpoints, disabled when empty.
+	AdminToken string
 }
 
 type ConfigTelegram struct {
-	Token  string
-	ChatID int64
+	Token       string
+	ChatID      int64
+	APIEndpoint string
+	// Updates enables the bot commands: "polling", "webhook" or empty to only send messages.
+	Updates string
+	// WebhookSecret is the path of the webhook and its X-Telegram-Bot-Api-Secret-Token header.
+	WebhookSecret string
+	// Workers handle the webhook updates, the updates over the queue are refused and sent again by Telegram.
+	Workers int `default:"4"`
+	// ParseMode of the messages: MarkdownV2 or HTML.
+	ParseMode string `default:"MarkdownV2"`
+}
+
+type ConfigSlack struct {
+	WebhookURL string
+}
+
+type ConfigEmail struct {
+	Host     string
+	Port     int `default:"587"`
+	Username string
+	Password string
+	// Security is starttls, tls (implicit TLS, usually port 465) or none.
+	Security      string `default:"starttls"`
+	TLSSkipVerify bool
+	From          string
+	To            []string
+	// Subject is the template with {title} replaced by the message title.
+	Subject string `default:"{title}"`
+}
+
+type ConfigWebhook struct {
+	URLs []string
+	// Secret signs the requests with HMAC-SHA256, unsigned when empty.
+	Secret string
+	// CloudEvents wraps the payload into the CloudEvents 1.0 envelope.
+	CloudEvents bool
+	Retries     int `default:"3"`
+}
+
+type ConfigNtfy struct {
+	URL   string `default:"https://ntfy.sh"`
+	Topic string
+	Token string
+	// Attachments uploads the charts, the server must have the attachments enabled.
+	Attachments bool
+}
+
+type ConfigGotify struct {
+	URL   string
+	Token string
+}
+
+type ConfigMatrix struct {
+	// HomeserverURL is the client-server API base, e.g. https://matrix.example.com.
+	HomeserverURL string
+	AccessToken   string
+	// RoomID is the internal ID of the room, e.g. !abcdef:example.com.
+	RoomID string
+	// Retries of a rate-limited request, waiting as long as the homeserver asks but not over MaxWait.
+	Retries int           `default:"2"`
+	MaxWait time.Duration `default:"30s"`
+}
+
+type ConfigConsole struct {
+	// Format of the printed text: plain, markdown (as Telegram MarkdownV2) or html.
+	Format string `default:"plain"`
+}
+
+type ConfigFile struct {
+	// Path of the directory the messages and their attachments are written to.
+	Path string `default:"messages"`
 }
 
 type ConfigMessenger struct {
-	Driver   string
+	Driver string
+	// Classes filters the messages by class, all of them when empty.
+	Classes  []string
 	Telegram ConfigTelegram
+	Slack    ConfigSlack
+	Email    ConfigEmail
+	Webhook  ConfigWebhook
+	Ntfy     ConfigNtfy
+	Gotify   ConfigGotify
+	Matrix   ConfigMatrix
+	Console  ConfigConsole
+	File     ConfigFile
+	// PublicURL of the server for the links to the charts, no links when empty.
+	PublicURL string
+	// EscalateAfter is the number of the failures in a row sending an admin message, never when 0.
+	// Only the main messenger's one is used.
+	EscalateAfter int `default:"3"`
+	// RetryEvery repeats the failed daily prices until the end of the day, never when 0.
+	// Only the main messenger's one is used.
+	RetryEvery time.Duration `default:"15m"`
+	// Destinations are more messengers, each with its own prefix: MESSENGER_<NAME>_DRIVER...
+	Destinations MessengerDestinations
+	// Name is the destination name, empty for the main messenger.
+	Name string `ignored:"true"`
+}
+
+type ConfigMQTT struct {
+	// Broker is tcp://host:1883 or ssl://host:8883, nothing is published when empty.
+	Broker   string
+	Username string
+	Password string
+	ClientID string `default:"day-ahead-prices"`
+	// Topic is the prefix of the state topics.
+	Topic string `default:"day-ahead-prices"`
+	// DiscoveryPrefix of the Home Assistant discovery configs, none are published when empty.
+	DiscoveryPrefix string `default:"homeassistant"`
+	// CheapWindows are the lengths in hours of the cheapest windows of the day flagged when active.
+	CheapWindows []int `default:"1,3"`
+}
+
+type ConfigTemplates struct {
+	// Path of the directory with <language>.tmpl files overriding the built-in templates.
+	Path string
+	// Language of the messages to the chats without their own, en, nl or uk.
+	Language string `default:"en"`
+}
+
+type ConfigTariff struct {
+	FixedPrice    decimal.Decimal
+	DynamicMarkup decimal.Decimal
+	EnergyTax     decimal.Decimal
+}
+
+type ConfigConsumption struct {
+	Delimiter    string `default:","`
+	TimeColumn   string `default:"timestamp"`
+	ValueColumn  string `default:"usage"`
+	TimeLayout   string `default:"2006-01-02 15:04"`
+	DecimalComma bool
+	Cumulative   bool
+}
+
+type ConfigP1 struct {
+	Driver  string
+	Address string
+	// AllowNoCRC accepts the DSMR 2/3 telegrams without the CRC, a truncated DSMR 4/5 one is accepted too then.
+	AllowNoCRC bool
+}
+
+type ConfigStorage struct {
+	Driver string `default:"memory"`
+	Path   string `default:"data"`
+}
+
+type ConfigOutbox struct {
+	// Enabled queues the notifications in the storage, otherwise they are sent at once.
+	// It needs the file storage, the memory one loses the queue and the sent keys on restart.
+	Enabled bool
+	// Attempts before the item is moved to the dead letters.
+	Attempts   int           `default:"8"`
+	Backoff    time.Duration `default:"30s"`
+	MaxBackoff time.Duration `default:"1h"`
+	Interval   time.Duration `default:"10s"`
+	// Retention of the sent keys, a message with the same key isn't sent again meanwhile.
+	Retention time.Duration `default:"168h"`
+}
+
+type ConfigQuiet struct {
+	// Urgent are the message classes and the alerts sent to the subscribers during their quiet hours.
+	Urgent []string `default:"negative"`
+}
+
+type ConfigForecast struct {
+	Enabled bool
+	Weeks   int `default:"4"`
+	Days    int `default:"7"`
+}
+
+type ConfigReports struct {
+	Weekly  bool
+	Monthly bool
+	Time    string `default:"09:00"`
 }
 
 type ConfigAnalytics struct {
 	HighPrice decimal.Decimal
 	LowPrice  decimal.Decimal
+	Tiers     PriceTiers
 	Version   string
 }
 
 // Config struct to hold environment variables
 type ConfigApp struct {
-	Analytics ConfigAnalytics
-	Loader    ConfigLoader
-	Server    ConfigServer
-	Messenger ConfigMessenger
+	Analytics   ConfigAnalytics
+	Loader      ConfigLoader
+	Server      ConfigServer
+	Messenger   ConfigMessenger
+	Tariff      ConfigTariff
+	Consumption ConfigConsumption
+	P1          ConfigP1
+	Storage     ConfigStorage
+	Forecast    ConfigForecast
+	Reports     ConfigReports
+	MQTT        ConfigMQTT
+	Templates   ConfigTemplates
+	Outbox      ConfigOutbox
+	Quiet       ConfigQuiet
+
+	locationOnce  sync.Once
+	location      *time.Location
+	storageOnce   sync.Once
+	storage       Storage
+	history       *PriceHistory
+	subscriptions *Subscriptions
+	outbox        *Outbox
 
-	locationOnce sync.Once
-	location     *time.Location
+	dispatcherOnce sync.Once
+	dispatcher     *Dispatcher
+	dispatcherErr  error
+
+	templatesMu sync.Mutex
+	templates   map[string]*MessageTemplates
 }
 
 func (cfg *ConfigApp) Location() *time.Location {
@@ -70,10 +283,168 @@ func (cfg *ConfigApp) Location() *time.Location {
 	return cfg.location
 }
 
+func (cfg *ConfigApp) Store() Storage {
+	cfg.storageOnce.Do(
+		func() {
+			var err error
+			cfg.storage, err = NewStorage(&cfg.Storage)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cfg.history = NewPriceHistory(cfg.storage, cfg.Location())
+			cfg.subscriptions = NewSubscriptions(cfg.storage)
+			cfg.outbox = NewOutbox(cfg.storage, &cfg.Outbox, &cfg.Messenger, cfg.Dispatcher,
+				func(item OutboxItem, cause error, dead bool, now time.Time) {
+					reportDelivery(cfg, item, cause, dead, now)
+				},
+			)
+		},
+	)
+	return cfg.storage
+}
+
+func (cfg *ConfigApp) History() *PriceHistory {
+	cfg.Store()
+	return cfg.history
+}
+
+func (cfg *ConfigApp) Subscriptions() *Subscriptions {
+	cfg.Store()
+	return cfg.subscriptions
+}
+
+// Dispatcher returns the main messenger and the destinations, they are created once.
+func (cfg *ConfigApp) Dispatcher() (*Dispatcher, error) {
+	cfg.dispatcherOnce.Do(func() {
+		cfg.dispatcher, cfg.dispatcherErr = NewDispatcher(&cfg.Messenger)
+	})
+	return cfg.dispatcher, cfg.dispatcherErr
+}
+
+// Queue returns the notification outbox.
+func (cfg *ConfigApp) Queue() *Outbox {
+	cfg.Store()
+	return cfg.outbox
+}
+
 func (cfg *ConfigApp) TomorrowHourMin() int {
 	return tomorrowHourMin
 }
 
+func (cfg *ConfigMessenger) selfCheck() error {
+	prefix := cfg.prefix()
+	if cfg.Driver == messengerDriverTelegram {
+		if cfg.Telegram.Token == "" {
+			return fmt.Errorf("%s_TELEGRAM_TOKEN not set", prefix)
+		}
+		if cfg.Telegram.ChatID == 0 {
+			return fmt.Errorf("%s_TELEGRAM_CHATID not set", prefix)
+		}
+		if cfg.Telegram.ParseMode != "" && cfg.Telegram.ParseMode != tgbotapi.ModeMarkdownV2 && cfg.Telegram.ParseMode != tgbotapi.ModeHTML {
+			return fmt.Errorf("unknown %s_TELEGRAM_PARSEMODE: %s", prefix, cfg.Telegram.ParseMode)
+		}
+	} else if cfg.Driver == messengerDriverSlack {
+		if cfg.Slack.WebhookURL == "" {
+			return fmt.Errorf("%s_SLACK_WEBHOOKURL not set", prefix)
+		}
+	} else if cfg.Driver == messengerDriverEmail {
+		if cfg.Email.Host == "" {
+			return fmt.Errorf("%s_EMAIL_HOST not set", prefix)
+		}
+		if cfg.Email.From == "" {
+			return fmt.Errorf("%s_EMAIL_FROM not set", prefix)
+		}
+		if len(cfg.Email.To) == 0 {
+			return fmt.Errorf("%s_EMAIL_TO not set", prefix)
+		}
+		if !slices.Contains([]string{emailSecurityNone, emailSecuritySTARTTLS, emailSecurityTLS}, cfg.Email.Security) {
+			return fmt.Errorf("unknown %s_EMAIL_SECURITY: %s", prefix, cfg.Email.Security)
+		}
+	} else if cfg.Driver == messengerDriverWebhook {
+		if len(cfg.Webhook.URLs) == 0 {
+			return fmt.Errorf("%s_WEBHOOK_URLS not set", prefix)
+		}
+		if cfg.Webhook.Retries < 0 {
+			return fmt.Errorf("%s_WEBHOOK_RETRIES must not be negative", prefix)
+		}
+	} else if cfg.Driver == messengerDriverNtfy {
+		if cfg.Ntfy.URL == "" {
+			return fmt.Errorf("%s_NTFY_URL not set", prefix)
+		}
+		if cfg.Ntfy.Topic == "" {
+			return fmt.Errorf("%s_NTFY_TOPIC not set", prefix)
+		}
+	} else if cfg.Driver == messengerDriverGotify {
+		if cfg.Gotify.URL == "" {
+			return fmt.Errorf("%s_GOTIFY_URL not set", prefix)
+		}
+		if cfg.Gotify.Token == "" {
+			return fmt.Errorf("%s_GOTIFY_TOKEN not set", prefix)
+		}
+	} else if cfg.Driver == messengerDriverMatrix {
+		if cfg.Matrix.HomeserverURL == "" {
+			return fmt.Errorf("%s_MATRIX_HOMESERVERURL not set", prefix)
+		}
+		if cfg.Matrix.AccessToken == "" {
+			return fmt.Errorf("%s_MATRIX_ACCESSTOKEN not set", prefix)
+		}
+		if cfg.Matrix.RoomID == "" {
+			return fmt.Errorf("%s_MATRIX_ROOMID not set", prefix)
+		}
+		if cfg.Matrix.Retries < 0 {
+			return fmt.Errorf("%s_MATRIX_RETRIES must not be negative", prefix)
+		}
+	} else if cfg.Driver == messengerDriverConsole {
+		if _, ok := consoleFormats[cfg.Console.Format]; !ok {
+			return fmt.Errorf("unknown %s_CONSOLE_FORMAT: %s", prefix, cfg.Console.Format)
+		}
+	} else if cfg.Driver == messengerDriverFile {
+		if cfg.File.Path == "" {
+			return fmt.Errorf("%s_FILE_PATH not set", prefix)
+		}
+	} else if cfg.Driver == "" {
+		return fmt.Errorf("%s_DRIVER not set", prefix)
+	} else {
+		return fmt.Errorf("unknown %s_DRIVER: %s", prefix, cfg.Driver)
+	}
+
+	for _, class := range cfg.Classes {
+		if !slices.Contains(messageClasses, class) {
+			return fmt.Errorf("unknown %s_CLASSES item: %s", prefix, class)
+		}
+	}
+	return nil
+}
+
+func (cfg *ConfigStorage) selfCheck() error {
+	if cfg.Driver == storageDriverFile {
+		if cfg.Path == "" {
+			return errors.New("STORAGE_PATH not set")
+		}
+	} else if cfg.Driver == "" {
+		return errors.New("STORAGE_DRIVER not set")
+	} else if cfg.Driver != storageDriverMemory {
+		return fmt.Errorf("unknown STORAGE_DRIVER: %s", cfg.Driver)
+	}
+	return nil
+}
+
+// BacktestSelfCheck checks only what the offline backtest needs: the stored history and the forecast,
+// the messenger isn't used. The history survives the server runs only in the file storage.
+func (cfg *ConfigApp) BacktestSelfCheck() error {
+	if err := cfg.Storage.selfCheck(); err != nil {
+		return err
+	}
+	if cfg.Storage.Driver != storageDriverFile {
+		return fmt.Errorf("backtest needs the price history of STORAGE_DRIVER=file, not %s", cfg.Storage.Driver)
+	}
+	if cfg.Forecast.Weeks < 1 || cfg.Forecast.Days < 1 {
+		return errors.New("FORECAST_WEEKS and FORECAST_DAYS must be positive")
+	}
+	cfg.Location()
+	return nil
+}
+
 func (cfg *ConfigApp) SelfCheck() error {
 
 	if cfg.Analytics.HighPrice.IsZero() {
@@ -99,17 +470,115 @@ func (cfg *ConfigApp) SelfCheck() error {
 		return errors.New("SERVER_PORT not set")
 	}
 
-	if cfg.Messenger.Driver == messengerDriverTelegram {
-		if cfg.Messenger.Telegram.Token == "" {
-			return errors.New("MESSENGER_TELEGRAM_TOKEN not set")
+	if err := cfg.Messenger.selfCheck(); err != nil {
+		return err
+	}
+	if cfg.Messenger.EscalateAfter < 0 {
+		return errors.New("MESSENGER_ESCALATEAFTER must not be negative")
+	}
+	if cfg.Messenger.RetryEvery < 0 {
+		return errors.New("MESSENGER_RETRYEVERY must not be negative")
+	}
+	if cfg.Messenger.Telegram.Updates == telegramUpdatesWebhook {
+		if cfg.Messenger.PublicURL == "" {
+			return errors.New("MESSENGER_TELEGRAM_UPDATES=webhook requires MESSENGER_PUBLICURL")
 		}
-		if cfg.Messenger.Telegram.ChatID == 0 {
-			return errors.New("MESSENGER_TELEGRAM_CHATID not set")
+		if !webhookSecretRegexp.MatchString(cfg.Messenger.Telegram.WebhookSecret) {
+			return errors.New("MESSENGER_TELEGRAM_WEBHOOKSECRET must be 1-256 characters A-Z, a-z, 0-9, _ or -")
+		}
+		if cfg.Messenger.Telegram.Workers < 1 {
+			return errors.New("MESSENGER_TELEGRAM_WORKERS must be positive")
+		}
+	}
+	if cfg.Messenger.Telegram.Updates != "" {
+		if cfg.Messenger.Telegram.Updates != telegramUpdatesPolling && cfg.Messenger.Telegram.Updates != telegramUpdatesWebhook {
+			return fmt.Errorf("unknown MESSENGER_TELEGRAM_UPDATES: %s", cfg.Messenger.Telegram.Updates)
+		}
+		if cfg.Messenger.Driver != messengerDriverTelegram {
+			return errors.New("MESSENGER_TELEGRAM_UPDATES requires MESSENGER_DRIVER=telegram")
+		}
+		// The bot commands are the only way to subscribe.
+		if cfg.Storage.Driver != storageDriverFile {
+			return fmt.Errorf("MESSENGER_TELEGRAM_UPDATES needs STORAGE_DRIVER=file, %s storage loses the subscriptions and the held digests on restart", cfg.Storage.Driver)
+		}
+	}
+	for i := range cfg.Messenger.Destinations {
+		if err := cfg.Messenger.Destinations[i].selfCheck(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Tariff.FixedPrice.IsNegative() {
+		return errors.New("TARIFF_FIXEDPRICE must not be negative")
+	}
+	if len([]rune(cfg.Consumption.Delimiter)) != 1 {
+		return errors.New("CONSUMPTION_DELIMITER must be a single character")
+	}
+
+	if cfg.P1.Driver == p1DriverTCP || cfg.P1.Driver == p1DriverSerial {
+		if cfg.P1.Address == "" {
+			return errors.New("P1_ADDRESS not set")
+		}
+	} else if cfg.P1.Driver != "" {
+		return fmt.Errorf("unknown P1_DRIVER: %s", cfg.P1.Driver)
+	}
+
+	if err := cfg.Storage.selfCheck(); err != nil {
+		return err
+	}
+
+	if cfg.Forecast.Enabled && (cfg.Forecast.Weeks < 1 || cfg.Forecast.Days < 1) {
+		return errors.New("FORECAST_WEEKS and FORECAST_DAYS must be positive")
+	}
+
+	if cfg.Reports.Weekly || cfg.Reports.Monthly {
+		if _, _, err := parseClock(cfg.Reports.Time); err != nil {
+			return fmt.Errorf("invalid REPORTS_TIME: %w", err)
+		}
+	}
+
+	if !slices.Contains(languages, cfg.Templates.Language) {
+		return fmt.Errorf("unknown TEMPLATES_LANGUAGE: %s", cfg.Templates.Language)
+	}
+	for _, language := range languages {
+		if _, err := cfg.MessageTemplates(language).parse(); err != nil {
+			return fmt.Errorf("invalid TEMPLATES_PATH: %w", err)
+		}
+	}
+
+	if cfg.MQTT.Broker != "" {
+		if _, _, err := mqttAddress(cfg.MQTT.Broker); err != nil {
+			return fmt.Errorf("invalid MQTT_BROKER: %w", err)
+		}
+		if cfg.MQTT.Topic == "" {
+			return errors.New("MQTT_TOPIC not set")
+		}
+		for _, hours := range cfg.MQTT.CheapWindows {
+			if hours < 1 || hours > 24 {
+				return fmt.Errorf("MQTT_CHEAPWINDOWS item must be from 1 to 24: %d", hours)
+			}
+		}
+	}
+
+	if cfg.Outbox.Enabled {
+		if cfg.Storage.Driver != storageDriverFile {
+			return fmt.Errorf("OUTBOX_ENABLED needs STORAGE_DRIVER=file, %s storage loses the queue on restart", cfg.Storage.Driver)
+		}
+		if cfg.Outbox.Attempts < 1 {
+			return errors.New("OUTBOX_ATTEMPTS must be positive")
+		}
+		if cfg.Outbox.Backoff <= 0 || cfg.Outbox.MaxBackoff < cfg.Outbox.Backoff {
+			return errors.New("OUTBOX_BACKOFF must be positive and not above OUTBOX_MAXBACKOFF")
+		}
+		if cfg.Outbox.Interval <= 0 {
+			return errors.New("OUTBOX_INTERVAL must be positive")
+		}
+	}
+
+	for _, urgent := range cfg.Quiet.Urgent {
+		if !slices.Contains(messageClasses, urgent) && !slices.Contains(subscriptionAlerts, urgent) {
+			return fmt.Errorf("unknown QUIET_URGENT item: %s", urgent)
 		}
-	} else if cfg.Messenger.Driver == "" {
-		return errors.New("MESSENGER_DRIVER not set")
-	} else {
-		return fmt.Errorf("unknown MESSENGER_DRIVER: %s", cfg.Messenger.Driver)
 	}
 
 	cfg.Location()
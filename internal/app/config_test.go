@@ -33,6 +33,16 @@ func TestConfigExample(t *testing.T) {
 	}
 }
 
+func TestConfigSelfCheck_Consumption(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Consumption.Delimiter = ";;"
+	assert.Error(t, cfg.SelfCheck())
+
+	cfg = generateTestConfig()
+	cfg.Tariff.FixedPrice = decimal.NewFromFloat(-0.1)
+	assert.Error(t, cfg.SelfCheck())
+}
+
 func TestLocation(t *testing.T) {
 	cfg := generateTestConfig()
 
@@ -46,6 +56,97 @@ func TestTomorrowHourMin(t *testing.T) {
 	assert.Equal(t, 15, cfg.TomorrowHourMin())
 }
 
+func TestConfigSelfCheck_TelegramParseMode(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.ParseMode = "HTML"
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.Telegram.ParseMode = "Markdown"
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_TelegramUpdates(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.Updates = "polling"
+	assert.EqualError(t, cfg.SelfCheck(), "MESSENGER_TELEGRAM_UPDATES needs STORAGE_DRIVER=file, memory storage loses the subscriptions and the held digests on restart")
+	cfg.Storage = ConfigStorage{Driver: "file", Path: t.TempDir()}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.Telegram.Updates = "push"
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_TelegramWebhook(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.Updates = "webhook"
+	cfg.Messenger.Telegram.WebhookSecret = "secret_token-1"
+	cfg.Messenger.Telegram.Workers = 4
+	cfg.Storage = ConfigStorage{Driver: "file", Path: t.TempDir()}
+	assert.Error(t, cfg.SelfCheck())
+	cfg.Messenger.PublicURL = "https://prices.example.com"
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.Telegram.WebhookSecret = "not/a secret"
+	assert.Error(t, cfg.SelfCheck())
+	cfg.Messenger.Telegram.WebhookSecret = "secret"
+	cfg.Messenger.Telegram.Workers = 0
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_MQTT(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.MQTT = ConfigMQTT{Broker: "tcp://localhost:1883", Topic: "prices", CheapWindows: []int{1, 3}}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.MQTT.Broker = "http://localhost"
+	assert.Error(t, cfg.SelfCheck())
+	cfg.MQTT.Broker = "ssl://localhost"
+	cfg.MQTT.CheapWindows = []int{25}
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_EscalateAfter(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.EscalateAfter = 3
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.EscalateAfter = -1
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_Quiet(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Quiet.Urgent = []string{AlertNegative, MessageClassError}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Quiet.Urgent = []string{"storm"}
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_Templates(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Templates.Language = "uk"
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Templates.Language = "fr"
+	assert.Error(t, cfg.SelfCheck())
+}
+
+func TestConfigSelfCheck_Outbox(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Outbox = ConfigOutbox{Enabled: true, Attempts: 8, Backoff: time.Second, MaxBackoff: time.Minute, Interval: time.Second}
+	assert.EqualError(t, cfg.SelfCheck(), "OUTBOX_ENABLED needs STORAGE_DRIVER=file, memory storage loses the queue on restart")
+	cfg.Storage = ConfigStorage{Driver: "file", Path: t.TempDir()}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Outbox.MaxBackoff = time.Millisecond
+	assert.Error(t, cfg.SelfCheck())
+	cfg.Outbox.Enabled = false
+	assert.NoError(t, cfg.SelfCheck())
+}
+
+func TestConfigBacktestSelfCheck(t *testing.T) {
+	cfg := &ConfigApp{Storage: ConfigStorage{Driver: "memory"}, Forecast: ConfigForecast{Weeks: 4, Days: 7}}
+	assert.Error(t, cfg.BacktestSelfCheck())
+	// The messenger isn't checked.
+	cfg.Storage = ConfigStorage{Driver: "file", Path: "data"}
+	assert.NoError(t, cfg.BacktestSelfCheck())
+	cfg.Forecast.Weeks = 0
+	assert.Error(t, cfg.BacktestSelfCheck())
+}
+
 func generateTestConfig() *ConfigApp {
 	return &ConfigApp{
 		Analytics: ConfigAnalytics{
@@ -69,5 +170,32 @@ func generateTestConfig() *ConfigApp {
 				ChatID: 123,
 			},
 		},
+		Tariff: ConfigTariff{
+			FixedPrice:    decimal.NewFromFloat(0.25),
+			DynamicMarkup: decimal.NewFromFloat(0.02),
+			EnergyTax:     decimal.NewFromFloat(0.1),
+		},
+		Consumption: ConfigConsumption{
+			Delimiter:   ",",
+			TimeColumn:  "timestamp",
+			ValueColumn: "usage",
+			TimeLayout:  "2006-01-02 15:04",
+		},
+		Storage: ConfigStorage{
+			Driver: "memory",
+		},
+		Forecast: ConfigForecast{
+			Enabled: true,
+			Weeks:   4,
+			Days:    7,
+		},
+		Reports: ConfigReports{
+			Weekly:  true,
+			Monthly: true,
+			Time:    "09:00",
+		},
+		Templates: ConfigTemplates{
+			Language: "en",
+		},
 	}
 }
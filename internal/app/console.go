@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+var consoleFormats = map[string]Formatter{"plain": FormatPlain, "markdown": FormatMarkdownV2, "html": FormatHTML}
+
+// consoleMu keeps the messages of several console destinations apart.
+var consoleMu sync.Mutex
+
+// consoleMessenger prints the rendered messages for the dry runs,
+// the attachments and the keyboard are listed under the text.
+type consoleMessenger struct {
+	cfg *ConfigConsole
+	out io.Writer
+}
+
+func (messenger *consoleMessenger) Send(message Message) error {
+	class := message.Class
+	if class == "" {
+		class = "message"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s ---\n", class)
+	b.WriteString(strings.TrimSuffix(consoleFormats[messenger.cfg.Format].Format(message.Document), "\n") + "\n")
+	for _, attachment := range message.Attachments {
+		fmt.Fprintf(&b, "[%s %s, %s, %d bytes]\n", attachment.Kind, attachment.Name, attachment.MimeType, len(attachment.Data))
+	}
+	for _, row := range message.Keyboard {
+		labels := make([]string, len(row))
+		for i, button := range row {
+			labels[i] = button.Text
+		}
+		fmt.Fprintf(&b, "[ %s ]\n", strings.Join(labels, " | "))
+	}
+
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	_, err := io.WriteString(messenger.out, b.String())
+	return err
+}
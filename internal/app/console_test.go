@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// assertGolden compares the output with testdata/<name>, go test -run ... -update rewrites it.
+func assertGolden(t *testing.T, name string, actual []byte) {
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestConsoleMessenger(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, day)
+	require.NoError(t, err)
+	message.Keyboard, err = DayKeyboard(&cfg.Messenger, cfg.MessageTemplates(""), day)
+	require.NoError(t, err)
+	// The PNG size depends on the fonts and the encoder.
+	message.Attachments[0].Data = []byte("png")
+
+	var out bytes.Buffer
+	messenger := &consoleMessenger{cfg: &ConfigConsole{Format: "plain"}, out: &out}
+	require.NoError(t, messenger.Send(message))
+	require.NoError(t, messenger.Send(Message{Document: TextDocument("No prices for 2025-03-01")}))
+	assertGolden(t, "console_day.golden", out.Bytes())
+
+	out.Reset()
+	messenger.cfg.Format = "markdown"
+	require.NoError(t, messenger.Send(Message{Class: MessageClassError, Document: TextDocument("Error for 2025-03-01")}))
+	assert.Equal(t, "--- error ---\nError for 2025\\-03\\-01\n", out.String())
+}
+
+func TestConfigSelfCheck_Console(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger = ConfigMessenger{Driver: messengerDriverConsole, Console: ConfigConsole{Format: "html"}}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.Console.Format = "rtf"
+	assert.Error(t, cfg.SelfCheck())
+
+	cfg.Messenger = ConfigMessenger{Driver: messengerDriverFile, File: ConfigFile{Path: "messages"}}
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.File.Path = ""
+	assert.Error(t, cfg.SelfCheck())
+}
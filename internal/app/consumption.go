@@ -0,0 +1,111 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+var ErrNoConsumption = errors.New("no consumption entries")
+
+// ParseConsumptionCSV reads a consumption profile (hourly or quarter-hourly) from CSV.
+// Columns are matched by the header name or, if the mapping is a number, by the zero-based index.
+func ParseConsumptionCSV(cfg *ConfigConsumption, r io.Reader, location *time.Location) (res []models.ConsumptionEntry, err error) {
+	reader := csv.NewReader(r)
+	reader.Comma = []rune(cfg.Delimiter)[0]
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		err = fmt.Errorf("failed to read CSV header: %w", err)
+		return
+	}
+	timeIndex, err := columnIndex(header, cfg.TimeColumn)
+	if err != nil {
+		return
+	}
+	valueIndex, err := columnIndex(header, cfg.ValueColumn)
+	if err != nil {
+		return
+	}
+
+	for line := 2; ; line++ {
+		record, errRead := reader.Read()
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			err = fmt.Errorf("failed to read CSV line %d: %w", line, errRead)
+			return
+		}
+		if timeIndex >= len(record) || valueIndex >= len(record) {
+			err = fmt.Errorf("CSV line %d: not enough columns", line)
+			return
+		}
+
+		entry := models.ConsumptionEntry{}
+		entry.Start, err = time.ParseInLocation(cfg.TimeLayout, strings.TrimSpace(record[timeIndex]), location)
+		if err != nil {
+			err = fmt.Errorf("CSV line %d: invalid time: %w", line, err)
+			return
+		}
+		value := strings.TrimSpace(record[valueIndex])
+		if cfg.DecimalComma {
+			value = strings.Replace(strings.Replace(value, ".", "", -1), ",", ".", 1)
+		}
+		entry.Usage, err = decimal.NewFromString(value)
+		if err != nil {
+			err = fmt.Errorf("CSV line %d: invalid value: %w", line, err)
+			return
+		}
+		res = append(res, entry)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Start.Before(res[j].Start) })
+	if cfg.Cumulative {
+		res = cumulativeToUsage(res)
+	}
+	if len(res) == 0 {
+		err = ErrNoConsumption
+		return
+	}
+
+	return
+}
+
+func columnIndex(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(column); err == nil && i >= 0 && i < len(header) {
+		return i, nil
+	}
+	return 0, fmt.Errorf("CSV column %q not found", column)
+}
+
+// cumulativeToUsage converts meter readings into the usage of each interval,
+// so the first reading is used as a base only.
+func cumulativeToUsage(readings []models.ConsumptionEntry) []models.ConsumptionEntry {
+	if len(readings) < 2 {
+		return nil
+	}
+	res := make([]models.ConsumptionEntry, len(readings)-1)
+	for i := 1; i < len(readings); i++ {
+		res[i-1] = models.ConsumptionEntry{
+			Start: readings[i-1].Start,
+			Usage: readings[i].Usage.Sub(readings[i-1].Usage),
+		}
+	}
+	return res
+}
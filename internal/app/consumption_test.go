@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConsumptionCSV(t *testing.T) {
+	cfg := generateTestConfig()
+	csv := "timestamp,usage\n2025-02-28 01:00,0.5\n2025-02-28 00:00,1.25\n"
+
+	res, err := ParseConsumptionCSV(&cfg.Consumption, strings.NewReader(csv), cfg.Location())
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, 0, res[0].Start.Hour())
+	assert.Equal(t, "1.25", res[0].Usage.String())
+	assert.Equal(t, "0.5", res[1].Usage.String())
+}
+
+func TestParseConsumptionCSV_Mapping(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Consumption.Delimiter = ";"
+	cfg.Consumption.TimeColumn = "0"
+	cfg.Consumption.ValueColumn = "Levering"
+	cfg.Consumption.TimeLayout = "02-01-2006 15:04"
+	cfg.Consumption.DecimalComma = true
+	cfg.Consumption.Cumulative = true
+	csv := "Datum;Levering\n28-02-2025 00:00;1.000,5\n28-02-2025 00:15;1.000,75\n28-02-2025 00:30;1.001,25\n"
+
+	res, err := ParseConsumptionCSV(&cfg.Consumption, strings.NewReader(csv), cfg.Location())
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "0.25", res[0].Usage.String())
+	assert.Equal(t, 15, res[1].Start.Minute())
+	assert.Equal(t, "0.5", res[1].Usage.String())
+}
+
+func TestParseConsumptionCSV_Error(t *testing.T) {
+	cfg := generateTestConfig()
+
+	_, err := ParseConsumptionCSV(&cfg.Consumption, strings.NewReader("time,value\n"), cfg.Location())
+	assert.Error(t, err)
+
+	_, err = ParseConsumptionCSV(&cfg.Consumption, strings.NewReader("timestamp,usage\n"), cfg.Location())
+	assert.True(t, errors.Is(err, ErrNoConsumption))
+
+	_, err = ParseConsumptionCSV(&cfg.Consumption, strings.NewReader("timestamp,usage\nyesterday,1\n"), cfg.Location())
+	assert.Error(t, err)
+
+	_, err = ParseConsumptionCSV(&cfg.Consumption, strings.NewReader("timestamp,usage\n2025-02-28 00:00,a lot\n"), cfg.Location())
+	assert.Error(t, err)
+}
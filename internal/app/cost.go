@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+type priceFetcher func(day time.Time) ([]decimal.Decimal, error)
+
+// CalculateCost compares the cost of the consumption profile under day-ahead dynamic prices
+// with the fixed tariff, per day and in total.
+func CalculateCost(cfg *ConfigApp, consumption []models.ConsumptionEntry) (models.CostReport, error) {
+	return calculateCost(&cfg.Tariff, consumption, cfg.Location(), func(day time.Time) ([]decimal.Decimal, error) {
+		return LoadPrices(cfg, day)
+	})
+}
+
+func calculateCost(
+	cfg *ConfigTariff, consumption []models.ConsumptionEntry, location *time.Location, fetch priceFetcher,
+) (report models.CostReport, err error) {
+	report.Total.Date = "total"
+	if len(consumption) == 0 {
+		err = ErrNoConsumption
+		return
+	}
+
+	var day models.CostReportDay
+	var dayStart time.Time
+	var prices []decimal.Decimal
+	for _, entry := range consumption {
+		start := entry.Start.In(location)
+		if prices == nil || !sameDay(start, dayStart) {
+			if prices != nil {
+				report.Add(roundCostDay(day))
+			}
+			dayStart = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+			day = models.CostReportDay{Date: dayStart.Format("2006-01-02")}
+			if prices, err = fetch(dayStart); err != nil {
+				err = fmt.Errorf("failed to fetch prices for %s: %w", day.Date, err)
+				return
+			}
+		}
+
+		// Slots are counted from the start of the day, so DST days get 23 or 25 of them.
+		slot := int(start.Sub(dayStart) / time.Hour)
+		if slot >= len(prices) {
+			err = fmt.Errorf("no price for %s", start.Format("2006-01-02 15:04"))
+			return
+		}
+
+		dynamicPrice := prices[slot].Add(cfg.DynamicMarkup).Add(cfg.EnergyTax)
+		fixedPrice := cfg.FixedPrice.Add(cfg.EnergyTax)
+		day.Usage = day.Usage.Add(entry.Usage)
+		day.DynamicCost = day.DynamicCost.Add(entry.Usage.Mul(dynamicPrice))
+		day.FixedCost = day.FixedCost.Add(entry.Usage.Mul(fixedPrice))
+	}
+	report.Add(roundCostDay(day))
+	report.Total = roundCostDay(report.Total)
+
+	return
+}
+
+func roundCostDay(day models.CostReportDay) models.CostReportDay {
+	day.Usage = day.Usage.Round(3)
+	day.DynamicCost = day.DynamicCost.Round(2)
+	day.FixedCost = day.FixedCost.Round(2)
+	day.Difference = day.DynamicCost.Sub(day.FixedCost)
+	return day
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
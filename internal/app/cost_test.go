@@ -0,0 +1,59 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateCost(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	consumption := []models.ConsumptionEntry{
+		{Start: day, Usage: decimal.NewFromFloat(1)},
+		{Start: day.Add(14 * time.Hour), Usage: decimal.NewFromFloat(2)},
+		{Start: day.AddDate(0, 0, 1), Usage: decimal.NewFromFloat(1)},
+	}
+
+	report, err := CalculateCost(cfg, consumption)
+	require.NoError(t, err)
+	require.Len(t, report.Days, 2)
+
+	// 1 * (0.15 + 0.12) + 2 * (-0.02 + 0.12) = 0.47
+	assert.Equal(t, "2025-02-28", report.Days[0].Date)
+	assert.Equal(t, "3", report.Days[0].Usage.String())
+	assert.Equal(t, "0.47", report.Days[0].DynamicCost.String())
+	assert.Equal(t, "1.05", report.Days[0].FixedCost.String())
+	assert.Equal(t, "-0.58", report.Days[0].Difference.String())
+
+	assert.Equal(t, "2025-03-01", report.Days[1].Date)
+	assert.Equal(t, "4", report.Total.Usage.String())
+	assert.Equal(t, "0.74", report.Total.DynamicCost.String())
+	assert.Equal(t, "1.4", report.Total.FixedCost.String())
+}
+
+func TestCalculateCost_Error(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	consumption := []models.ConsumptionEntry{{Start: day.Add(30 * time.Hour), Usage: decimal.NewFromFloat(1)}}
+
+	_, err := calculateCost(&cfg.Tariff, nil, cfg.Location(), nil)
+	assert.True(t, errors.Is(err, ErrNoConsumption))
+
+	_, err = calculateCost(&cfg.Tariff, consumption, cfg.Location(), func(time.Time) ([]decimal.Decimal, error) {
+		return nil, errors.New("fetch failed")
+	})
+	assert.Error(t, err)
+
+	consumption[0].Start = day.Add(2 * time.Hour)
+	_, err = calculateCost(&cfg.Tariff, consumption, cfg.Location(), func(time.Time) ([]decimal.Decimal, error) {
+		return []decimal.Decimal{decimal.NewFromFloat(0.1)}, nil
+	})
+	assert.Error(t, err)
+}
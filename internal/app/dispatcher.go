@@ -0,0 +1,272 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+const mainDestination = "main"
+
+var destinationNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// Messenger sends the messages to a destination.
+type Messenger interface {
+	Send(message Message) error
+}
+
+func NewMessenger(cfg *ConfigMessenger) (Messenger, error) {
+	switch cfg.Driver {
+	case messengerDriverTelegram:
+		return &telegramMessenger{cfg: &cfg.Telegram}, nil
+	case messengerDriverSlack:
+		return &slackMessenger{cfg: &cfg.Slack}, nil
+	case messengerDriverEmail:
+		return &emailMessenger{cfg: &cfg.Email, now: time.Now}, nil
+	case messengerDriverWebhook:
+		return &webhookMessenger{cfg: &cfg.Webhook, now: time.Now, backoff: webhookBackoff}, nil
+	case messengerDriverNtfy:
+		return &ntfyMessenger{cfg: &cfg.Ntfy, publicURL: cfg.PublicURL}, nil
+	case messengerDriverGotify:
+		return &gotifyMessenger{cfg: &cfg.Gotify, publicURL: cfg.PublicURL}, nil
+	case messengerDriverMatrix:
+		return &matrixMessenger{cfg: &cfg.Matrix}, nil
+	case messengerDriverConsole:
+		return &consoleMessenger{cfg: &cfg.Console, out: os.Stdout}, nil
+	case messengerDriverFile:
+		return &fileMessenger{cfg: &cfg.File}, nil
+	default:
+		return nil, ErrUnknownMessengerDriver
+	}
+}
+
+// Editor is a messenger able to replace the content of the messages it sent.
+// SendReceipt resumes the interrupted send of the message: the parts in the receipt aren't sent again,
+// the returned receipt has the parts sent before the error too.
+type Editor interface {
+	SendReceipt(message Message, resume Receipt) (Receipt, error)
+	Edit(receipt Receipt, message Message) error
+}
+
+type telegramMessenger struct {
+	cfg *ConfigTelegram
+}
+
+func (messenger *telegramMessenger) Send(message Message) error {
+	_, err := sendTelegram(messenger.cfg, message, Receipt{})
+	return err
+}
+
+func (messenger *telegramMessenger) SendReceipt(message Message, resume Receipt) (Receipt, error) {
+	return sendTelegram(messenger.cfg, message, resume)
+}
+
+func (messenger *telegramMessenger) Edit(receipt Receipt, message Message) error {
+	client, err := newTelegramClient(messenger.cfg)
+	if err != nil {
+		return err
+	}
+	return editTelegramMessage(client, receipt, messenger.cfg.ParseMode, telegramBotMessage(messenger.cfg, message))
+}
+
+// MessengerDestinations are decoded from the names "ops,team" (MESSENGER_DESTINATIONS),
+// every destination is configured as the main messenger with its prefix: MESSENGER_OPS_DRIVER, MESSENGER_OPS_CLASSES...
+type MessengerDestinations []ConfigMessenger
+
+func (destinations *MessengerDestinations) Decode(value string) error {
+	var res MessengerDestinations
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !destinationNameRegexp.MatchString(name) || name == mainDestination {
+			return fmt.Errorf("invalid destination name %q", name)
+		}
+		destination := ConfigMessenger{Name: name}
+		if err := envconfig.Process(destination.prefix(), &destination); err != nil {
+			return fmt.Errorf("invalid destination %s: %w", name, err)
+		}
+		if len(destination.Destinations) > 0 {
+			return fmt.Errorf("destination %s can't have destinations", name)
+		}
+		res = append(res, destination)
+	}
+	*destinations = res
+	return nil
+}
+
+// prefix returns the environment variables prefix of the messenger.
+func (cfg *ConfigMessenger) prefix() string {
+	if cfg.Name == "" {
+		return "MESSENGER"
+	}
+	return "MESSENGER_" + strings.ToUpper(cfg.Name)
+}
+
+// DeliveryResult is the outcome of a message for a destination,
+// it's skipped when the destination filters the message class out.
+type DeliveryResult struct {
+	Destination string
+	Skipped     bool
+	Err         error
+}
+
+// Dispatcher fans a message out to the destinations accepting its class.
+type Dispatcher struct {
+	destinations []dispatcherDestination
+}
+
+type dispatcherDestination struct {
+	name      string
+	classes   []string
+	messenger Messenger
+}
+
+// NewDispatcher creates the main messenger and the destinations.
+func NewDispatcher(cfg *ConfigMessenger) (*Dispatcher, error) {
+	dispatcher := &Dispatcher{}
+	// The messengers refer to the config itself, not to its copy.
+	destinations := []*ConfigMessenger{cfg}
+	for i := range cfg.Destinations {
+		destinations = append(destinations, &cfg.Destinations[i])
+	}
+	for _, destination := range destinations {
+		messenger, err := NewMessenger(destination)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", destination.prefix(), err)
+		}
+		name := destination.Name
+		if name == "" {
+			name = mainDestination
+		}
+		dispatcher.Add(name, destination.Classes, messenger)
+	}
+	return dispatcher, nil
+}
+
+func (dispatcher *Dispatcher) Add(name string, classes []string, messenger Messenger) {
+	dispatcher.destinations = append(dispatcher.destinations, dispatcherDestination{name: name, classes: classes, messenger: messenger})
+}
+
+// Dispatch sends the message to all destinations at once and returns the results in their order.
+func (dispatcher *Dispatcher) Dispatch(message Message) []DeliveryResult {
+	results := make([]DeliveryResult, len(dispatcher.destinations))
+	var wg sync.WaitGroup
+	for i, destination := range dispatcher.destinations {
+		results[i].Destination = destination.name
+		if !destination.accepts(message.Class) {
+			results[i].Skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(result *DeliveryResult, messenger Messenger) {
+			defer wg.Done()
+			result.Err = messenger.Send(message)
+		}(&results[i], destination.messenger)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("Error sending %s message to %s: %v\n", message.Class, result.Destination, result.Err)
+		} else if !result.Skipped {
+			log.Printf("Sent %s message to %s\n", message.Class, result.Destination)
+		}
+	}
+	return results
+}
+
+// Only returns the dispatcher of the named destinations, the same one when none are named.
+func (dispatcher *Dispatcher) Only(names []string) *Dispatcher {
+	if len(names) == 0 {
+		return dispatcher
+	}
+	res := &Dispatcher{}
+	for _, destination := range dispatcher.destinations {
+		if slices.Contains(names, destination.name) {
+			res.destinations = append(res.destinations, destination)
+		}
+	}
+	return res
+}
+
+// Destinations returns the names of the destinations accepting the message class.
+func (dispatcher *Dispatcher) Destinations(class string) (names []string) {
+	for _, destination := range dispatcher.destinations {
+		if destination.accepts(class) {
+			names = append(names, destination.name)
+		}
+	}
+	return
+}
+
+// Names returns the names of all destinations.
+func (dispatcher *Dispatcher) Names() (names []string) {
+	for _, destination := range dispatcher.destinations {
+		names = append(names, destination.name)
+	}
+	return
+}
+
+// Messenger returns the messenger of the destination by its name.
+func (dispatcher *Dispatcher) Messenger(name string) (Messenger, error) {
+	for _, destination := range dispatcher.destinations {
+		if destination.name == name {
+			return destination.messenger, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown destination %q", name)
+}
+
+func (destination *dispatcherDestination) accepts(class string) bool {
+	return len(destination.classes) == 0 || slices.Contains(destination.classes, class)
+}
+
+// DestinationError is the failure of the message delivery to a destination.
+type DestinationError struct {
+	Destination string
+	Err         error
+}
+
+func (err *DestinationError) Error() string {
+	return err.Destination + ": " + err.Err.Error()
+}
+
+func (err *DestinationError) Unwrap() error {
+	return err.Err
+}
+
+// DeliveryError joins the errors of the failed destinations, nil when all succeeded.
+func DeliveryError(results []DeliveryResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, &DestinationError{Destination: result.Destination, Err: result.Err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FailedDestinations returns the names of the destinations failed in the error, none when it isn't a delivery error.
+func FailedDestinations(err error) (names []string) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			names = append(names, FailedDestinations(err)...)
+		}
+		return
+	}
+	var destinationErr *DestinationError
+	if errors.As(err, &destinationErr) {
+		names = append(names, destinationErr.Destination)
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessenger keeps the sent messages and fails with err when set.
+type fakeMessenger struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func (messenger *fakeMessenger) Send(message Message) error {
+	messenger.mu.Lock()
+	defer messenger.mu.Unlock()
+	messenger.messages = append(messenger.messages, message)
+	return messenger.err
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	main, ops, broken := &fakeMessenger{}, &fakeMessenger{}, &fakeMessenger{err: errors.New("timeout")}
+	dispatcher := &Dispatcher{}
+	dispatcher.Add("main", nil, main)
+	dispatcher.Add("ops", []string{MessageClassError}, ops)
+	dispatcher.Add("broken", []string{MessageClassPrices}, broken)
+
+	results := dispatcher.Dispatch(Message{Class: MessageClassPrices, Document: TextDocument("prices")})
+	assert.Equal(t, []DeliveryResult{
+		{Destination: "main"},
+		{Destination: "ops", Skipped: true},
+		{Destination: "broken", Err: broken.err},
+	}, results)
+	assert.EqualError(t, DeliveryError(results), "broken: timeout")
+	assert.Equal(t, []string{"broken"}, FailedDestinations(DeliveryError(results)))
+	assert.Nil(t, FailedDestinations(broken.err))
+
+	results = dispatcher.Dispatch(Message{Class: MessageClassError, Document: TextDocument("error")})
+	assert.NoError(t, DeliveryError(results))
+	assert.Len(t, main.messages, 2)
+	require.Len(t, ops.messages, 1)
+	assert.Equal(t, "error", ops.messages[0].Document.Format(FormatPlain))
+	assert.Len(t, broken.messages, 1)
+
+	// A message without the class goes to the destinations without the filter only.
+	results = dispatcher.Dispatch(Message{Document: TextDocument("test")})
+	assert.Equal(t, []bool{false, true, true}, []bool{results[0].Skipped, results[1].Skipped, results[2].Skipped})
+}
+
+func TestMessengerDestinations_Decode(t *testing.T) {
+	t.Setenv("MESSENGER_OPS_DRIVER", "telegram")
+	t.Setenv("MESSENGER_OPS_CLASSES", "error,no-prices")
+	t.Setenv("MESSENGER_OPS_TELEGRAM_TOKEN", "ops-token")
+	t.Setenv("MESSENGER_OPS_TELEGRAM_CHATID", "-100")
+
+	var destinations MessengerDestinations
+	require.NoError(t, destinations.Decode("Ops, team"))
+	require.Len(t, destinations, 2)
+	assert.Equal(t, "ops", destinations[0].Name)
+	assert.Equal(t, "telegram", destinations[0].Driver)
+	assert.Equal(t, []string{"error", "no-prices"}, destinations[0].Classes)
+	assert.Equal(t, int64(-100), destinations[0].Telegram.ChatID)
+	assert.Equal(t, "MESSENGER_TEAM", destinations[1].prefix())
+
+	assert.Error(t, destinations.Decode("main"))
+	assert.Error(t, destinations.Decode("ops-team"))
+}
+
+func TestConfigSelfCheck_Destinations(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Destinations = MessengerDestinations{{Name: "team", Driver: "telegram"}}
+	assert.EqualError(t, cfg.SelfCheck(), "MESSENGER_TEAM_TELEGRAM_TOKEN not set")
+
+	cfg.Messenger.Destinations[0].Telegram = ConfigTelegram{Token: "test", ChatID: 1}
+	assert.NoError(t, cfg.SelfCheck())
+
+	cfg.Messenger.Classes = []string{"weather"}
+	assert.EqualError(t, cfg.SelfCheck(), "unknown MESSENGER_CLASSES item: weather")
+}
+
+func TestSendRichMessage_Destinations(t *testing.T) {
+	main, ops := newFakeTelegram(), newFakeTelegram()
+	defer main.Close()
+	defer ops.Close()
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = main.Endpoint()
+	cfg.Messenger.Destinations = MessengerDestinations{{
+		Name:     "ops",
+		Driver:   "telegram",
+		Classes:  []string{MessageClassError},
+		Telegram: ConfigTelegram{Token: "ops", ChatID: 7, APIEndpoint: ops.Endpoint()},
+	}}
+
+	require.NoError(t, SendRichMessage(&cfg.Messenger, Message{Class: MessageClassPrices, Document: TextDocument("prices")}))
+	require.NoError(t, SendRichMessage(&cfg.Messenger, Message{Class: MessageClassError, Document: TextDocument("error")}))
+
+	assert.Len(t, main.Requests(), 2)
+	requests := ops.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "7", requests[0].Values["chat_id"])
+	assert.Equal(t, "error", requests[0].Values["text"])
+}
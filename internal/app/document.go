@@ -0,0 +1,229 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Document block kinds.
+const (
+	BlockHeading   = "heading"
+	BlockParagraph = "paragraph"
+	BlockStrong    = "strong"
+	BlockFields    = "fields"
+	BlockCode      = "code"
+	BlockChart     = "chart"
+	BlockContext   = "context"
+)
+
+// Document is the message content without any markup, every messenger renders it with its Formatter.
+type Document struct {
+	Blocks []Block
+}
+
+// Block is a part of the document: the text of a heading, paragraph, strong paragraph, code or context,
+// the name/value fields or the chart rows. The detail blocks repeat what the chart image shows.
+type Block struct {
+	Kind   string
+	Text   string
+	Fields [][2]string
+	Rows   []ChartRow
+	Detail bool
+}
+
+// ChartRow is a line of the text bar chart: "13:00 ████ 🟢 0.00".
+type ChartRow struct {
+	Label  string
+	Bar    string
+	Marker string
+	Value  string
+}
+
+// TextDocument returns the document of the plain text.
+func TextDocument(text string) Document {
+	return Document{Blocks: []Block{{Kind: BlockParagraph, Text: text}}}
+}
+
+func (doc *Document) Add(block Block) *Document {
+	doc.Blocks = append(doc.Blocks, block)
+	return doc
+}
+
+func (doc *Document) Heading(text string) *Document {
+	return doc.Add(Block{Kind: BlockHeading, Text: text})
+}
+
+func (doc *Document) Paragraph(text string) *Document {
+	return doc.Add(Block{Kind: BlockParagraph, Text: text})
+}
+
+func (doc *Document) Strong(text string) *Document {
+	return doc.Add(Block{Kind: BlockStrong, Text: text})
+}
+
+// Summary returns the document without the detail blocks, for the messages sent with the chart image.
+func (doc Document) Summary() Document {
+	var res Document
+	for _, block := range doc.Blocks {
+		if !block.Detail {
+			res.Blocks = append(res.Blocks, block)
+		}
+	}
+	return res
+}
+
+// Without returns the document without the blocks of the kind.
+func (doc Document) Without(kind string) Document {
+	var res Document
+	for _, block := range doc.Blocks {
+		if block.Kind != kind {
+			res.Blocks = append(res.Blocks, block)
+		}
+	}
+	return res
+}
+
+func (doc Document) hasHeading() bool {
+	return len(doc.Blocks) > 0 && doc.Blocks[0].Kind == BlockHeading
+}
+
+// Title returns the heading, or the first line of the plain text without the heading.
+func (doc Document) Title() string {
+	if doc.hasHeading() {
+		return doc.Blocks[0].Text
+	}
+	title, _, _ := strings.Cut(FormatPlain.Format(doc), "\n")
+	return title
+}
+
+// Body returns the document without the heading.
+func (doc Document) Body() Document {
+	if doc.hasHeading() {
+		return Document{Blocks: doc.Blocks[1:]}
+	}
+	return doc
+}
+
+func (doc Document) Format(formatter Formatter) string {
+	return formatter.Format(doc)
+}
+
+// Formatter renders the document with the markup and the escaping of a messenger.
+type Formatter interface {
+	Format(doc Document) string
+}
+
+// Formatters of the messengers.
+var (
+	FormatMarkdownV2   Formatter = markupFormatter{escape: EscapeMarkdownV2, bold: "*%s*", code: "`%s`", pre: "```\n%s\n```", codeEscape: markdownV2CodeReplacer.Replace}
+	FormatTelegramHTML Formatter = markupFormatter{escape: html.EscapeString, bold: "<b>%s</b>", code: "<code>%s</code>", pre: "<pre>%s</pre>", small: "<i>%s</i>"}
+	FormatSlack        Formatter = markupFormatter{escape: slackEscape, bold: "*%s*", code: "`%s`", pre: "```\n%s\n```"}
+	FormatPlain        Formatter = markupFormatter{}
+	FormatHTML         Formatter = htmlFormatter{}
+)
+
+var markdownV2CodeReplacer = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// markupFormatter renders the blocks line by line with the markup patterns, the empty ones leave the text as is.
+type markupFormatter struct {
+	escape     func(string) string
+	codeEscape func(string) string
+	bold       string
+	code       string
+	pre        string
+	small      string
+}
+
+func (formatter markupFormatter) wrap(pattern, text string) string {
+	if pattern == "" {
+		return text
+	}
+	return fmt.Sprintf(pattern, text)
+}
+
+func (formatter markupFormatter) text(text string) string {
+	if formatter.escape == nil {
+		return text
+	}
+	return formatter.escape(text)
+}
+
+// verbatim escapes the text of the code and the chart, where the markup isn't parsed.
+func (formatter markupFormatter) verbatim(text string) string {
+	if formatter.codeEscape != nil {
+		return formatter.codeEscape(text)
+	}
+	return formatter.text(text)
+}
+
+func (formatter markupFormatter) Format(doc Document) string {
+	var blocks []string
+	for _, block := range doc.Blocks {
+		switch block.Kind {
+		case BlockHeading, BlockStrong:
+			blocks = append(blocks, formatter.wrap(formatter.bold, formatter.text(block.Text)))
+		case BlockFields:
+			lines := make([]string, len(block.Fields))
+			for i, field := range block.Fields {
+				lines[i] = formatter.wrap(formatter.bold, formatter.text(field[0])) + formatter.text(": "+field[1])
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		case BlockCode:
+			blocks = append(blocks, formatter.wrap(formatter.pre, formatter.verbatim(strings.TrimSuffix(block.Text, "\n"))))
+		case BlockChart:
+			lines := make([]string, len(block.Rows))
+			for i, row := range block.Rows {
+				lines[i] = formatter.chartRow(row)
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		case BlockContext:
+			blocks = append(blocks, formatter.wrap(formatter.small, formatter.text(block.Text)))
+		default:
+			blocks = append(blocks, formatter.text(block.Text))
+		}
+	}
+	return strings.Join(blocks, "\n")
+}
+
+func (formatter markupFormatter) chartRow(row ChartRow) string {
+	parts := []string{formatter.wrap(formatter.code, formatter.verbatim(row.Label)), row.Bar}
+	if row.Marker != "" {
+		parts = append(parts, row.Marker)
+	}
+	return strings.Join(append(parts, formatter.text(row.Value)), " ")
+}
+
+// htmlFormatter renders the document as the body of an HTML page.
+type htmlFormatter struct{}
+
+func (htmlFormatter) Format(doc Document) string {
+	var res strings.Builder
+	for _, block := range doc.Blocks {
+		text := html.EscapeString(block.Text)
+		switch block.Kind {
+		case BlockHeading:
+			res.WriteString("<h2>" + text + "</h2>\n")
+		case BlockStrong:
+			res.WriteString("<p><b>" + text + "</b></p>\n")
+		case BlockFields:
+			res.WriteString("<table>\n")
+			for _, field := range block.Fields {
+				res.WriteString(fmt.Sprintf(
+					"<tr><th style=\"text-align:left;padding:2px 12px 2px 0\">%s</th><td>%s</td></tr>\n",
+					html.EscapeString(field[0]), html.EscapeString(field[1]),
+				))
+			}
+			res.WriteString("</table>\n")
+		case BlockCode:
+			res.WriteString("<pre>" + strings.TrimSuffix(text, "\n") + "</pre>\n")
+		case BlockChart:
+			res.WriteString("<pre>" + html.EscapeString(FormatPlain.Format(Document{Blocks: []Block{block}})) + "</pre>\n")
+		case BlockContext:
+			res.WriteString("<p><small>" + text + "</small></p>\n")
+		default:
+			res.WriteString("<p>" + strings.ReplaceAll(text, "\n", "<br>\n") + "</p>\n")
+		}
+	}
+	return res.String()
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateTestDocument() Document {
+	var doc Document
+	doc.Heading("EPEX NL 2025-02-28 <test>")
+	doc.Strong("There are Negative prices!")
+	doc.Paragraph("a_b & (c)\nd*e")
+	doc.Add(Block{Kind: BlockFields, Fields: [][2]string{{"Lowest", "-0.020 at 14:00"}}, Detail: true})
+	doc.Add(Block{Kind: BlockCode, Text: "x `y` \\z\n"})
+	doc.Add(Block{Kind: BlockChart, Rows: []ChartRow{
+		{Label: "13:00", Bar: "██", Value: "0.00"},
+		{Label: "14:00", Bar: "█", Marker: "🟢", Value: "-0.02"},
+	}})
+	doc.Add(Block{Kind: BlockContext, Text: "🟢 Low ≤ 0.10", Detail: true})
+	return doc
+}
+
+func TestFormatMarkdownV2(t *testing.T) {
+	assert.Equal(t, "*EPEX NL 2025\\-02\\-28 <test\\>*\n"+
+		"*There are Negative prices\\!*\n"+
+		"a\\_b & \\(c\\)\nd\\*e\n"+
+		"*Lowest*: \\-0\\.020 at 14:00\n"+
+		"```\nx \\`y\\` \\\\z\n```\n"+
+		"`13:00` ██ 0\\.00\n`14:00` █ 🟢 \\-0\\.02\n"+
+		"🟢 Low ≤ 0\\.10", generateTestDocument().Format(FormatMarkdownV2))
+}
+
+func TestFormatTelegramHTML(t *testing.T) {
+	assert.Equal(t, "<b>EPEX NL 2025-02-28 &lt;test&gt;</b>\n"+
+		"<b>There are Negative prices!</b>\n"+
+		"a_b &amp; (c)\nd*e\n"+
+		"<b>Lowest</b>: -0.020 at 14:00\n"+
+		"<pre>x `y` \\z</pre>\n"+
+		"<code>13:00</code> ██ 0.00\n<code>14:00</code> █ 🟢 -0.02\n"+
+		"<i>🟢 Low ≤ 0.10</i>", generateTestDocument().Format(FormatTelegramHTML))
+}
+
+func TestFormatSlack(t *testing.T) {
+	text := generateTestDocument().Format(FormatSlack)
+	assert.Contains(t, text, "*EPEX NL 2025-02-28 &lt;test&gt;*\n")
+	assert.Contains(t, text, "a_b &amp; (c)\n")
+	assert.Contains(t, text, "`14:00` █ 🟢 -0.02\n")
+}
+
+func TestFormatPlain(t *testing.T) {
+	assert.Equal(t, "EPEX NL 2025-02-28 <test>\n"+
+		"There are Negative prices!\n"+
+		"a_b & (c)\nd*e\n"+
+		"Lowest: -0.020 at 14:00\n"+
+		"x `y` \\z\n"+
+		"13:00 ██ 0.00\n14:00 █ 🟢 -0.02\n"+
+		"🟢 Low ≤ 0.10", generateTestDocument().Format(FormatPlain))
+}
+
+func TestFormatHTML(t *testing.T) {
+	html := generateTestDocument().Format(FormatHTML)
+	assert.Contains(t, html, "<h2>EPEX NL 2025-02-28 &lt;test&gt;</h2>\n")
+	assert.Contains(t, html, "<p>a_b &amp; (c)<br>\nd*e</p>\n")
+	assert.Contains(t, html, "<th style=\"text-align:left;padding:2px 12px 2px 0\">Lowest</th><td>-0.020 at 14:00</td>")
+	assert.Contains(t, html, "<pre>13:00 ██ 0.00\n14:00 █ 🟢 -0.02</pre>\n")
+}
+
+func TestDocument_Summary(t *testing.T) {
+	doc := generateTestDocument()
+	assert.Len(t, doc.Summary().Blocks, 5)
+	assert.Equal(t, "EPEX NL 2025-02-28 <test>", doc.Title())
+	assert.Len(t, doc.Body().Blocks, 6)
+
+	doc = TextDocument("No prices for 2025-02-28\nRetrying")
+	assert.Equal(t, "No prices for 2025-02-28", doc.Title())
+	assert.Equal(t, doc, doc.Body())
+}
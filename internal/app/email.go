@@ -0,0 +1,225 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const emailDialTimeout = 10 * time.Second
+
+// Email connection security.
+const (
+	emailSecurityNone     = "none"
+	emailSecuritySTARTTLS = "starttls"
+	emailSecurityTLS      = "tls"
+)
+
+// emailMessenger sends the messages as multipart emails with the images inline.
+type emailMessenger struct {
+	cfg *ConfigEmail
+	now func() time.Time
+}
+
+func (messenger *emailMessenger) Send(message Message) error {
+	data, err := buildEmail(messenger.cfg, message, messenger.now())
+	if err != nil {
+		return err
+	}
+	return sendEmail(messenger.cfg, data)
+}
+
+// buildEmail builds multipart/related with the text and HTML alternatives and the attachments,
+// the photos are shown inline by their Content-ID.
+func buildEmail(cfg *ConfigEmail, message Message, now time.Time) ([]byte, error) {
+	title, plain, body := emailContent(message)
+	var images []string
+	for i, attachment := range message.Attachments {
+		if attachment.Kind == AttachmentPhoto {
+			images = append(images, fmt.Sprintf(`<p><img src="cid:%s" alt="%s" style="max-width:100%%"></p>`,
+				emailContentID(i), html.EscapeString(attachment.Name)))
+		}
+	}
+	page := fmt.Sprintf(
+		"<!DOCTYPE html>\n<html><body style=\"font-family:sans-serif\">\n%s%s</body></html>\n",
+		body, strings.Join(images, "\n"),
+	)
+
+	var res bytes.Buffer
+	related := multipart.NewWriter(&res)
+	header := []string{
+		"From: " + cfg.From,
+		"To: " + strings.Join(cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.ReplaceAll(cfg.Subject, "{title}", title)),
+		"Date: " + now.Format(time.RFC1123Z),
+		"Message-ID: " + emailMessageID(cfg.From),
+		"MIME-Version: 1.0",
+		fmt.Sprintf(`Content-Type: multipart/related; boundary="%s"`, related.Boundary()),
+	}
+	res.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	part, err := related.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	alternative := multipart.NewWriter(part)
+	if err = alternative.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for _, content := range []struct{ mimeType, text string }{{"text/plain", plain}, {"text/html", page}} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {content.mimeType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writer := quotedprintable.NewWriter(part)
+		if _, err = writer.Write([]byte(content.text)); err != nil {
+			return nil, err
+		}
+		if err = writer.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err = alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	for i, attachment := range message.Attachments {
+		mimeType := attachment.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		partHeader := textproto.MIMEHeader{
+			"Content-Type":              {fmt.Sprintf("%s; name=%q", mimeType, attachment.Name)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.Name)},
+		}
+		if attachment.Kind == AttachmentPhoto {
+			partHeader.Set("Content-ID", "<"+emailContentID(i)+">")
+			partHeader.Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.Name))
+		}
+		part, err := related.CreatePart(partHeader)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = part.Write(emailBase64(attachment.Data)); err != nil {
+			return nil, err
+		}
+	}
+	if err = related.Close(); err != nil {
+		return nil, err
+	}
+
+	return res.Bytes(), nil
+}
+
+// emailContent returns the title, the plain text and the HTML body of the message.
+func emailContent(message Message) (title, plain, body string) {
+	doc := message.Document
+	return doc.Title(), FormatPlain.Format(doc), FormatHTML.Format(doc)
+}
+
+func emailContentID(i int) string {
+	return fmt.Sprintf("attachment-%d@day-ahead-prices", i)
+}
+
+func emailMessageID(from string) string {
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// emailBase64 encodes the data in lines of 76 characters.
+func emailBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+func sendEmail(cfg *ConfigEmail, data []byte) (err error) {
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.TLSSkipVerify}
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+
+	var conn net.Conn
+	if cfg.Security == emailSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if cfg.Security == emailSecuritySTARTTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err = client.Mail(emailAddress(cfg.From)); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range cfg.To {
+		if err = client.Rcpt(emailAddress(to)); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+	if _, err = writer.Write(data); err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+	return client.Quit()
+}
+
+// emailAddress returns the bare address of "Name <address>".
+func emailAddress(address string) string {
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		return strings.TrimSuffix(address[start+1:], ">")
+	}
+	return strings.TrimSpace(address)
+}
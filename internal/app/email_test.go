@@ -0,0 +1,270 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMail struct {
+	From string
+	To   []string
+	Auth string
+	TLS  bool
+	Data []byte
+}
+
+// fakeSMTP is an in-process SMTP server supporting STARTTLS, implicit TLS and AUTH PLAIN.
+type fakeSMTP struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	mu        sync.Mutex
+	mails     []fakeMail
+}
+
+func newFakeSMTP(t *testing.T, implicitTLS bool) *fakeSMTP {
+	server := &fakeSMTP{tlsConfig: generateTestTLSConfig(t)}
+	var err error
+	if implicitTLS {
+		server.listener, err = tls.Listen("tcp", "127.0.0.1:0", server.tlsConfig)
+	} else {
+		server.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := server.listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, implicitTLS)
+		}
+	}()
+	return server
+}
+
+func (server *fakeSMTP) Close() {
+	_ = server.listener.Close()
+}
+
+func (server *fakeSMTP) Config(security string) ConfigEmail {
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return ConfigEmail{
+		Host:          "127.0.0.1",
+		Port:          portNumber,
+		Security:      security,
+		TLSSkipVerify: true,
+		From:          "Prices <prices@example.com>",
+		To:            []string{"alice@example.com", "Bob <bob@example.com>"},
+		Subject:       "[EPEX] {title}",
+	}
+}
+
+func (server *fakeSMTP) Mails() []fakeMail {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]fakeMail{}, server.mails...)
+}
+
+func (server *fakeSMTP) serve(conn net.Conn, isTLS bool) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	mail := fakeMail{TLS: isTLS}
+	_ = text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			extensions := "250-localhost\r\n250-AUTH PLAIN\r\n"
+			if !mail.TLS {
+				extensions += "250-STARTTLS\r\n"
+			}
+			_ = text.PrintfLine("%s250 8BITMIME", extensions)
+		case "STARTTLS":
+			_ = text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, mail.TLS = tlsConn, true
+			text = textproto.NewConn(conn)
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(args, "PLAIN "))
+			mail.Auth = string(credentials)
+			_ = text.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(args, "FROM:"), " ")
+			mail.From = strings.Trim(from, "<>")
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			mail.To = append(mail.To, strings.Trim(strings.TrimPrefix(args, "TO:"), "<>"))
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 Go ahead")
+			if mail.Data, err = text.ReadDotBytes(); err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.mails = append(server.mails, mail)
+			server.mu.Unlock()
+			_ = text.PrintfLine("250 Queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func generateTestTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// readTestEmail returns the decoded subject and the parts by their content type.
+func readTestEmail(t *testing.T, data []byte) (subject string, parts map[string]*multipart.Part, bodies map[string]string) {
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	subject, err = new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	require.NoError(t, err)
+
+	parts, bodies = map[string]*multipart.Part{}, map[string]string{}
+	var read func(contentType string, body io.Reader)
+	read = func(contentType string, body io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		require.NoError(t, err)
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			data, err := io.ReadAll(body)
+			require.NoError(t, err)
+			bodies[mediaType] = string(data)
+			return
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return
+			}
+			require.NoError(t, err)
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			parts[partType] = part
+			read(part.Header.Get("Content-Type"), bufio.NewReader(part))
+		}
+	}
+	read(message.Header.Get("Content-Type"), message.Body)
+	return
+}
+
+func TestEmailMessenger_STARTTLS(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	defer server.Close()
+	cfg := server.Config(emailSecuritySTARTTLS)
+	cfg.Username, cfg.Password = "user", "secret"
+	analytics := generateTestConfig().Analytics
+	prices, _ := generateStub()
+	message, err := BuildDayMessage(&analytics, NewMessageTemplates(&ConfigTemplates{}, ""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	messenger, err := NewMessenger(&ConfigMessenger{Driver: "email", Email: cfg})
+	require.NoError(t, err)
+	require.NoError(t, messenger.Send(message))
+
+	mails := server.Mails()
+	require.Len(t, mails, 1)
+	assert.True(t, mails[0].TLS)
+	assert.Equal(t, "\x00user\x00secret", mails[0].Auth)
+	assert.Equal(t, "prices@example.com", mails[0].From)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, mails[0].To)
+
+	subject, parts, bodies := readTestEmail(t, mails[0].Data)
+	assert.Equal(t, "[EPEX] EPEX NL Day-Ahead 2025-02-28", subject)
+	assert.Contains(t, bodies["text/plain"], "Lowest: -0.020 at 14:00\n")
+	assert.Contains(t, bodies["text/plain"], "14:00 █ 🟢 -0.02\n")
+	assert.NotContains(t, bodies["text/plain"], "\\")
+	assert.Contains(t, bodies["text/html"], "<h2>EPEX NL Day-Ahead 2025-02-28</h2>")
+	assert.Contains(t, bodies["text/html"], "<th style=\"text-align:left;padding:2px 12px 2px 0\">Average</th><td>0.109</td>")
+	assert.Contains(t, bodies["text/html"], `<img src="cid:attachment-0@day-ahead-prices"`)
+	require.Contains(t, parts, "image/png")
+	assert.Equal(t, "<attachment-0@day-ahead-prices>", parts["image/png"].Header.Get("Content-ID"))
+	image, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(bodies["image/png"], "\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, message.Attachments[0].Data, image)
+}
+
+func TestEmailMessenger_TLS(t *testing.T) {
+	server := newFakeSMTP(t, true)
+	defer server.Close()
+	cfg := server.Config(emailSecurityTLS)
+
+	messenger := &emailMessenger{cfg: &cfg, now: time.Now}
+	require.NoError(t, messenger.Send(Message{Class: MessageClassError, Document: TextDocument("Error for 2025-02-28")}))
+
+	mails := server.Mails()
+	require.Len(t, mails, 1)
+	assert.True(t, mails[0].TLS)
+	assert.Equal(t, "", mails[0].Auth)
+	subject, _, bodies := readTestEmail(t, mails[0].Data)
+	assert.Equal(t, "[EPEX] Error for 2025-02-28", subject)
+	assert.Equal(t, "Error for 2025-02-28", bodies["text/plain"])
+	assert.Contains(t, bodies["text/html"], "<p>Error for 2025-02-28</p>")
+}
+
+func TestEmailMessenger_Unavailable(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	cfg := server.Config(emailSecurityNone)
+	server.Close()
+
+	messenger := &emailMessenger{cfg: &cfg, now: time.Now}
+	assert.Error(t, messenger.Send(Message{Document: TextDocument("test")}))
+}
+
+func TestConfigSelfCheck_Email(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Destinations = MessengerDestinations{{
+		Name:   "mail",
+		Driver: "email",
+		Email:  ConfigEmail{Host: "smtp.example.com", From: "prices@example.com", Security: "ssl"},
+	}}
+	assert.EqualError(t, cfg.SelfCheck(), "MESSENGER_MAIL_EMAIL_TO not set")
+	cfg.Messenger.Destinations[0].Email.To = []string{"alice@example.com"}
+	assert.EqualError(t, cfg.SelfCheck(), "unknown MESSENGER_MAIL_EMAIL_SECURITY: ssl")
+	cfg.Messenger.Destinations[0].Email.Security = "tls"
+	assert.NoError(t, cfg.SelfCheck())
+}
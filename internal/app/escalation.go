@@ -0,0 +1,130 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const failuresCollection = "failures"
+
+// Sources of the counted failures, the delivery ones are per destination: "delivery-<destination>".
+const (
+	FailureSourceNotifyDay = "notify-day"
+	FailureSourceRevisions = "revisions"
+	FailureSourceDelivery  = "delivery"
+)
+
+// failuresMu serializes the counting, the concurrent deliveries report the same sources.
+var failuresMu sync.Mutex
+
+// Failure counts the failures in a row of a source, it's removed by the first success.
+type Failure struct {
+	Count     int       `json:"count"`
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	LastError string    `json:"lastError"`
+	Escalated bool      `json:"escalated"`
+}
+
+// ReportFailure counts the failure of the source, the admin message is sent once when it fails
+// MESSENGER_ESCALATEAFTER times in a row.
+func ReportFailure(cfg *ConfigApp, source string, cause error, now time.Time) error {
+	return reportFailure(cfg, source, cause, false, now)
+}
+
+// reportFailure counts the failure, the final one is escalated at once as nothing retries it anymore.
+func reportFailure(cfg *ConfigApp, source string, cause error, final bool, now time.Time) (err error) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	var failure Failure
+	if _, err = cfg.Store().Load(failuresCollection, source, &failure); err != nil {
+		return
+	}
+	if failure.Count == 0 {
+		failure.First = now
+	}
+	failure.Count++
+	failure.Last = now
+	failure.LastError = cause.Error()
+
+	if !failure.Escalated && cfg.Messenger.EscalateAfter > 0 && (final || failure.Count >= cfg.Messenger.EscalateAfter) {
+		log.Printf("%s failed %d times in a row, escalating\n", source, failure.Count)
+		if err = sendAdminMessage(cfg, "escalation", source, &failure); err != nil {
+			return
+		}
+		failure.Escalated = true
+	}
+	return cfg.Store().Save(failuresCollection, source, failure)
+}
+
+// ReportSuccess resets the failures of the source, the recovery of an escalated one is sent as an admin message.
+func ReportSuccess(cfg *ConfigApp, source string) (err error) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	var failure Failure
+	ok, err := cfg.Store().Load(failuresCollection, source, &failure)
+	if err != nil || !ok {
+		return
+	}
+	if failure.Escalated {
+		if err = sendAdminMessage(cfg, "recovery", source, &failure); err != nil {
+			return
+		}
+	}
+	return cfg.Store().Delete(failuresCollection, source)
+}
+
+// reportDelivery counts the failed delivery attempts of the item destination, a success resets them.
+// The dead-lettered item is escalated at once. The admin messages aren't counted, their failure would escalate itself.
+func reportDelivery(cfg *ConfigApp, item OutboxItem, cause error, dead bool, now time.Time) {
+	if item.Message.Class == MessageClassAdmin {
+		return
+	}
+	source := FailureSourceDelivery + "-" + item.Destination
+	var err error
+	if cause == nil {
+		err = ReportSuccess(cfg, source)
+	} else {
+		err = reportFailure(cfg, source, cause, dead, now)
+	}
+	if err != nil {
+		log.Printf("Error reporting delivery to %s: %v\n", item.Destination, err)
+	}
+}
+
+// NotifyDayDue is due at the daily time and after a failure again every MESSENGER_RETRYEVERY until the end of the day,
+// so the failures in a row are the attempts and not the days.
+func NotifyDayDue(cfg *ConfigApp, daily func(now time.Time) bool) func(now time.Time) bool {
+	return func(now time.Time) bool {
+		if daily(now) {
+			return true
+		}
+		if cfg.Messenger.RetryEvery <= 0 {
+			return false
+		}
+		var failure Failure
+		if ok, err := cfg.Store().Load(failuresCollection, FailureSourceNotifyDay, &failure); err != nil || !ok {
+			return false
+		}
+		last := failure.Last.In(now.Location())
+		sameDay := last.Year() == now.Year() && last.YearDay() == now.YearDay()
+		return sameDay && !now.Before(last.Truncate(time.Minute).Add(cfg.Messenger.RetryEvery))
+	}
+}
+
+// sendAdminMessage renders the template of the failure, the key is the same for the whole failure series.
+func sendAdminMessage(cfg *ConfigApp, name string, source string, failure *Failure) error {
+	text, err := cfg.MessageTemplates("").Render(name, TemplateData{
+		Source:   source,
+		Failures: failure.Count,
+		Since:    failure.First,
+		Error:    failure.LastError,
+	})
+	if err != nil {
+		return fmt.Errorf("error rendering %s message: %w", name, err)
+	}
+	key := fmt.Sprintf("%s-%s-%s", name, source, failure.First.UTC().Format("20060102T150405"))
+	return Deliver(cfg, key, Message{Class: MessageClassAdmin, Document: TextDocument(text)})
+}
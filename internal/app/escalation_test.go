@@ -0,0 +1,175 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyDay_Escalation(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = "broken"
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Messenger.Classes = []string{MessageClassPrices, MessageClassNoPrices}
+	cfg.Messenger.EscalateAfter = 2
+	cfg.Messenger.Destinations = MessengerDestinations{{
+		Name:     "admin",
+		Driver:   messengerDriverTelegram,
+		Classes:  []string{MessageClassError, MessageClassAdmin},
+		Telegram: ConfigTelegram{Token: "admin", ChatID: 456, APIEndpoint: telegram.Endpoint()},
+	}}
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+
+	// The errors go to the admin chat only, the second one in a row is escalated once.
+	for i := 0; i < 3; i++ {
+		assert.Error(t, NotifyDay(cfg, day.AddDate(0, 0, i)))
+	}
+	requests := telegram.Requests()
+	require.Len(t, requests, 4)
+	for _, request := range requests {
+		assert.Equal(t, "456", request.Values["chat_id"])
+	}
+	assert.Equal(t, "Error for 2025\\-02\\-28", requests[0].Values["text"])
+	assert.Equal(t, "Error for 2025\\-03\\-01", requests[1].Values["text"])
+	assert.Contains(t, requests[2].Values["text"], "notify\\-day failed 2 times in a row since ")
+	assert.Equal(t, "Error for 2025\\-03\\-02", requests[3].Values["text"])
+
+	// The retry of the day doesn't repeat the error.
+	assert.Error(t, NotifyDay(cfg, day))
+	assert.Len(t, telegram.Requests(), 4)
+
+	// The prices go to the channel, the recovery to the admin chat.
+	cfg.Loader.Driver = loaderDriverStub
+	require.NoError(t, NotifyDay(cfg, day))
+	requests = telegram.Requests()
+	require.Len(t, requests, 6)
+	assert.Equal(t, "123", requests[4].Values["chat_id"])
+	assert.Equal(t, "456", requests[5].Values["chat_id"])
+	assert.Equal(t, "notify\\-day works again after 4 failures", requests[5].Values["text"])
+	ok, err := cfg.Store().Load(failuresCollection, FailureSourceNotifyDay, &Failure{})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestReportFailure(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	cfg.Messenger.EscalateAfter = 1
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	require.NoError(t, ReportFailure(cfg, FailureSourceRevisions, errors.New("timeout"), now))
+	require.NoError(t, ReportFailure(cfg, FailureSourceRevisions, errors.New("bad gateway"), now.Add(time.Hour)))
+	var failure Failure
+	_, err := cfg.Store().Load(failuresCollection, FailureSourceRevisions, &failure)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failure.Count)
+	assert.True(t, failure.Escalated)
+	assert.Equal(t, "bad gateway", failure.LastError)
+	assert.WithinDuration(t, now, failure.First, 0)
+
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "escalation-revisions-20250228T140000.main", pending[0].ID)
+	assert.Equal(t, MessageClassAdmin, pending[0].Class)
+	assert.Equal(t, "revisions failed 1 times in a row since 2025-02-28 15:00: timeout", pending[0].Title)
+
+	// Escalation is off.
+	cfg.Messenger.EscalateAfter = 0
+	require.NoError(t, ReportSuccess(cfg, FailureSourceRevisions))
+	require.NoError(t, ReportFailure(cfg, FailureSourceRevisions, errors.New("timeout"), now.Add(2*time.Hour)))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "revisions works again after 2 failures", pending[1].Title)
+}
+
+func TestReportDelivery(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	telegram.fail = true
+	cfg := generateOutboxTestConfig(telegram)
+	cfg.Messenger.EscalateAfter = 2
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	_, err := cfg.Queue().Enqueue("prices-2025-03-01", Message{Class: MessageClassPrices, Document: TextDocument("prices")}, now)
+	require.NoError(t, err)
+
+	// The attempts are counted, not the messages.
+	cfg.Queue().Process(now)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	cfg.Queue().Process(now.Add(time.Minute))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, MessageClassAdmin, pending[1].Class)
+	assert.Equal(t, "delivery-main failed 2 times in a row since 2025-02-28 15:00: error sending Telegram message: Bad Request", pending[1].Title)
+
+	// The dead letter is already escalated.
+	cfg.Queue().Process(now.Add(time.Hour))
+	var failure Failure
+	_, err = cfg.Store().Load(failuresCollection, FailureSourceDelivery+"-main", &failure)
+	require.NoError(t, err)
+	assert.Equal(t, 3, failure.Count)
+
+	telegram.fail = false
+	_, err = cfg.Queue().Enqueue("prices-2025-03-02", Message{Class: MessageClassPrices, Document: TextDocument("prices")}, now)
+	require.NoError(t, err)
+	cfg.Queue().Process(now.Add(2 * time.Hour))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "delivery-main works again after 3 failures", pending[1].Title)
+}
+
+func TestReportDelivery_DeadLetter(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	telegram.fail = true
+	cfg := generateOutboxTestConfig(telegram)
+	cfg.Messenger.EscalateAfter = 10
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	_, err := cfg.Queue().EnqueueChat("subscription-42-2025-03-01", 42, Message{Document: TextDocument("prices")}, now)
+	require.NoError(t, err)
+
+	// Nothing retries the dead letter, so it's escalated at once.
+	for i := 0; i < 3; i++ {
+		cfg.Queue().Process(now.Add(time.Duration(i) * time.Hour))
+	}
+	dead, err := cfg.Queue().DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "delivery-chat failed 3 times in a row since 2025-02-28 15:00: error sending Telegram message: Bad Request", pending[0].Title)
+}
+
+func TestNotifyDayDue(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.RetryEvery = 15 * time.Minute
+	daily, err := Daily("15:00")
+	require.NoError(t, err)
+	due := NotifyDayDue(cfg, daily)
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	assert.True(t, due(now))
+	assert.False(t, due(now.Add(15*time.Minute)))
+
+	// The failed attempt is repeated every 15 minutes until the end of the day.
+	require.NoError(t, ReportFailure(cfg, FailureSourceNotifyDay, errors.New("timeout"), now.Add(30*time.Second)))
+	assert.False(t, due(now.Add(10*time.Minute)))
+	assert.True(t, due(now.Add(15*time.Minute)))
+	assert.True(t, due(now.Add(8*time.Hour+59*time.Minute)))
+	assert.False(t, due(now.Add(9*time.Hour)))
+
+	cfg.Messenger.RetryEvery = 0
+	assert.False(t, due(now.Add(15*time.Minute)))
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+)
+
+// fileMessengerMu keeps the numbering of the files when several destinations write to the same directory.
+var fileMessengerMu sync.Mutex
+
+// fileMessenger writes every message as <number>-<class>.json with its attachments next to it as <number>-<name>,
+// the numbers go on from the highest one in the directory. The existing files are never overwritten.
+type fileMessenger struct {
+	cfg *ConfigFile
+}
+
+type fileMessage struct {
+	Class       string                `json:"class"`
+	Title       string                `json:"title"`
+	Text        string                `json:"text"`
+	Markdown    string                `json:"markdown"`
+	Payload     models.WebhookPayload `json:"payload"`
+	Attachments []fileAttachment      `json:"attachments,omitempty"`
+	Keyboard    [][]Button            `json:"keyboard,omitempty"`
+}
+
+type fileAttachment struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Size     int    `json:"size"`
+	File     string `json:"file"`
+}
+
+func (messenger *fileMessenger) Send(message Message) error {
+	fileMessengerMu.Lock()
+	defer fileMessengerMu.Unlock()
+
+	if err := os.MkdirAll(messenger.cfg.Path, 0o755); err != nil {
+		return fmt.Errorf("failed to create messages directory: %w", err)
+	}
+	class := message.Class
+	if class == "" {
+		class = "message"
+	}
+	number, err := lastFileNumber(messenger.cfg.Path)
+	if err != nil {
+		return err
+	}
+	// The message file reserves the number, the next one is taken when another process was faster.
+	var file *os.File
+	for {
+		number++
+		file, err = createFile(filepath.Join(messenger.cfg.Path, fmt.Sprintf("%04d-%s.json", number, class)))
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+	defer file.Close()
+	prefix := fmt.Sprintf("%04d-", number)
+
+	res := fileMessage{
+		Class:    class,
+		Title:    message.Document.Title(),
+		Text:     FormatPlain.Format(message.Document),
+		Markdown: FormatMarkdownV2.Format(message.Document),
+		Payload:  WebhookPayload(message),
+		Keyboard: message.Keyboard,
+	}
+	for _, attachment := range message.Attachments {
+		name := prefix + filepath.Base(attachment.Name)
+		if err = writeNewFile(filepath.Join(messenger.cfg.Path, name), attachment.Data); err != nil {
+			return fmt.Errorf("failed to write attachment: %w", err)
+		}
+		res.Attachments = append(res.Attachments, fileAttachment{
+			Kind:     attachment.Kind,
+			Name:     attachment.Name,
+			MimeType: attachment.MimeType,
+			Size:     len(attachment.Data),
+			File:     name,
+		})
+	}
+
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return file.Close()
+}
+
+// lastFileNumber returns the highest number of the message files in the directory, 0 when there are none.
+func lastFileNumber(path string) (last int, err error) {
+	names, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(filepath.Base(name), "-")
+		if number, errNumber := strconv.Atoi(prefix); errNumber == nil {
+			last = max(last, number)
+		}
+	}
+	return
+}
+
+// createFile creates the file, it fails with fs.ErrExist when it's already there.
+func createFile(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+}
+
+func writeNewFile(name string, data []byte) error {
+	file, err := createFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMessenger(t *testing.T) {
+	path := t.TempDir()
+	cfg := generateBotTestConfig()
+	cfg.Messenger = ConfigMessenger{Driver: messengerDriverFile, File: ConfigFile{Path: path}}
+	require.NoError(t, cfg.SelfCheck())
+
+	// The whole pipeline runs without Telegram.
+	require.NoError(t, NotifyDay(cfg, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())))
+	cfg.Loader.Driver = "broken"
+	assert.Error(t, NotifyDay(cfg, time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())))
+
+	entries, err := os.ReadDir(path)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"0001-epex_nl_2025-02-28.png", "0001-prices.json", "0002-error.json"}, names)
+
+	chart, err := os.ReadFile(filepath.Join(path, "0001-epex_nl_2025-02-28.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNG", string(chart[:4]))
+
+	data, err := os.ReadFile(filepath.Join(path, "0001-prices.json"))
+	require.NoError(t, err)
+	// The PNG size depends on the fonts and the encoder.
+	var message fileMessage
+	require.NoError(t, json.Unmarshal(data, &message))
+	require.Len(t, message.Attachments, 1)
+	assert.Equal(t, len(chart), message.Attachments[0].Size)
+	message.Attachments[0].Size = 0
+	data, err = json.MarshalIndent(message, "", "  ")
+	require.NoError(t, err)
+	assertGolden(t, "file_prices.golden.json", append(data, '\n'))
+
+	data, err = os.ReadFile(filepath.Join(path, "0002-error.json"))
+	require.NoError(t, err)
+	assertGolden(t, "file_error.golden.json", data)
+}
+
+func TestFileMessenger_Numbering(t *testing.T) {
+	path := t.TempDir()
+	for _, name := range []string{"0002-prices.json", "0007-error.json", "notes.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(path, name), []byte("{}\n"), 0o644))
+	}
+	messenger := &fileMessenger{cfg: &ConfigFile{Path: path}}
+
+	// The numbers go on from the highest one, not from the count of the files.
+	require.NoError(t, messenger.Send(Message{Document: TextDocument("first")}))
+	require.NoError(t, messenger.Send(Message{Class: MessageClassSummary, Document: TextDocument("second")}))
+	_, err := os.Stat(filepath.Join(path, "0008-message.json"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(path, "0009-summary.json"))
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(path, "0007-error.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", string(data))
+}
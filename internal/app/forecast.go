@@ -0,0 +1,161 @@
+package app
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// forecastBandZ gives the 95% confidence band.
+const forecastBandZ = 1.96
+
+var ErrNotEnoughHistory = errors.New("not enough price history for a forecast")
+
+// Forecast is an estimated day curve with its confidence band.
+type Forecast struct {
+	Day    time.Time
+	Prices []decimal.Decimal
+	Lower  []decimal.Decimal
+	Upper  []decimal.Decimal
+}
+
+type BacktestResult struct {
+	Days     int
+	MAE      float64
+	RMSE     float64
+	Coverage float64
+}
+
+// ForecastPrices estimates the day curve from the same weekday of the previous weeks (hour seasonality),
+// shifted by the difference between the recent days and the whole window (trend).
+func ForecastPrices(cfg *ConfigForecast, history *PriceHistory, day time.Time) (forecast Forecast, err error) {
+	location := day.Location()
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location)
+	nextDay := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, location)
+	slots := int(nextDay.Sub(day) / time.Hour)
+
+	window := cfg.Weeks * 7
+	if cfg.Days > window {
+		window = cfg.Days
+	}
+	days, err := history.Range(time.Date(day.Year(), day.Month(), day.Day()-window, 0, 0, 0, 0, location), day)
+	if err != nil {
+		return
+	}
+
+	seasonal := make([][]float64, slots)
+	recent := make([][]float64, slots)
+	var windowLevel, recentLevel []float64
+	recentFrom := time.Date(day.Year(), day.Month(), day.Day()-cfg.Days, 0, 0, 0, 0, location)
+	for _, historyDay := range days {
+		isSeasonal := historyDay.Day.Weekday() == day.Weekday()
+		isRecent := !historyDay.Day.Before(recentFrom)
+		for h, price := range historyDay.Prices {
+			value := price.InexactFloat64()
+			windowLevel = append(windowLevel, value)
+			if isRecent {
+				recentLevel = append(recentLevel, value)
+			}
+			if h >= slots {
+				continue
+			}
+			if isSeasonal {
+				seasonal[h] = append(seasonal[h], value)
+			}
+			if isRecent {
+				recent[h] = append(recent[h], value)
+			}
+		}
+	}
+
+	shift := 0.0
+	if len(recentLevel) > 0 {
+		shift = mean(recentLevel) - mean(windowLevel)
+	}
+
+	forecast.Day = day
+	for h := 0; h < slots; h++ {
+		var estimate float64
+		if len(seasonal[h]) > 0 {
+			estimate = mean(seasonal[h]) + shift
+		} else if len(recent[h]) > 0 {
+			estimate = mean(recent[h])
+		} else {
+			err = ErrNotEnoughHistory
+			return
+		}
+		band := forecastBandZ * stdDev(append(append([]float64{}, seasonal[h]...), recent[h]...))
+
+		forecast.Prices = append(forecast.Prices, decimal.NewFromFloat(estimate).Round(5))
+		forecast.Lower = append(forecast.Lower, decimal.NewFromFloat(estimate-band).Round(5))
+		forecast.Upper = append(forecast.Upper, decimal.NewFromFloat(estimate+band).Round(5))
+	}
+
+	return
+}
+
+// BacktestForecast forecasts every stored day between "from" and "till" from the history before it
+// and compares the forecast with the real prices.
+func BacktestForecast(cfg *ConfigForecast, history *PriceHistory, from, till time.Time) (res BacktestResult, err error) {
+	days, err := history.Range(from, till)
+	if err != nil {
+		return
+	}
+
+	var count, covered int
+	var absSum, sqSum float64
+	for _, historyDay := range days {
+		forecast, errForecast := ForecastPrices(cfg, history, historyDay.Day)
+		if errors.Is(errForecast, ErrNotEnoughHistory) {
+			continue
+		}
+		if errForecast != nil {
+			err = errForecast
+			return
+		}
+		res.Days++
+		for h, price := range historyDay.Prices {
+			if h >= len(forecast.Prices) {
+				break
+			}
+			diff := price.Sub(forecast.Prices[h]).InexactFloat64()
+			absSum += math.Abs(diff)
+			sqSum += diff * diff
+			if price.GreaterThanOrEqual(forecast.Lower[h]) && price.LessThanOrEqual(forecast.Upper[h]) {
+				covered++
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		err = ErrNotEnoughHistory
+		return
+	}
+
+	res.MAE = absSum / float64(count)
+	res.RMSE = math.Sqrt(sqSum / float64(count))
+	res.Coverage = float64(covered) / float64(count)
+	return
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	sum := 0.0
+	for _, value := range values {
+		sum += (value - m) * (value - m)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
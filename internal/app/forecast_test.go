@@ -0,0 +1,65 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecastPrices(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	saveTestHistory(t, cfg, day, 14)
+
+	forecast, err := ForecastPrices(&cfg.Forecast, cfg.History(), day)
+	require.NoError(t, err)
+	require.Len(t, forecast.Prices, 24)
+	assert.Equal(t, day, forecast.Day)
+
+	// Fridays have 0.1 more than other days, hours go up by 0.01 and there is no noise.
+	assert.Equal(t, "0.1", forecast.Prices[0].String())
+	assert.Equal(t, "0.33", forecast.Prices[23].String())
+	assert.True(t, forecast.Lower[12].LessThanOrEqual(forecast.Prices[12]))
+	assert.True(t, forecast.Upper[12].GreaterThanOrEqual(forecast.Prices[12]))
+}
+
+func TestForecastPrices_NotEnoughHistory(t *testing.T) {
+	cfg := generateTestConfig()
+
+	_, err := ForecastPrices(&cfg.Forecast, cfg.History(), time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	assert.True(t, errors.Is(err, ErrNotEnoughHistory))
+}
+
+func TestBacktestForecast(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	saveTestHistory(t, cfg, day, 28)
+
+	res, err := BacktestForecast(&cfg.Forecast, cfg.History(), day.AddDate(0, 0, -14), day)
+	require.NoError(t, err)
+	assert.Equal(t, 14, res.Days)
+	assert.Less(t, res.MAE, 0.1)
+	assert.Greater(t, res.Coverage, 0.0)
+
+	_, err = BacktestForecast(&cfg.Forecast, cfg.History(), day, day.AddDate(0, 0, 1))
+	assert.True(t, errors.Is(err, ErrNotEnoughHistory))
+}
+
+// saveTestHistory stores the days before the day, where hour h costs 0.01*h and Fridays cost 0.1 more.
+func saveTestHistory(t *testing.T, cfg *ConfigApp, day time.Time, days int) {
+	for i := 1; i <= days; i++ {
+		historyDay := day.AddDate(0, 0, -i)
+		prices := make([]decimal.Decimal, 24)
+		for h := range prices {
+			prices[h] = decimal.NewFromFloat(0.01 * float64(h))
+			if historyDay.Weekday() == time.Friday {
+				prices[h] = prices[h].Add(decimal.NewFromFloat(0.1))
+			}
+		}
+		require.NoError(t, cfg.History().Save(historyDay, prices))
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+var gotifyPriorities = map[int]int{pushPriorityLow: 2, pushPriorityDefault: 5, pushPriorityHigh: 8}
+
+// gotifyMessenger posts to a Gotify application, it can't take files,
+// so the chart is the PNG of the server when the public URL is set.
+type gotifyMessenger struct {
+	cfg       *ConfigGotify
+	publicURL string
+}
+
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras"`
+}
+
+func (messenger *gotifyMessenger) Send(message Message) error {
+	title, text := PlainMessage(message)
+	notification := map[string]any{}
+	if click := pushDayURL(messenger.publicURL, message, ""); click != "" {
+		notification["click"] = map[string]string{"url": click}
+		notification["bigImageUrl"] = pushDayURL(messenger.publicURL, message, "png")
+	}
+	body, err := json.Marshal(gotifyMessage{
+		Title:    pushTitle(message, title),
+		Message:  text,
+		Priority: gotifyPriorities[pushPriority(message)],
+		Extras: map[string]any{
+			"client::display":      map[string]string{"contentType": "text/plain"},
+			"client::notification": notification,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Gotify-Key", messenger.cfg.Token)
+	return pushRequest(http.MethodPost, strings.TrimSuffix(messenger.cfg.URL, "/")+"/message", header, bytes.NewReader(body))
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const historyCollection = "prices"
+
+// PriceHistory keeps the loaded day prices, the key is the day in the config location.
+type PriceHistory struct {
+	storage  Storage
+	location *time.Location
+}
+
+// HistoryDay is a stored day with its prices.
+type HistoryDay struct {
+	Day    time.Time
+	Prices []decimal.Decimal
+}
+
+func NewPriceHistory(storage Storage, location *time.Location) *PriceHistory {
+	return &PriceHistory{storage: storage, location: location}
+}
+
+func (history *PriceHistory) Save(day time.Time, prices []decimal.Decimal) error {
+	return history.storage.Save(historyCollection, day.In(history.location).Format("2006-01-02"), prices)
+}
+
+func (history *PriceHistory) Load(day time.Time) (prices []decimal.Decimal, ok bool, err error) {
+	ok, err = history.storage.Load(historyCollection, day.In(history.location).Format("2006-01-02"), &prices)
+	return
+}
+
+// Range returns the stored days from "from" up to, but not including, "till".
+func (history *PriceHistory) Range(from, till time.Time) (res []HistoryDay, err error) {
+	keys, err := history.storage.Keys(historyCollection)
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		day, errParse := time.ParseInLocation("2006-01-02", key, history.location)
+		if errParse != nil || day.Before(from) || !day.Before(till) {
+			continue
+		}
+		var prices []decimal.Decimal
+		if _, err = history.storage.Load(historyCollection, key, &prices); err != nil {
+			return
+		}
+		res = append(res, HistoryDay{Day: day, Prices: prices})
+	}
+	return
+}
+
+// LoadPrices returns the day prices from the history and fetches them only when they aren't stored yet.
+func LoadPrices(cfg *ConfigApp, day time.Time) (prices []decimal.Decimal, err error) {
+	prices, ok, err := cfg.History().Load(day)
+	if ok || err != nil {
+		return
+	}
+	if prices, err = FetchPrices(&cfg.Loader, day); err != nil {
+		return
+	}
+	err = cfg.History().Save(day, prices)
+	return
+}
+
+// RevisePrices fetches the stored day again and replaces the prices when they were corrected,
+// revised is false when the day isn't stored yet or the prices are the same.
+func RevisePrices(cfg *ConfigApp, day time.Time) (previous, prices []decimal.Decimal, revised bool, err error) {
+	previous, ok, err := cfg.History().Load(day)
+	if !ok || err != nil {
+		return
+	}
+	if prices, err = FetchPrices(&cfg.Loader, day); err != nil {
+		return
+	}
+	// Nothing published is the loader failure rather than the revision.
+	if len(prices) == 0 || len(priceChanges(previous, prices, day)) == 0 {
+		return
+	}
+	return previous, prices, true, cfg.History().Save(day, prices)
+}
+
+// loadTomorrowPrices returns tomorrow's prices when they are known,
+// they aren't fetched before they are published.
+func loadTomorrowPrices(cfg *ConfigApp, tomorrow, now time.Time) []decimal.Decimal {
+	prices, ok, _ := cfg.History().Load(tomorrow)
+	if !ok && now.Hour() >= cfg.TomorrowHourMin() {
+		prices, _ = LoadPrices(cfg, tomorrow)
+	}
+	return prices
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceHistoryRange(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cfg.History().Save(day.AddDate(0, 0, i), []decimal.Decimal{decimal.NewFromInt(int64(i))}))
+	}
+
+	days, err := cfg.History().Range(day.AddDate(0, 0, 1), day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, day.AddDate(0, 0, 1), days[0].Day)
+	assert.Equal(t, "2", days[1].Prices[0].String())
+}
+
+func TestLoadPrices(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+
+	prices, err := LoadPrices(cfg, day)
+	require.NoError(t, err)
+	assert.Len(t, prices, 24)
+
+	// The second load comes from the history.
+	cfg.Loader.Driver = "broken"
+	stored, err := LoadPrices(cfg, day)
+	require.NoError(t, err)
+	assert.Equal(t, prices[0].String(), stored[0].String())
+
+	_, err = LoadPrices(cfg, day.AddDate(0, 0, 1))
+	assert.Error(t, err)
+}
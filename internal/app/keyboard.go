@@ -0,0 +1,158 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+)
+
+// Views of the day keyboard, the callback data is "<view>:<date>" or "cheapest:<date>:<hours>".
+const (
+	callbackDay      = "day"
+	callbackCheapest = "cheapest"
+	callbackChart    = "chart"
+	callbackStats    = "stats"
+)
+
+// Lengths in hours of the cheapest windows on the keyboard.
+var keyboardCheapestHours = []int{2, 3, 4}
+
+var ErrUnknownCallback = errors.New("unknown callback data")
+
+// DayKeyboard returns the navigation buttons of the day message in the language of the templates,
+// with the public URL of the server the chart page opens in the browser.
+func DayKeyboard(cfg *ConfigMessenger, templates *MessageTemplates, day time.Time) (keyboard [][]Button, err error) {
+	labels := map[string]string{}
+	for _, name := range []string{"button.previous", "button.next", "button.chart", "button.stats", "button.browser"} {
+		if labels[name], err = templates.Render(name, TemplateData{Day: day}); err != nil {
+			return nil, err
+		}
+	}
+	date := day.Format("2006-01-02")
+	cheapest := make([]Button, len(keyboardCheapestHours))
+	for i, hours := range keyboardCheapestHours {
+		label, errLabel := templates.Render("button.cheapest", TemplateData{Day: day, Window: models.CheapWindow{Hours: hours}})
+		if errLabel != nil {
+			return nil, errLabel
+		}
+		cheapest[i] = Button{Text: label, Data: fmt.Sprintf("%s:%s:%d", callbackCheapest, date, hours)}
+	}
+	keyboard = [][]Button{
+		{
+			{Text: labels["button.previous"], Data: callbackDay + ":" + day.AddDate(0, 0, -1).Format("2006-01-02")},
+			{Text: labels["button.next"], Data: callbackDay + ":" + day.AddDate(0, 0, 1).Format("2006-01-02")},
+		},
+		cheapest,
+		{
+			{Text: labels["button.chart"], Data: callbackChart + ":" + date},
+			{Text: labels["button.stats"], Data: callbackStats + ":" + date},
+		},
+	}
+	if cfg.PublicURL != "" {
+		keyboard = append(keyboard, []Button{{Text: labels["button.browser"], URL: strings.TrimSuffix(cfg.PublicURL, "/") + "/day-prices/" + date}})
+	}
+	return keyboard, nil
+}
+
+// BotCallbackReply builds the view of the pressed button in the language of the chat, it replaces the message.
+// The future days without published prices are ErrNoPrices.
+func BotCallbackReply(cfg *ConfigApp, chatID int64, data string, now time.Time) (reply Message, err error) {
+	parts := strings.Split(data, ":")
+	if len(parts) < 2 {
+		return reply, ErrUnknownCallback
+	}
+	day, err := time.ParseInLocation("2006-01-02", parts[1], cfg.Location())
+	if err != nil {
+		return reply, ErrUnknownCallback
+	}
+	if !botKnownDay(cfg, day, now) {
+		return reply, ErrNoPrices
+	}
+	if parts[0] == callbackDay {
+		return botDayReply(cfg, chatID, day, now)
+	}
+
+	prices, err := LoadPrices(cfg, day)
+	if err != nil {
+		return
+	}
+	subscription, _, err := cfg.Subscriptions().Load(chatID)
+	if err != nil {
+		return
+	}
+	// The views show the prices of the subscriber tariff like the day message does.
+	prices = subscription.Prices(&cfg.Tariff, prices)
+	analytics := subscription.Analytics(&cfg.Analytics)
+	templates := cfg.MessageTemplates(subscription.Language)
+	locale := templates.Locale()
+	title, err := templates.Render("day.title", TemplateData{Day: day})
+	if err != nil {
+		return
+	}
+	reply.Document.Heading(title)
+
+	switch parts[0] {
+	case callbackCheapest:
+		hours, errHours := strconv.Atoi(parts[len(parts)-1])
+		if len(parts) != 3 || errHours != nil || hours < 1 || hours > len(prices) {
+			return reply, ErrUnknownCallback
+		}
+		start, average := cheapestWindow(prices, hours)
+		from := day.Add(time.Duration(start) * time.Hour)
+		window := models.CheapWindow{Hours: hours, Start: from, End: from.Add(time.Duration(hours) * time.Hour), Average: average}
+		text, errText := templates.Render("cheapest", TemplateData{Day: day, Window: window})
+		if errText != nil {
+			return reply, errText
+		}
+		reply.Document.Strong(text)
+		// The chart of the window hours only.
+		chart, errChart := ChartText(&analytics, locale, prices, day)
+		if errChart != nil {
+			return reply, errChart
+		}
+		chart.Rows = chart.Rows[start : start+hours]
+		reply.Document.Add(chart)
+	case callbackChart:
+		chart, errChart := ChartText(&analytics, locale, prices, day)
+		if errChart != nil {
+			return reply, errChart
+		}
+		reply.Document.Add(chart)
+		reply.Document.Add(Block{Kind: BlockContext, Text: strings.Join(analytics.TierLegend(locale), "   ")})
+	case callbackStats:
+		reply.Document.Add(Block{Kind: BlockFields, Fields: dayStatsFields(locale, dayStats(prices))})
+	default:
+		return reply, ErrUnknownCallback
+	}
+
+	image, err := ChartPNG(&analytics, prices, day)
+	if err != nil {
+		return
+	}
+	reply.Attachments = []Attachment{{
+		Kind:     AttachmentPhoto,
+		Name:     fmt.Sprintf("epex_nl_%s.png", day.Format("2006-01-02")),
+		MimeType: "image/png",
+		Data:     image,
+	}}
+	reply.Keyboard, err = DayKeyboard(&cfg.Messenger, templates, day)
+	return
+}
+
+// botKnownDay tells if the day prices can be shown: the past days, today and tomorrow once published.
+func botKnownDay(cfg *ConfigApp, day, now time.Time) bool {
+	now = now.In(cfg.Location())
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, cfg.Location())
+	if day.Before(tomorrow) {
+		return true
+	}
+	if !day.Equal(tomorrow) {
+		return false
+	}
+	_, ok, _ := cfg.History().Load(day)
+	return ok || now.Hour() >= cfg.TomorrowHourMin()
+}
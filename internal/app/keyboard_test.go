@@ -0,0 +1,149 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDayKeyboard(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+
+	keyboard, err := DayKeyboard(&cfg.Messenger, cfg.MessageTemplates(""), day)
+	require.NoError(t, err)
+	require.Len(t, keyboard, 3)
+	assert.Equal(t, Button{Text: "◀ Previous day", Data: "day:2025-02-28"}, keyboard[0][0])
+	assert.Equal(t, Button{Text: "Next day ▶", Data: "day:2025-03-02"}, keyboard[0][1])
+	assert.Equal(t, Button{Text: "Cheapest 3h", Data: "cheapest:2025-03-01:3"}, keyboard[1][1])
+	assert.Equal(t, Button{Text: "Stats", Data: "stats:2025-03-01"}, keyboard[2][1])
+
+	cfg.Messenger.PublicURL = "https://prices.example.com/"
+	keyboard, err = DayKeyboard(&cfg.Messenger, cfg.MessageTemplates(""), day)
+	require.NoError(t, err)
+	require.Len(t, keyboard, 4)
+	assert.Equal(t, "https://prices.example.com/day-prices/2025-03-01", keyboard[3][0].URL)
+
+	keyboard, err = DayKeyboard(&cfg.Messenger, cfg.MessageTemplates("nl"), day)
+	require.NoError(t, err)
+	assert.Equal(t, Button{Text: "◀ Vorige dag", Data: "day:2025-02-28"}, keyboard[0][0])
+	assert.Equal(t, Button{Text: "Goedkoopste 3u", Data: "cheapest:2025-03-01:3"}, keyboard[1][1])
+	assert.Equal(t, "Openen in browser", keyboard[3][0].Text)
+}
+
+func TestBotCallbackReply(t *testing.T) {
+	cfg := generateBotTestConfig()
+	now := time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())
+
+	reply, err := BotCallbackReply(cfg, 42, "cheapest:2025-02-28:3", now)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"*EPEX NL Day\\-Ahead 2025\\-02\\-28*\n*Cheapest 3h: 12:00\\-15:00, average 0\\.007*\n"+
+			"`12:00` ██████████ 🟢 0\\.04\n`13:00` ████ 🟢 0\\.00\n`14:00` █ 🟢 \\-0\\.02",
+		reply.Document.Format(FormatMarkdownV2),
+	)
+	require.Len(t, reply.Attachments, 1)
+	assert.Equal(t, "cheapest:2025-02-28:2", reply.Keyboard[1][0].Data)
+
+	reply, err = BotCallbackReply(cfg, 42, "stats:2025-02-28", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatPlain), "\nLowest: -0.020 at 14:00\n")
+
+	reply, err = BotCallbackReply(cfg, 42, "chart:2025-02-28", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatPlain), "23:00")
+
+	reply, err = BotCallbackReply(cfg, 42, "day:2025-02-27", now)
+	require.NoError(t, err)
+	assert.Equal(t, "EPEX NL Day-Ahead 2025-02-27", reply.Document.Title())
+	assert.Equal(t, "day:2025-02-28", reply.Keyboard[0][1].Data)
+
+	// Tomorrow's prices aren't published yet.
+	_, err = BotCallbackReply(cfg, 42, "day:2025-03-01", now)
+	assert.Equal(t, ErrNoPrices, err)
+	_, err = BotCallbackReply(cfg, 42, "day:2025-03-01", now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	_, err = BotCallbackReply(cfg, 42, "day:2025-03-02", now.Add(2*time.Hour))
+	assert.Equal(t, ErrNoPrices, err)
+
+	for _, data := range []string{"day", "day:yesterday", "cheapest:2025-02-28:25", "zoom:2025-02-28"} {
+		_, err = BotCallbackReply(cfg, 42, data, now)
+		assert.Equal(t, ErrUnknownCallback, err, data)
+	}
+
+	// The subscriber sees the views in the own language and tariff, the dynamic one adds 0.12.
+	subscription := cfg.NewSubscription(43)
+	require.NoError(t, subscription.Set("language", "nl"))
+	require.NoError(t, subscription.Set("tariff", TariffProfileDynamic))
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+	reply, err = BotCallbackReply(cfg, 43, "cheapest:2025-02-28:3", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatPlain), "\nGoedkoopste 3u: 12:00-15:00, gemiddeld 0,127\n")
+	assert.Equal(t, "◀ Vorige dag", reply.Keyboard[0][0].Text)
+	reply, err = BotCallbackReply(cfg, 43, "stats:2025-02-28", now)
+	require.NoError(t, err)
+	assert.Contains(t, reply.Document.Format(FormatPlain), "\nLaagste: 0,100 om 14:00\n")
+}
+
+func TestBot_HandleCallback(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	bot, err := NewBot(cfg)
+	require.NoError(t, err)
+	bot.now = func() time.Time {
+		return time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())
+	}
+	callback := func(data string, photo bool) tgbotapi.Update {
+		message := &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 42}}
+		if photo {
+			message.Photo = []tgbotapi.PhotoSize{{FileID: "chart"}}
+		}
+		return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "query", Message: message, Data: data}}
+	}
+
+	require.NoError(t, bot.HandleUpdate(callback("stats:2025-02-28", true)))
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "editMessageMedia", requests[0].Method)
+	assert.Equal(t, "7", requests[0].Values["message_id"])
+	assert.Contains(t, requests[0].Values["media"], "Lowest")
+	assert.Contains(t, requests[0].Values["reply_markup"], `"callback_data":"stats:2025-02-28"`)
+	assert.Equal(t, "answerCallbackQuery", requests[1].Method)
+	assert.Equal(t, "", requests[1].Values["text"])
+
+	// The text messages stay text.
+	require.NoError(t, bot.HandleUpdate(callback("cheapest:2025-02-28:2", false)))
+	requests = telegram.Requests()
+	require.Len(t, requests, 4)
+	assert.Equal(t, "editMessageText", requests[2].Method)
+	assert.Contains(t, requests[2].Values["text"], "Cheapest 2h")
+
+	require.NoError(t, bot.HandleUpdate(callback("day:2025-03-01", true)))
+	requests = telegram.Requests()
+	require.Len(t, requests, 5)
+	assert.Equal(t, "answerCallbackQuery", requests[4].Method)
+	assert.Contains(t, requests[4].Values["text"], "No prices yet")
+}
+
+func TestSendTelegram_Keyboard(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+
+	// Without the bot updates nobody answers the buttons.
+	require.NoError(t, NotifyDay(cfg, day))
+	cfg.Messenger.Telegram.Updates = telegramUpdatesPolling
+	require.NoError(t, NotifyDay(cfg, day))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Empty(t, requests[0].Values["reply_markup"])
+	assert.Contains(t, requests[1].Values["reply_markup"], `"callback_data":"day:2025-03-01"`)
+}
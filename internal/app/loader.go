@@ -16,6 +16,8 @@ import (
 
 const fetchHttpTimeout = 10 * time.Second
 
+var ErrNoPrices = errors.New("no prices available")
+
 // fetchPrices function downloads and parses the prices from the driver
 func FetchPrices(cfg *ConfigLoader, startDate time.Time) ([]decimal.Decimal, error) {
 	switch cfg.Driver {
@@ -41,7 +43,7 @@ func fetchAsEnergyZero(cfg *ConfigLoader, startDate time.Time) (res []decimal.De
 		return
 	}
 	if len(data.Prices) == 0 {
-		err = errors.New("no prices available")
+		err = ErrNoPrices
 		return
 	}
 
@@ -81,7 +83,7 @@ func fetchByUrl(url string, data *models.PriceData) (err error) {
 	}
 
 	if len(data.Prices) == 0 {
-		err = errors.New("no prices available")
+		err = ErrNoPrices
 		return
 	}
 
@@ -49,11 +49,15 @@ func generateFakeConfig(serverUrl string) *ConfigLoader {
 }
 
 func generateFakeServer() *httptest.Server {
+	return generateFakeServerWithBody(`{"prices":[{"price":100.0},{"price":200.0}]}`)
+}
+
+func generateFakeServerWithBody(body string) *httptest.Server {
 	return httptest.NewServer(
 		http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
-				_, _ = w.Write([]byte(`{"prices":[{"price":100.0},{"price":200.0}]}`))
+				_, _ = w.Write([]byte(body))
 			},
 		),
 	)
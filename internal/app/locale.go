@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Languages of the message templates, the first one is the default.
+var languages = []string{"en", "nl", "uk"}
+
+// Locale formats the numbers and dates of the messages and translates the words used by the code,
+// the sentences are in the templates.
+type Locale struct {
+	Language         string
+	DecimalSeparator string
+	DateLayout       string
+	Weekdays         [7]string
+	Words            map[string]string
+}
+
+var locales = map[string]*Locale{
+	"en": {
+		Language:         "en",
+		DecimalSeparator: ".",
+		DateLayout:       "2006-01-02",
+		Weekdays:         [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		Words: map[string]string{
+			AlertHigh: "High", AlertLow: "Low", AlertNegative: "Negative",
+			"lowest": "Lowest", "highest": "Highest", "average": "Average", "at": "at",
+		},
+	},
+	"nl": {
+		Language:         "nl",
+		DecimalSeparator: ",",
+		DateLayout:       "02-01-2006",
+		Weekdays:         [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		Words: map[string]string{
+			AlertHigh: "hoge", AlertLow: "lage", AlertNegative: "negatieve",
+			"lowest": "Laagste", "highest": "Hoogste", "average": "Gemiddeld", "at": "om",
+		},
+	},
+	"uk": {
+		Language:         "uk",
+		DecimalSeparator: ",",
+		DateLayout:       "02.01.2006",
+		Weekdays:         [7]string{"неділя", "понеділок", "вівторок", "середа", "четвер", "пʼятниця", "субота"},
+		Words: map[string]string{
+			AlertHigh: "високі", AlertLow: "низькі", AlertNegative: "відʼємні",
+			"lowest": "Найнижча", "highest": "Найвища", "average": "Середня", "at": "о",
+		},
+	},
+}
+
+// LocaleFor returns the locale of the language, English when it's unknown.
+func LocaleFor(language string) *Locale {
+	if locale, ok := locales[language]; ok {
+		return locale
+	}
+	return locales[languages[0]]
+}
+
+// Number rounds the number to the decimal places with the decimal separator of the language.
+func (locale *Locale) Number(d decimal.Decimal, places int) string {
+	return strings.Replace(d.StringFixed(int32(places)), ".", locale.DecimalSeparator, 1)
+}
+
+// Price formats the price per kWh.
+func (locale *Locale) Price(d decimal.Decimal) string {
+	return locale.Number(d, 3)
+}
+
+func (locale *Locale) Date(t time.Time) string {
+	return t.Format(locale.DateLayout)
+}
+
+func (locale *Locale) Weekday(t time.Time) string {
+	return locale.Weekdays[t.Weekday()]
+}
+
+// Hour formats the hour of the day, as "13:00".
+func (locale *Locale) Hour(hour int) string {
+	return fmt.Sprintf("%02d:00", hour)
+}
+
+// Word translates the word, the unknown ones (e.g. the custom tier names) are kept.
+func (locale *Locale) Word(word string) string {
+	if translation, ok := locale.Words[word]; ok {
+		return translation
+	}
+	return word
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocale(t *testing.T) {
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	price := decimal.NewFromFloat(-0.0234)
+
+	en := LocaleFor("en")
+	assert.Equal(t, "-0.023", en.Price(price))
+	assert.Equal(t, "2025-02-28", en.Date(day))
+	assert.Equal(t, "Friday", en.Weekday(day))
+	assert.Equal(t, "High", en.Word(AlertHigh))
+	assert.Equal(t, "very_high", en.Word("very_high"))
+
+	nl := LocaleFor("nl")
+	assert.Equal(t, "-0,02", nl.Number(price, 2))
+	assert.Equal(t, "28-02-2025", nl.Date(day))
+	assert.Equal(t, "vrijdag", nl.Weekday(day))
+
+	uk := LocaleFor("uk")
+	assert.Equal(t, "28.02.2025", uk.Date(day))
+	assert.Equal(t, "пʼятниця", uk.Weekday(day))
+
+	assert.Equal(t, en, LocaleFor("fr"))
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const matrixHttpTimeout = 10 * time.Second
+
+const matrixErrLimitExceeded = "M_LIMIT_EXCEEDED"
+
+// MatrixError is an error response of the homeserver, RetryAfter is set when the request is rate limited.
+type MatrixError struct {
+	Status     int
+	ErrCode    string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (err *MatrixError) Error() string {
+	if err.ErrCode == "" {
+		return fmt.Sprintf("Matrix status %d: %s", err.Status, err.Message)
+	}
+	return fmt.Sprintf("Matrix %s (status %d): %s", err.ErrCode, err.Status, err.Message)
+}
+
+// matrixMessenger sends m.text events with the HTML formatted body to the room,
+// the photos are uploaded to the media repository and sent as m.image events before the text.
+type matrixMessenger struct {
+	cfg *ConfigMatrix
+}
+
+type matrixImageInfo struct {
+	MimeType string `json:"mimetype,omitempty"`
+	Size     int    `json:"size"`
+	Width    int    `json:"w,omitempty"`
+	Height   int    `json:"h,omitempty"`
+}
+
+type matrixEvent struct {
+	MsgType       string           `json:"msgtype"`
+	Body          string           `json:"body"`
+	Format        string           `json:"format,omitempty"`
+	FormattedBody string           `json:"formatted_body,omitempty"`
+	URL           string           `json:"url,omitempty"`
+	Info          *matrixImageInfo `json:"info,omitempty"`
+}
+
+func (messenger *matrixMessenger) Send(message Message) error {
+	// The transaction IDs of the delivery key make the retried events idempotent, also the retries of the outbox.
+	txn := message.Key
+	if txn == "" {
+		txn = webhookDeliveryID()
+	}
+	doc := message.Document
+	if slices.ContainsFunc(message.Attachments, func(attachment Attachment) bool { return attachment.Kind == AttachmentPhoto }) {
+		// The chart image shows the details.
+		doc = doc.Summary()
+	}
+	for i, attachment := range message.Attachments {
+		if attachment.Kind != AttachmentPhoto {
+			continue
+		}
+		uri, err := messenger.upload(attachment)
+		if err != nil {
+			return err
+		}
+		info := &matrixImageInfo{MimeType: attachment.MimeType, Size: len(attachment.Data)}
+		if config, _, errDecode := image.DecodeConfig(bytes.NewReader(attachment.Data)); errDecode == nil {
+			info.Width, info.Height = config.Width, config.Height
+		}
+		event := matrixEvent{MsgType: "m.image", Body: attachment.Name, URL: uri, Info: info}
+		if err = messenger.sendEvent(fmt.Sprintf("%s-%d", txn, i), event); err != nil {
+			return err
+		}
+	}
+
+	return messenger.sendEvent(txn, matrixEvent{
+		MsgType:       "m.text",
+		Body:          FormatPlain.Format(doc),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: FormatHTML.Format(doc),
+	})
+}
+
+// upload puts the file into the media repository and returns its mxc:// URI.
+func (messenger *matrixMessenger) upload(attachment Attachment) (string, error) {
+	endpoint := "/_matrix/media/v3/upload?filename=" + url.QueryEscape(attachment.Name)
+	var res struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := messenger.request(http.MethodPost, endpoint, attachment.MimeType, attachment.Data, &res); err != nil {
+		return "", fmt.Errorf("failed to upload %s to Matrix: %w", attachment.Name, err)
+	}
+	return res.ContentURI, nil
+}
+
+func (messenger *matrixMessenger) sendEvent(txn string, event matrixEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(messenger.cfg.RoomID), url.PathEscape(txn))
+	if err = messenger.request(http.MethodPut, endpoint, "application/json", body, nil); err != nil {
+		return fmt.Errorf("failed to send %s to Matrix: %w", event.MsgType, err)
+	}
+	return nil
+}
+
+// request retries the rate-limited requests, waiting as long as the homeserver asks up to MaxWait.
+func (messenger *matrixMessenger) request(method, endpoint, contentType string, body []byte, res any) (err error) {
+	for attempt := 0; ; attempt++ {
+		if err = messenger.requestOnce(method, endpoint, contentType, body, res); err == nil {
+			return
+		}
+		var matrixErr *MatrixError
+		if !errors.As(err, &matrixErr) || matrixErr.RetryAfter == 0 || attempt >= messenger.cfg.Retries ||
+			matrixErr.RetryAfter > messenger.cfg.MaxWait {
+			return
+		}
+		log.Printf("Matrix rate limit, retrying in %s\n", matrixErr.RetryAfter)
+		time.Sleep(matrixErr.RetryAfter)
+	}
+}
+
+func (messenger *matrixMessenger) requestOnce(method, endpoint, contentType string, body []byte, res any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), matrixHttpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(messenger.cfg.HomeserverURL, "/")+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+messenger.cfg.AccessToken)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return matrixResponseError(resp, data)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(data, res)
+}
+
+// matrixResponseError reads the standard error body, the wait of the rate limit is
+// retry_after_ms of the body or the Retry-After header in seconds.
+func matrixResponseError(resp *http.Response, data []byte) error {
+	var body struct {
+		ErrCode      string `json:"errcode"`
+		Error        string `json:"error"`
+		RetryAfterMs int64  `json:"retry_after_ms"`
+	}
+	err := &MatrixError{Status: resp.StatusCode}
+	if json.Unmarshal(data, &body) == nil && body.ErrCode != "" {
+		err.ErrCode, err.Message = body.ErrCode, body.Error
+	} else {
+		err.Message = strings.TrimSpace(string(data))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || err.ErrCode == matrixErrLimitExceeded {
+		err.RetryAfter = time.Duration(body.RetryAfterMs) * time.Millisecond
+		if seconds, errParse := strconv.Atoi(resp.Header.Get("Retry-After")); errParse == nil && seconds > 0 {
+			err.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		if err.RetryAfter == 0 {
+			err.RetryAfter = time.Second
+		}
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHomeserverRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Auth   string
+	Body   []byte
+}
+
+// fakeHomeserver stands in for the client-server API, the queued limits answer the next requests with 429.
+type fakeHomeserver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []fakeHomeserverRequest
+	limits   []string
+}
+
+func newFakeHomeserver() *fakeHomeserver {
+	homeserver := &fakeHomeserver{}
+	homeserver.Server = httptest.NewServer(http.HandlerFunc(homeserver.handle))
+	return homeserver
+}
+
+func (homeserver *fakeHomeserver) Requests() []fakeHomeserverRequest {
+	homeserver.mu.Lock()
+	defer homeserver.mu.Unlock()
+	return append([]fakeHomeserverRequest{}, homeserver.requests...)
+}
+
+func (homeserver *fakeHomeserver) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	homeserver.mu.Lock()
+	defer homeserver.mu.Unlock()
+	homeserver.requests = append(homeserver.requests, fakeHomeserverRequest{
+		Method: r.Method, Path: r.URL.EscapedPath(), Query: r.URL.RawQuery, Auth: r.Header.Get("Authorization"), Body: body,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token"}`))
+		return
+	}
+	if len(homeserver.limits) > 0 {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(homeserver.limits[0]))
+		homeserver.limits = homeserver.limits[1:]
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/_matrix/media/v3/upload"):
+		_, _ = w.Write([]byte(`{"content_uri":"mxc://example.com/chart"}`))
+	case strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/"):
+		_, _ = w.Write([]byte(`{"event_id":"$event"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errcode":"M_UNRECOGNIZED","error":"Unrecognized request"}`))
+	}
+}
+
+func generateMatrixTestConfig(homeserver *fakeHomeserver) *ConfigMatrix {
+	return &ConfigMatrix{HomeserverURL: homeserver.URL + "/", AccessToken: "token", RoomID: "!room:example.com", Retries: 2, MaxWait: time.Second}
+}
+
+func TestMatrixMessenger(t *testing.T) {
+	homeserver := newFakeHomeserver()
+	defer homeserver.Close()
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, day)
+	require.NoError(t, err)
+
+	messenger := &matrixMessenger{cfg: generateMatrixTestConfig(homeserver)}
+	require.NoError(t, messenger.Send(message))
+
+	requests := homeserver.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, "POST", requests[0].Method)
+	assert.Equal(t, "/_matrix/media/v3/upload", requests[0].Path)
+	assert.Equal(t, "filename=epex_nl_2025-02-28.png", requests[0].Query)
+	assert.Equal(t, "Bearer token", requests[0].Auth)
+	assert.Equal(t, message.Attachments[0].Data, requests[0].Body)
+
+	assert.Equal(t, "PUT", requests[1].Method)
+	assert.True(t, strings.HasPrefix(requests[1].Path, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/"), requests[1].Path)
+	var image matrixEvent
+	require.NoError(t, json.Unmarshal(requests[1].Body, &image))
+	assert.Equal(t, "m.image", image.MsgType)
+	assert.Equal(t, "mxc://example.com/chart", image.URL)
+	require.NotNil(t, image.Info)
+	assert.Equal(t, "image/png", image.Info.MimeType)
+	assert.Equal(t, len(message.Attachments[0].Data), image.Info.Size)
+	assert.True(t, image.Info.Width > 0)
+
+	var text matrixEvent
+	require.NoError(t, json.Unmarshal(requests[2].Body, &text))
+	assert.Equal(t, "m.text", text.MsgType)
+	assert.Equal(t, "org.matrix.custom.html", text.Format)
+	assert.True(t, strings.HasPrefix(text.Body, "EPEX NL Day-Ahead 2025-02-28\nThere are Low prices\n"), text.Body)
+	assert.Contains(t, text.FormattedBody, "<h2>EPEX NL Day-Ahead 2025-02-28</h2>\n<p><b>There are Low prices</b></p>\n<pre>00:00 ")
+	// The stats and the legend are on the chart image.
+	assert.NotContains(t, text.Body, "Lowest")
+	assert.Contains(t, text.Body, "14:00 █ 🟢 -0.02\n")
+	assert.NotEqual(t, requests[1].Path, requests[2].Path)
+
+	// The delivery key gives the same transaction IDs to the next attempt.
+	message.Key = "prices-2025-02-28.matrix"
+	require.NoError(t, messenger.Send(message))
+	requests = homeserver.Requests()
+	require.Len(t, requests, 6)
+	assert.True(t, strings.HasSuffix(requests[4].Path, "/send/m.room.message/prices-2025-02-28.matrix-0"), requests[4].Path)
+	assert.True(t, strings.HasSuffix(requests[5].Path, "/send/m.room.message/prices-2025-02-28.matrix"), requests[5].Path)
+}
+
+func TestMatrixMessenger_RateLimit(t *testing.T) {
+	homeserver := newFakeHomeserver()
+	defer homeserver.Close()
+	homeserver.limits = []string{`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":20}`}
+	messenger := &matrixMessenger{cfg: generateMatrixTestConfig(homeserver)}
+
+	require.NoError(t, messenger.Send(Message{Document: TextDocument("No prices for 2025-03-01")}))
+	requests := homeserver.Requests()
+	require.Len(t, requests, 2)
+	// The retry keeps the transaction ID, so the homeserver can't duplicate the event.
+	assert.Equal(t, requests[0].Path, requests[1].Path)
+	var text matrixEvent
+	require.NoError(t, json.Unmarshal(requests[1].Body, &text))
+	assert.Equal(t, "No prices for 2025-03-01", text.Body)
+
+	// A longer wait is left to the outbox.
+	homeserver.limits = []string{`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":30000}`}
+	err := messenger.Send(Message{Document: TextDocument("Error for 2025-03-01")})
+	require.Error(t, err)
+	assert.Equal(t, 30*time.Second, retryAfter(err))
+	assert.Len(t, homeserver.Requests(), 3)
+}
+
+func TestMatrixMessenger_Error(t *testing.T) {
+	homeserver := newFakeHomeserver()
+	defer homeserver.Close()
+	cfg := generateMatrixTestConfig(homeserver)
+	cfg.AccessToken = "wrong"
+
+	err := (&matrixMessenger{cfg: cfg}).Send(Message{Document: TextDocument("Error for 2025-03-01")})
+	require.Error(t, err)
+	assert.Equal(t, "failed to send m.text to Matrix: Matrix M_UNKNOWN_TOKEN (status 401): Invalid access token", err.Error())
+	assert.Zero(t, retryAfter(err))
+}
+
+func TestOutbox_MatrixRateLimit(t *testing.T) {
+	homeserver := newFakeHomeserver()
+	defer homeserver.Close()
+	homeserver.limits = []string{`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":45000}`}
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	cfg.Messenger = ConfigMessenger{Driver: messengerDriverMatrix, Matrix: *generateMatrixTestConfig(homeserver)}
+	cfg.Storage = ConfigStorage{Driver: storageDriverFile, Path: t.TempDir()}
+	require.NoError(t, cfg.SelfCheck())
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	_, err := cfg.Queue().Enqueue("error-2025-03-01", Message{Class: MessageClassError, Document: TextDocument("Error for 2025-03-01")}, now)
+	require.NoError(t, err)
+	cfg.Queue().Process(now)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 0, pending[0].Attempts)
+	assert.WithinDuration(t, now.Add(45*time.Second), pending[0].NextAttempt, 0)
+
+	cfg.Queue().Process(now.Add(45 * time.Second))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	requests := homeserver.Requests()
+	require.Len(t, requests, 2)
+	// The transaction ID is the item ID, so the retried event isn't duplicated.
+	assert.Equal(t, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/error-2025-03-01.main", requests[0].Path)
+	assert.Equal(t, requests[0].Path, requests[1].Path)
+}
+
+func TestConfigSelfCheck_Matrix(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger = ConfigMessenger{Driver: messengerDriverMatrix, Matrix: ConfigMatrix{HomeserverURL: "https://matrix.example.com", AccessToken: "token"}}
+	assert.Error(t, cfg.SelfCheck())
+	cfg.Messenger.Matrix.RoomID = "!room:example.com"
+	assert.NoError(t, cfg.SelfCheck())
+	cfg.Messenger.Matrix.Retries = -1
+	assert.Error(t, cfg.SelfCheck())
+}
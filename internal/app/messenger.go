@@ -2,39 +2,417 @@ package app
 
 import (
 	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"net/http"
 	"strings"
 )
 
+// Telegram limits, in characters.
+const telegramTextLimit = 4096
+const telegramCaptionLimit = 1024
+const telegramMediaGroupLimit = 10
+
+const (
+	AttachmentPhoto    = "photo"
+	AttachmentDocument = "document"
+)
+
+// Message classes the destinations filter by.
+const (
+	MessageClassPrices   = "prices"
+	MessageClassNoPrices = "no-prices"
+	MessageClassError    = "error"
+	MessageClassSummary  = "summary"
+	MessageClassRevision = "revision"
+	MessageClassAdmin    = "admin"
+)
+
+var messageClasses = []string{MessageClassPrices, MessageClassNoPrices, MessageClassError, MessageClassSummary, MessageClassRevision, MessageClassAdmin}
+
 var ErrUnknownMessengerDriver = errors.New("unknown messenger driver")
+var ErrNotEditable = errors.New("message can't be edited")
 
-func SendMessage(cfg *ConfigMessenger, message string) (err error) {
-	switch cfg.Driver {
-	case messengerDriverTelegram:
-		err = sendTelegram(&cfg.Telegram, message)
-	default:
-		err = ErrUnknownMessengerDriver
+var markdownV2Replacer = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`",
+	">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// Attachment is a file sent with the message: a photo (PNG chart) or a document (HTML chart, CSV export).
+type Attachment struct {
+	Kind     string
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// Button of the inline keyboard under the message, it opens the URL or sends the callback data to the bot.
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"data,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Message is the document with optional attachments and keyboard,
+// a message without the class goes only to the destinations without a filter.
+// Day is set for the day prices, so the drivers can use the structured data.
+type Message struct {
+	Class       string
+	Document    Document
+	Attachments []Attachment
+	Keyboard    [][]Button
+	Day         *DayReport
+	// Key is the idempotency key of the delivery, the outbox item ID, empty when the message isn't delivered by the outbox.
+	Key string
+}
+
+// SendMessage sends the plain text.
+func SendMessage(cfg *ConfigMessenger, text string) error {
+	return SendRichMessage(cfg, Message{Document: TextDocument(text)})
+}
+
+// Receipt identifies the Telegram messages of a sent message, so they can be edited.
+type Receipt struct {
+	ChatID int64 `json:"chat_id"`
+	// TextIDs are the messages with the text parts, none when the text is the caption of the photo.
+	TextIDs []int `json:"text_ids,omitempty"`
+	// PhotoID is the single photo message, zero without a photo or with a media group.
+	PhotoID int `json:"photo_id,omitempty"`
+	// MediaGroups and Documents count the sent ones, so an interrupted send is resumed after them.
+	MediaGroups int `json:"media_groups,omitempty"`
+	Documents   int `json:"documents,omitempty"`
+}
+
+// SendRichMessage sends the message to every destination of the messenger, the error joins the failed ones.
+func SendRichMessage(cfg *ConfigMessenger, message Message) error {
+	dispatcher, err := NewDispatcher(cfg)
+	if err != nil {
+		return err
 	}
+	return DeliveryError(dispatcher.Dispatch(message))
+}
+
+// SendChatMessage sends the message to the chat instead of the configured one,
+// the chats are the subscribers of the main Telegram bot, so the destinations are skipped.
+func SendChatMessage(cfg *ConfigMessenger, chatID int64, message Message) error {
+	return chatMessenger(cfg, chatID).Send(message)
+}
+
+// chatMessenger returns the main Telegram bot messenger sending to the chat.
+func chatMessenger(cfg *ConfigMessenger, chatID int64) *telegramMessenger {
+	chatCfg := cfg.Telegram
+	chatCfg.ChatID = chatID
+	return &telegramMessenger{cfg: &chatCfg}
+}
 
+// PlainMessage returns the title and the text without any markup,
+// the text is the title when the document has nothing else.
+func PlainMessage(message Message) (title, text string) {
+	doc := message.Document
+	title = doc.Title()
+	if doc.hasHeading() {
+		text = FormatPlain.Format(doc.Body())
+	} else {
+		_, text, _ = strings.Cut(FormatPlain.Format(doc), "\n")
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		text = title
+	}
 	return
 }
 
-func sendTelegram(cfg *ConfigTelegram, message string) (err error) {
-	client, err := tgbotapi.NewBotAPI(cfg.Token)
-	if err != nil {
+// EscapeMarkdownV2 escapes all characters reserved by Telegram MarkdownV2.
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Replacer.Replace(text)
+}
+
+func newTelegramClient(cfg *ConfigTelegram) (client *tgbotapi.BotAPI, err error) {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+	if client, err = tgbotapi.NewBotAPIWithClient(cfg.Token, endpoint, &http.Client{}); err != nil {
 		err = errors.New("error creating Telegram Bot: " + err.Error())
-		return
 	}
+	return
+}
+
+func sendTelegram(cfg *ConfigTelegram, message Message, resume Receipt) (receipt Receipt, err error) {
+	client, err := newTelegramClient(cfg)
+	if err != nil {
+		return resume, err
+	}
+	return sendTelegramMessage(client, cfg.ChatID, cfg.ParseMode, telegramBotMessage(cfg, message), resume)
+}
+
+// telegramBotMessage drops the keyboard when the bot doesn't get the updates to answer it.
+func telegramBotMessage(cfg *ConfigTelegram, message Message) Message {
+	if cfg.Updates == "" {
+		message.Keyboard = nil
+	}
+	return message
+}
+
+// telegramKeyboard returns the inline keyboard markup of the buttons, nil without buttons.
+func telegramKeyboard(buttons [][]Button) *tgbotapi.InlineKeyboardMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	rows := make([][]tgbotapi.InlineKeyboardButton, len(buttons))
+	for i, row := range buttons {
+		for _, button := range row {
+			if button.URL != "" {
+				rows[i] = append(rows[i], tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL))
+			} else {
+				rows[i] = append(rows[i], tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+			}
+		}
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// telegramFormatter returns the formatter of the parse mode, MarkdownV2 by default.
+func telegramFormatter(parseMode string) (Formatter, string) {
+	if parseMode == tgbotapi.ModeHTML {
+		return FormatTelegramHTML, tgbotapi.ModeHTML
+	}
+	return FormatMarkdownV2, tgbotapi.ModeMarkdownV2
+}
+
+// sendTelegramMessage sends the text as the caption of the first attachment when it fits,
+// otherwise as separate messages before the attachments. The chart image makes the detail blocks redundant.
+// The keyboard goes under the last message, unless it's a media group.
+// The parts sent according to the resume receipt are skipped, the receipt is returned with the error too.
+func sendTelegramMessage(client *tgbotapi.BotAPI, chatID int64, parseMode string, message Message, resume Receipt) (receipt Receipt, err error) {
+	formatter, parseMode := telegramFormatter(parseMode)
+	text := message.Document.Summary().Format(formatter)
+	log.Printf("Sending messages to Telegram %d: %s\n", chatID, strings.Replace(text, "\n", " ", -1))
+	receipt = resume
+	receipt.ChatID = chatID
+
+	var photos []Attachment
+	var documents []Attachment
+	for _, attachment := range message.Attachments {
+		if attachment.Kind == AttachmentPhoto {
+			photos = append(photos, attachment)
+		} else {
+			documents = append(documents, attachment)
+		}
+	}
+	markup := telegramKeyboard(message.Keyboard)
 
-	log.Printf("Sending messages to Telegram: %s\n", strings.Replace(message, "\n", " ", -1))
+	caption := ""
+	if len(message.Attachments) > 0 && len([]rune(text)) <= telegramCaptionLimit {
+		caption = text
+	} else {
+		parts := splitTelegramText(text, telegramTextLimit)
+		for i, text := range parts {
+			if i < len(resume.TextIDs) {
+				continue
+			}
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.ParseMode = parseMode
+			if markup != nil && len(message.Attachments) == 0 && i == len(parts)-1 {
+				msg.ReplyMarkup = markup
+			}
+			sent, errSend := client.Send(msg)
+			if errSend != nil {
+				err = fmt.Errorf("error sending Telegram message: %w", errSend)
+				return
+			}
+			receipt.TextIDs = append(receipt.TextIDs, sent.MessageID)
+		}
+	}
 
-	msg := tgbotapi.NewMessage(cfg.ChatID, message)
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
-	if _, err = client.Send(msg); err != nil {
-		err = errors.New("error sending Telegram message: " + err.Error())
-		return
+	if len(photos) == 1 && receipt.PhotoID != 0 {
+		caption = ""
+	} else if len(photos) == 1 {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: photos[0].Name, Bytes: photos[0].Data})
+		photo.Caption, photo.ParseMode = caption, parseMode
+		if markup != nil && len(documents) == 0 {
+			photo.ReplyMarkup = markup
+		}
+		caption = ""
+		sent, errSend := client.Send(photo)
+		if errSend != nil {
+			err = fmt.Errorf("error sending Telegram photo: %w", errSend)
+			return
+		}
+		receipt.PhotoID = sent.MessageID
+	} else if len(photos) > 1 {
+		for start := 0; start < len(photos); start += telegramMediaGroupLimit {
+			end := start + telegramMediaGroupLimit
+			if end > len(photos) {
+				end = len(photos)
+			}
+			if start/telegramMediaGroupLimit < receipt.MediaGroups {
+				caption = ""
+				continue
+			}
+			var media []interface{}
+			for _, attachment := range photos[start:end] {
+				photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: attachment.Name, Bytes: attachment.Data})
+				if caption != "" {
+					photo.Caption, photo.ParseMode = caption, parseMode
+					caption = ""
+				}
+				media = append(media, photo)
+			}
+			if _, err = client.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media)); err != nil {
+				err = fmt.Errorf("error sending Telegram media group: %w", err)
+				return
+			}
+			receipt.MediaGroups++
+		}
+	}
+
+	for i, attachment := range documents {
+		if i < receipt.Documents {
+			caption = ""
+			continue
+		}
+		document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: attachment.Name, Bytes: attachment.Data})
+		document.Caption, document.ParseMode = caption, parseMode
+		if markup != nil && i == len(documents)-1 {
+			document.ReplyMarkup = markup
+		}
+		caption = ""
+		if _, err = client.Send(document); err != nil {
+			err = fmt.Errorf("error sending Telegram document: %w", err)
+			return
+		}
+		receipt.Documents++
 	}
 
 	return
 }
+
+// editTelegramMessage replaces the text, the photo and the keyboard of the sent message,
+// ErrNotEditable when the new message doesn't fit into the sent ones.
+func editTelegramMessage(client *tgbotapi.BotAPI, receipt Receipt, parseMode string, message Message) (err error) {
+	formatter, parseMode := telegramFormatter(parseMode)
+	text := message.Document.Summary().Format(formatter)
+	log.Printf("Editing messages of Telegram %d: %s\n", receipt.ChatID, strings.Replace(text, "\n", " ", -1))
+
+	var photos []Attachment
+	for _, attachment := range message.Attachments {
+		if attachment.Kind == AttachmentPhoto {
+			photos = append(photos, attachment)
+		}
+	}
+	if len(photos) > 1 || (receipt.PhotoID != 0) != (len(photos) == 1) {
+		return ErrNotEditable
+	}
+
+	caption := ""
+	if len(receipt.TextIDs) == 0 {
+		if receipt.PhotoID == 0 || len([]rune(text)) > telegramCaptionLimit {
+			return ErrNotEditable
+		}
+		caption = text
+	} else {
+		parts := splitTelegramText(text, telegramTextLimit)
+		if len(parts) != len(receipt.TextIDs) {
+			return ErrNotEditable
+		}
+		for i, part := range parts {
+			msg := tgbotapi.NewEditMessageText(receipt.ChatID, receipt.TextIDs[i], part)
+			msg.ParseMode = parseMode
+			if receipt.PhotoID == 0 && i == len(parts)-1 {
+				msg.ReplyMarkup = telegramKeyboard(message.Keyboard)
+			}
+			if _, err = client.Send(msg); err != nil && !telegramNotModified(err) {
+				return fmt.Errorf("error editing Telegram message: %w", err)
+			}
+		}
+	}
+
+	if receipt.PhotoID != 0 {
+		photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: photos[0].Name, Bytes: photos[0].Data})
+		photo.Caption, photo.ParseMode = caption, parseMode
+		edit := tgbotapi.EditMessageMediaConfig{
+			BaseEdit: tgbotapi.BaseEdit{
+				ChatID:      receipt.ChatID,
+				MessageID:   receipt.PhotoID,
+				ReplyMarkup: telegramKeyboard(message.Keyboard),
+			},
+			Media: photo,
+		}
+		if _, err = client.Send(edit); err != nil && !telegramNotModified(err) {
+			return fmt.Errorf("error editing Telegram photo: %w", err)
+		}
+	}
+	return nil
+}
+
+// telegramNotModified tells if the edit failed only because the content is the same.
+func telegramNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
+
+// splitTelegramText splits the text by lines into parts within the limit. A code block (``` or <pre>)
+// cut by the split is closed at the end of the part and reopened at the start of the next one.
+func splitTelegramText(text string, limit int) (parts []string) {
+	var part []rune
+	open, start := "", 0
+	cut := func() {
+		parts = append(parts, string(part)+telegramCodeCloser(open))
+		part = []rune(open)
+		start = len(part)
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		next := telegramCodeState(open, line)
+		reserve := len(telegramCodeCloser(next))
+		if len(part)+len(runes)+reserve > limit && len(part) > start {
+			if open != "" && next == "" && strings.TrimSpace(line) == "```" {
+				// The closing line ends the part as it is, there is room for it.
+				parts = append(parts, string(part)+"```")
+				part, start, open = nil, 0, ""
+				continue
+			}
+			cut()
+		}
+		// The lines longer than the limit are cut as they are.
+		for n := limit - len(part) - reserve; len(runes) > n && n > 0; n = limit - len(part) - reserve {
+			part = append(part, runes[:n]...)
+			runes = runes[n:]
+			cut()
+		}
+		part = append(part, runes...)
+		open = next
+	}
+	if len(part) > start {
+		parts = append(parts, string(part))
+	}
+	return
+}
+
+// telegramCodeState returns the opening marker of the code block the text is in after the line, if any.
+func telegramCodeState(open, line string) string {
+	if strings.HasPrefix(line, "```") {
+		if open == "" {
+			return line
+		}
+		return ""
+	}
+	if i, j := strings.LastIndex(line, "<pre>"), strings.LastIndex(line, "</pre>"); i > j {
+		return "<pre>"
+	} else if j > i {
+		return ""
+	}
+	return open
+}
+
+func telegramCodeCloser(open string) string {
+	switch {
+	case open == "":
+		return ""
+	case open == "<pre>":
+		return "</pre>"
+	default:
+		return "```"
+	}
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	assert.Equal(t, "\\-0\\.02 €, \\*high\\* \\(x\\_y\\)\\!", EscapeMarkdownV2("-0.02 €, *high* (x_y)!"))
+}
+
+func TestSendMessage_UnknownDriver(t *testing.T) {
+	err := SendMessage(&ConfigMessenger{Driver: "pigeon"}, "test")
+	assert.True(t, errors.Is(err, ErrUnknownMessengerDriver))
+}
+
+func TestSendRichMessage(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	err := SendRichMessage(&cfg.Messenger, Message{
+		Document: TextDocument("Prices"),
+		Attachments: []Attachment{
+			{Kind: AttachmentPhoto, Name: "chart.png", Data: []byte("png")},
+			{Kind: AttachmentDocument, Name: "chart.html", Data: []byte("html")},
+		},
+	})
+	require.NoError(t, err)
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "sendPhoto", requests[0].Method)
+	assert.Equal(t, "Prices", requests[0].Values["caption"])
+	assert.Equal(t, "chart.png", requests[0].File)
+	assert.Equal(t, "sendDocument", requests[1].Method)
+	assert.Equal(t, "", requests[1].Values["caption"])
+}
+
+func TestSendRichMessage_LongText(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	err := SendRichMessage(&cfg.Messenger, Message{
+		Document: TextDocument(strings.Repeat("line\n", 300)),
+		Attachments: []Attachment{
+			{Kind: AttachmentPhoto, Name: "1.png", Data: []byte("png")},
+			{Kind: AttachmentPhoto, Name: "2.png", Data: []byte("png")},
+		},
+	})
+	require.NoError(t, err)
+
+	// The text doesn't fit into the caption, so it goes first.
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "sendMessage", requests[0].Method)
+	assert.Equal(t, "MarkdownV2", requests[0].Values["parse_mode"])
+	assert.Equal(t, "sendMediaGroup", requests[1].Method)
+	assert.NotContains(t, requests[1].Values["media"], `"caption":`)
+}
+
+func TestSendRichMessage_Error(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	telegram.fail = true
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	assert.Error(t, SendMessage(&cfg.Messenger, "test"))
+}
+
+func TestSplitTelegramText(t *testing.T) {
+	assert.Equal(t, []string{"ab\n", "cd\nef"}, splitTelegramText("ab\ncd\nef", 5))
+	assert.Equal(t, []string{"abc", "de"}, splitTelegramText("abcde", 3))
+	assert.Nil(t, splitTelegramText("", 3))
+
+	assert.Equal(t,
+		[]string{"*a*\n```\n01\n```", "```\n02\n03\n04\n```", "b"},
+		splitTelegramText("*a*\n```\n01\n02\n03\n04\n```\nb", 16),
+	)
+	assert.Equal(t,
+		[]string{"<b>a</b>\n<pre>01\n</pre>", "<pre>02\n03\n04</pre>\nb"},
+		splitTelegramText("<b>a</b>\n<pre>01\n02\n03\n04</pre>\nb", 23),
+	)
+}
+
+type fakeTelegramRequest struct {
+	Method string
+	Values map[string]string
+	File   string
+}
+
+// fakeTelegram is a stand-in for the Telegram Bot API recording the requests.
+type fakeTelegram struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requests  []fakeTelegramRequest
+	updates   []string
+	messageID int
+	fail      bool
+	// errors are the responses to the next requests, before the successful ones, an empty one succeeds.
+	errors []string
+}
+
+func newFakeTelegram() *fakeTelegram {
+	telegram := &fakeTelegram{}
+	telegram.Server = httptest.NewServer(http.HandlerFunc(telegram.handle))
+	return telegram
+}
+
+func (telegram *fakeTelegram) Endpoint() string {
+	return telegram.URL + "/bot%s/%s"
+}
+
+func (telegram *fakeTelegram) Requests() []fakeTelegramRequest {
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+	return append([]fakeTelegramRequest{}, telegram.requests...)
+}
+
+// AddUpdate queues an update JSON for getUpdates.
+func (telegram *fakeTelegram) AddUpdate(update string) {
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+	telegram.updates = append(telegram.updates, update)
+}
+
+func (telegram *fakeTelegram) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	request := fakeTelegramRequest{Method: method, Values: map[string]string{}}
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		for name, files := range r.MultipartForm.File {
+			if name != "" && len(files) > 0 {
+				request.File = files[0].Filename
+			}
+		}
+	}
+	_ = r.ParseForm()
+	for name := range r.Form {
+		request.Values[name] = r.Form.Get(name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+	switch method {
+	case "getMe":
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}}`))
+		return
+	case "getUpdates":
+		updates := telegram.updates
+		telegram.updates = nil
+		if len(updates) == 0 {
+			// Long polling would wait here, a short pause is enough for the tests.
+			time.Sleep(10 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":[` + strings.Join(updates, ",") + `]}`))
+		return
+	}
+
+	telegram.requests = append(telegram.requests, request)
+	if len(telegram.errors) > 0 {
+		response := telegram.errors[0]
+		telegram.errors = telegram.errors[1:]
+		if response != "" {
+			_, _ = w.Write([]byte(response))
+			return
+		}
+	}
+	if telegram.fail {
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request"}`))
+		return
+	}
+	telegram.messageID++
+	message := fmt.Sprintf(`{"message_id":%d,"date":0,"chat":{"id":123,"type":"private"}}`, telegram.messageID)
+	switch method {
+	case "sendMediaGroup":
+		_, _ = w.Write([]byte(`{"ok":true,"result":[` + message + `]}`))
+	case "answerCallbackQuery", "setWebhook", "deleteWebhook":
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	default:
+		_, _ = w.Write([]byte(`{"ok":true,"result":` + message + `}`))
+	}
+}
+
+func TestSendRichMessage_HTML(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Messenger.Telegram.ParseMode = "HTML"
+
+	var doc Document
+	doc.Heading("Prices <today>").Paragraph("-0.02 & more")
+	require.NoError(t, SendRichMessage(&cfg.Messenger, Message{Document: doc}))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "HTML", requests[0].Values["parse_mode"])
+	assert.Equal(t, "<b>Prices &lt;today&gt;</b>\n-0.02 &amp; more", requests[0].Values["text"])
+}
+
+func TestTelegramMessenger_Edit(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	messenger := &telegramMessenger{cfg: &cfg.Messenger.Telegram}
+
+	receipt, err := messenger.SendReceipt(Message{Document: TextDocument("first")}, Receipt{})
+	require.NoError(t, err)
+	assert.Equal(t, Receipt{ChatID: 123, TextIDs: []int{1}}, receipt)
+
+	require.NoError(t, messenger.Edit(receipt, Message{Document: TextDocument("second")}))
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "editMessageText", requests[1].Method)
+	assert.Equal(t, "1", requests[1].Values["message_id"])
+	assert.Equal(t, "second", requests[1].Values["text"])
+
+	// The photo can't be added to the text message, nor the text split into more messages.
+	photo := []Attachment{{Kind: AttachmentPhoto, Name: "chart.png", Data: []byte("png")}}
+	assert.Equal(t, ErrNotEditable, messenger.Edit(receipt, Message{Document: TextDocument("third"), Attachments: photo}))
+	long := strings.Repeat("long line\n", telegramTextLimit/5)
+	assert.Equal(t, ErrNotEditable, messenger.Edit(receipt, Message{Document: TextDocument(long)}))
+	assert.Len(t, telegram.Requests(), 2)
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+const mqttDialTimeout = 10 * time.Second
+const mqttKeepAlive = 60
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xe0
+)
+
+var ErrMQTTRefused = errors.New("MQTT connection refused")
+
+// mqttClient is the minimal MQTT 3.1.1 client publishing with QoS 0, enough for the retained states.
+type mqttClient struct {
+	conn net.Conn
+}
+
+// mqttAddress returns the host:port of the broker URL, secure for ssl://, tls:// and mqtts://.
+func mqttAddress(broker string) (address string, secure bool, err error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure, port = true, "8883"
+	default:
+		err = fmt.Errorf("unknown scheme %q, expected tcp or ssl", u.Scheme)
+		return
+	}
+	if u.Hostname() == "" {
+		err = errors.New("host not set")
+		return
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	address = net.JoinHostPort(u.Hostname(), port)
+	return
+}
+
+// dialMQTT connects to the broker with a clean session.
+func dialMQTT(cfg *ConfigMQTT) (*mqttClient, error) {
+	address, secure, err := mqttAddress(cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: mqttDialTimeout}
+	var conn net.Conn
+	if secure {
+		host, _, _ := net.SplitHostPort(address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+	client := &mqttClient{conn: conn}
+	if err = client.connect(cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (client *mqttClient) connect(cfg *ConfigMQTT) error {
+	var body bytes.Buffer
+	mqttWriteString(&body, "MQTT")
+	flags := byte(0x02) // clean session
+	if cfg.Username != "" {
+		flags |= 0x80
+		if cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body.Write([]byte{4, flags, 0, mqttKeepAlive})
+	mqttWriteString(&body, cfg.ClientID)
+	if cfg.Username != "" {
+		mqttWriteString(&body, cfg.Username)
+		if cfg.Password != "" {
+			mqttWriteString(&body, cfg.Password)
+		}
+	}
+
+	_ = client.conn.SetDeadline(time.Now().Add(mqttDialTimeout))
+	defer client.conn.SetDeadline(time.Time{})
+	if _, err := client.conn.Write(mqttPacket(mqttConnect, body.Bytes())); err != nil {
+		return fmt.Errorf("failed to send MQTT connect: %w", err)
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(client.conn, ack); err != nil {
+		return fmt.Errorf("failed to read MQTT connack: %w", err)
+	}
+	if ack[0] != mqttConnack || ack[1] != 2 {
+		return fmt.Errorf("unexpected MQTT packet %#x instead of connack", ack[0])
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("%w: return code %d", ErrMQTTRefused, ack[3])
+	}
+	return nil
+}
+
+// Publish sends the message with QoS 0, retained messages are kept by the broker for new subscribers
+// and an empty retained payload removes the kept one.
+func (client *mqttClient) Publish(topic string, payload []byte, retain bool) error {
+	var body bytes.Buffer
+	mqttWriteString(&body, topic)
+	body.Write(payload)
+	kind := byte(mqttPublish)
+	if retain {
+		kind |= 0x01
+	}
+	if _, err := client.conn.Write(mqttPacket(kind, body.Bytes())); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close disconnects gracefully.
+func (client *mqttClient) Close() error {
+	_, err := client.conn.Write(mqttPacket(mqttDisconnect, nil))
+	if errClose := client.conn.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// mqttPacket prepends the fixed header with the variable length encoding of the body length.
+func mqttPacket(kind byte, body []byte) []byte {
+	packet := []byte{kind}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func mqttWriteString(buf *bytes.Buffer, s string) {
+	buf.Write([]byte{byte(len(s) >> 8), byte(len(s))})
+	buf.WriteString(s)
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+)
+
+const mqttPriceUnit = "EUR/kWh"
+
+// mqttUnknown is the state payload Home Assistant shows as unknown, an empty one isn't numeric.
+const mqttUnknown = "None"
+
+var mqttNodeIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// PublishMQTT publishes the Home Assistant discovery configs and the retained price states:
+// the current and the next hour price, the tier, today's and tomorrow's series and the cheap windows.
+func PublishMQTT(cfg *ConfigApp, now time.Time) error {
+	messages, err := mqttStates(cfg, now)
+	if err != nil {
+		return err
+	}
+	if cfg.MQTT.DiscoveryPrefix != "" {
+		discovery, err := mqttDiscovery(cfg)
+		if err != nil {
+			return err
+		}
+		messages = append(discovery, messages...)
+	}
+
+	client, err := dialMQTT(&cfg.MQTT)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if err = client.Publish(message.topic, message.payload, true); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	return client.Close()
+}
+
+// mqttStates returns the states at the time, the unknown next hour price is "None" and tomorrow's series is empty
+// to clear the retained ones.
+func mqttStates(cfg *ConfigApp, now time.Time) (messages []mqttMessage, err error) {
+	now = now.In(cfg.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, cfg.Location())
+	prices, err := LoadPrices(cfg, today)
+	if err != nil {
+		return
+	}
+	current := int(now.Sub(today) / time.Hour)
+	if current >= len(prices) {
+		err = fmt.Errorf("no price for %s", now.Format("2006-01-02 15:04"))
+		return
+	}
+	tomorrow := today.AddDate(0, 0, 1)
+	tomorrowPrices := loadTomorrowPrices(cfg, tomorrow, now)
+
+	add := func(name string, payload []byte) {
+		messages = append(messages, mqttMessage{topic: cfg.MQTT.Topic + "/" + name, payload: payload})
+	}
+	addJSON := func(name string, v any) {
+		if err == nil {
+			var data []byte
+			data, err = json.Marshal(v)
+			add(name, data)
+		}
+	}
+
+	next := mqttUnknown
+	if current+1 < len(prices) {
+		next = prices[current+1].String()
+	} else if len(tomorrowPrices) > 0 {
+		next = tomorrowPrices[0].String()
+	}
+	add("price", []byte(prices[current].String()))
+	add("price/next", []byte(next))
+	add("tier", []byte(cfg.Analytics.Tier(prices[current]).Name))
+	addJSON("prices/today", DayPricesPayload(&cfg.Analytics, today, prices, nil, nil))
+	if len(tomorrowPrices) > 0 {
+		addJSON("prices/tomorrow", DayPricesPayload(&cfg.Analytics, tomorrow, tomorrowPrices, nil, nil))
+	} else {
+		add("prices/tomorrow", nil)
+	}
+
+	windows := cheapWindows(prices, today, cfg.MQTT.CheapWindows)
+	for _, hours := range cfg.MQTT.CheapWindows {
+		window := models.CheapWindow{Hours: hours}
+		if i := slices.IndexFunc(windows, func(window models.CheapWindow) bool { return window.Hours == hours }); i >= 0 {
+			window = windows[i]
+			window.Active = !now.Before(window.Start) && now.Before(window.End)
+		}
+		addJSON(fmt.Sprintf("cheap/%dh", hours), window)
+	}
+	return
+}
+
+// mqttDiscovery returns the Home Assistant discovery configs of the states.
+func mqttDiscovery(cfg *ConfigApp) (messages []mqttMessage, err error) {
+	node := strings.ToLower(mqttNodeIDInvalid.ReplaceAllString(cfg.MQTT.ClientID, "_"))
+	topic := func(name string) string {
+		return cfg.MQTT.Topic + "/" + name
+	}
+	type entity struct {
+		component, name string
+		config          models.HADiscoveryConfig
+	}
+	price := func(title, name string) models.HADiscoveryConfig {
+		return models.HADiscoveryConfig{
+			Name:                      title,
+			StateTopic:                topic(name),
+			UnitOfMeasurement:         mqttPriceUnit,
+			StateClass:                "measurement",
+			SuggestedDisplayPrecision: 3,
+			Icon:                      "mdi:currency-eur",
+		}
+	}
+	series := func(title, name string) models.HADiscoveryConfig {
+		return models.HADiscoveryConfig{
+			Name:                title,
+			StateTopic:          topic(name),
+			ValueTemplate:       "{{ value_json.date }}",
+			JSONAttributesTopic: topic(name),
+			Icon:                "mdi:chart-bar",
+		}
+	}
+	entities := []entity{
+		{"sensor", "price", price("Price", "price")},
+		{"sensor", "next_price", price("Next hour price", "price/next")},
+		{"sensor", "tier", models.HADiscoveryConfig{Name: "Price tier", StateTopic: topic("tier"), Icon: "mdi:tag"}},
+		{"sensor", "today", series("Today's prices", "prices/today")},
+		{"sensor", "tomorrow", series("Tomorrow's prices", "prices/tomorrow")},
+	}
+	for _, hours := range cfg.MQTT.CheapWindows {
+		name := fmt.Sprintf("cheap/%dh", hours)
+		entities = append(entities, entity{"binary_sensor", fmt.Sprintf("cheap_%dh", hours), models.HADiscoveryConfig{
+			Name:                fmt.Sprintf("Cheapest %dh window", hours),
+			StateTopic:          topic(name),
+			ValueTemplate:       "{{ 'ON' if value_json.active else 'OFF' }}",
+			JSONAttributesTopic: topic(name),
+			Icon:                "mdi:cash-clock",
+		}})
+	}
+
+	device := models.HADevice{
+		Identifiers:  []string{node},
+		Name:         "Day-Ahead Prices NL",
+		Manufacturer: "day-ahead-prices-notificator",
+		SWVersion:    strings.TrimSpace(cfg.Analytics.Version),
+	}
+	for _, entity := range entities {
+		config := entity.config
+		config.UniqueID = node + "_" + entity.name
+		config.ObjectID = config.UniqueID
+		config.Device = device
+		data, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, mqttMessage{
+			topic:   fmt.Sprintf("%s/%s/%s/%s/config", cfg.MQTT.DiscoveryPrefix, entity.component, node, entity.name),
+			payload: data,
+		})
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateMQTTTestConfig(broker *fakeMQTTBroker) *ConfigApp {
+	cfg := generateBotTestConfig()
+	cfg.MQTT = ConfigMQTT{
+		Broker:          broker.URL(),
+		ClientID:        "day-ahead-prices",
+		Topic:           "prices",
+		DiscoveryPrefix: "homeassistant",
+		CheapWindows:    []int{1, 3},
+	}
+	return cfg
+}
+
+func TestPublishMQTT(t *testing.T) {
+	broker := newFakeMQTTBroker(t, 0)
+	cfg := generateMQTTTestConfig(broker)
+
+	// Tomorrow's prices aren't published yet.
+	require.NoError(t, PublishMQTT(cfg, time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())))
+	retained := func(topic string) string {
+		var payload string
+		assert.Eventually(t, func() bool {
+			var ok bool
+			payload, ok = broker.Retained(topic)
+			return ok
+		}, time.Second, 10*time.Millisecond, topic)
+		return payload
+	}
+	assert.Equal(t, "0", retained("prices/price"))
+	assert.Equal(t, "-0.02", retained("prices/price/next"))
+	assert.Equal(t, "low", retained("prices/tier"))
+
+	var today models.DayPrices
+	require.NoError(t, json.Unmarshal([]byte(retained("prices/prices/today")), &today))
+	assert.Equal(t, "2025-02-28", today.Date)
+	assert.Len(t, today.Slots, 24)
+	_, ok := broker.Retained("prices/prices/tomorrow")
+	assert.False(t, ok)
+
+	var window models.CheapWindow
+	require.NoError(t, json.Unmarshal([]byte(retained("prices/cheap/3h")), &window))
+	assert.True(t, window.Active)
+	assert.Equal(t, 12, window.Start.In(cfg.Location()).Hour())
+	assert.Equal(t, 15, window.End.In(cfg.Location()).Hour())
+	assert.Equal(t, "0.00667", window.Average.String())
+	require.NoError(t, json.Unmarshal([]byte(retained("prices/cheap/1h")), &window))
+	assert.False(t, window.Active)
+
+	var config models.HADiscoveryConfig
+	require.NoError(t, json.Unmarshal([]byte(retained("homeassistant/sensor/day-ahead-prices/price/config")), &config))
+	assert.Equal(t, "prices/price", config.StateTopic)
+	assert.Equal(t, "day-ahead-prices_price", config.UniqueID)
+	assert.Equal(t, []string{"day-ahead-prices"}, config.Device.Identifiers)
+	require.NoError(t, json.Unmarshal([]byte(retained("homeassistant/binary_sensor/day-ahead-prices/cheap_3h/config")), &config))
+	assert.Equal(t, "prices/cheap/3h", config.JSONAttributesTopic)
+
+	// At the end of the day the next hour is tomorrow's first one.
+	require.NoError(t, PublishMQTT(cfg, time.Date(2025, 2, 28, 23, 0, 0, 0, cfg.Location())))
+	assert.Eventually(t, func() bool {
+		_, ok := broker.Retained("prices/prices/tomorrow")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "0.15", retained("prices/price/next"))
+	assert.Equal(t, "0.13", retained("prices/price"))
+}
+
+func TestMQTTStates_UnknownNext(t *testing.T) {
+	broker := newFakeMQTTBroker(t, 0)
+	cfg := generateMQTTTestConfig(broker)
+	today := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	prices, err := LoadPrices(cfg, today)
+	require.NoError(t, err)
+	// Tomorrow's prices can't be loaded.
+	cfg.Loader.Driver = "broken"
+
+	messages, err := mqttStates(cfg, today.Add(23*time.Hour))
+	require.NoError(t, err)
+	payloads := map[string]string{}
+	for _, message := range messages {
+		payloads[message.topic] = string(message.payload)
+	}
+	assert.Equal(t, prices[23].String(), payloads["prices/price"])
+	assert.Equal(t, "None", payloads["prices/price/next"])
+	assert.Equal(t, "", payloads["prices/prices/tomorrow"])
+}
+
+func TestPublishMQTT_NoDiscovery(t *testing.T) {
+	broker := newFakeMQTTBroker(t, 0)
+	cfg := generateMQTTTestConfig(broker)
+	cfg.MQTT.DiscoveryPrefix = ""
+
+	require.NoError(t, PublishMQTT(cfg, time.Date(2025, 2, 28, 13, 30, 0, 0, cfg.Location())))
+	assert.Eventually(t, func() bool {
+		return len(broker.Published()) == 7
+	}, time.Second, 10*time.Millisecond)
+	for _, message := range broker.Published() {
+		assert.NotContains(t, message.topic, "homeassistant")
+	}
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMQTTConnect struct {
+	ClientID, Username, Password string
+}
+
+// fakeMQTTBroker accepts MQTT 3.1.1 connections and keeps the published messages,
+// the retained ones by topic as a broker does.
+type fakeMQTTBroker struct {
+	listener   net.Listener
+	returnCode byte
+
+	mu        sync.Mutex
+	connects  []fakeMQTTConnect
+	published []mqttMessage
+	retained  map[string]string
+}
+
+func newFakeMQTTBroker(t *testing.T, returnCode byte) *fakeMQTTBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	broker := &fakeMQTTBroker{listener: listener, returnCode: returnCode, retained: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return broker
+}
+
+func (broker *fakeMQTTBroker) URL() string {
+	return "tcp://" + broker.listener.Addr().String()
+}
+
+func (broker *fakeMQTTBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, multiplier := 0, 1
+		for {
+			digit, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			length += int(digit&0x7f) * multiplier
+			multiplier *= 128
+			if digit&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch kind & 0xf0 {
+		case mqttConnect:
+			broker.connect(body)
+			if _, err = conn.Write([]byte{mqttConnack, 2, 0, broker.returnCode}); err != nil || broker.returnCode != 0 {
+				return
+			}
+		case mqttPublish:
+			topic, payload := fakeMQTTString(body)
+			broker.mu.Lock()
+			broker.published = append(broker.published, mqttMessage{topic: topic, payload: payload})
+			if kind&0x01 != 0 {
+				if len(payload) == 0 {
+					delete(broker.retained, topic)
+				} else {
+					broker.retained[topic] = string(payload)
+				}
+			}
+			broker.mu.Unlock()
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func (broker *fakeMQTTBroker) connect(body []byte) {
+	_, rest := fakeMQTTString(body)
+	flags := rest[1]
+	var connect fakeMQTTConnect
+	connect.ClientID, rest = fakeMQTTString(rest[4:])
+	if flags&0x80 != 0 {
+		connect.Username, rest = fakeMQTTString(rest)
+	}
+	if flags&0x40 != 0 {
+		connect.Password, _ = fakeMQTTString(rest)
+	}
+	broker.mu.Lock()
+	broker.connects = append(broker.connects, connect)
+	broker.mu.Unlock()
+}
+
+func (broker *fakeMQTTBroker) Connects() []fakeMQTTConnect {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return append([]fakeMQTTConnect{}, broker.connects...)
+}
+
+func (broker *fakeMQTTBroker) Published() []mqttMessage {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return append([]mqttMessage{}, broker.published...)
+}
+
+func (broker *fakeMQTTBroker) Retained(topic string) (string, bool) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	payload, ok := broker.retained[topic]
+	return payload, ok
+}
+
+// fakeMQTTString returns the length-prefixed string and the rest.
+func fakeMQTTString(data []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(data))
+	return string(data[2 : 2+length]), data[2+length:]
+}
+
+func TestMQTTAddress(t *testing.T) {
+	address, secure, err := mqttAddress("tcp://broker.local")
+	require.NoError(t, err)
+	assert.Equal(t, "broker.local:1883", address)
+	assert.False(t, secure)
+
+	address, secure, err = mqttAddress("mqtts://broker.local")
+	require.NoError(t, err)
+	assert.Equal(t, "broker.local:8883", address)
+	assert.True(t, secure)
+
+	_, _, err = mqttAddress("ws://broker.local:9001")
+	assert.Error(t, err)
+}
+
+func TestMQTTClient(t *testing.T) {
+	broker := newFakeMQTTBroker(t, 0)
+	cfg := &ConfigMQTT{Broker: broker.URL(), ClientID: "test", Username: "user", Password: "secret"}
+
+	client, err := dialMQTT(cfg)
+	require.NoError(t, err)
+	// Bodies over 127 bytes need two bytes of the remaining length.
+	long := make([]byte, 300)
+	require.NoError(t, client.Publish("prices/long", long, true))
+	require.NoError(t, client.Publish("prices/price", []byte("0.123"), false))
+	require.NoError(t, client.Close())
+
+	assert.Eventually(t, func() bool {
+		return len(broker.Published()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []fakeMQTTConnect{{ClientID: "test", Username: "user", Password: "secret"}}, broker.Connects())
+	payload, ok := broker.Retained("prices/long")
+	assert.True(t, ok)
+	assert.Len(t, payload, 300)
+	_, ok = broker.Retained("prices/price")
+	assert.False(t, ok)
+}
+
+func TestMQTTClient_Refused(t *testing.T) {
+	broker := newFakeMQTTBroker(t, 5)
+
+	_, err := dialMQTT(&ConfigMQTT{Broker: broker.URL(), ClientID: "test"})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrMQTTRefused))
+}
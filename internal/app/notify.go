@@ -0,0 +1,215 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// NotifyDay sends the day prices with the chart, or "No prices for"/"Error for" when it can't,
+// only to the failed destinations when the prices were delivered to the others.
+// The errors in a row are escalated, see ReportFailure, and the failed day is retried, see NotifyDayDue.
+func NotifyDay(cfg *ConfigApp, day time.Time) (err error) {
+	templates := cfg.MessageTemplates("")
+	defer func() {
+		if err == nil {
+			if errReport := ReportSuccess(cfg, FailureSourceNotifyDay); errReport != nil {
+				log.Printf("Error reporting success: %v\n", errReport)
+			}
+			return
+		}
+		log.Printf("Error notifying prices for %s: %v\n", day.Format("2006-01-02"), err)
+		message := Message{Class: MessageClassError}
+		if errors.Is(err, ErrNoPrices) {
+			message.Class = MessageClassNoPrices
+		}
+		if text, errRender := templates.Render(string(message.Class), TemplateData{Day: day}); errRender != nil {
+			log.Printf("Error rendering message: %v\n", errRender)
+		} else {
+			message.Document = TextDocument(text)
+			// The destinations which got the prices don't get the error, the retries don't repeat it.
+			destinations := FailedDestinations(err)
+			if errSend := DeliverOnce(cfg, message.Class+"-"+day.Format("2006-01-02"), destinations, message); errSend != nil {
+				log.Printf("Error sending message: %v\n", errSend)
+			}
+		}
+		if message.Class == MessageClassError {
+			if errReport := ReportFailure(cfg, FailureSourceNotifyDay, err, time.Now()); errReport != nil {
+				log.Printf("Error reporting failure: %v\n", errReport)
+			}
+		}
+	}()
+
+	prices, err := LoadPrices(cfg, day)
+	if err != nil {
+		return
+	}
+	message, err := BuildDayMessage(&cfg.Analytics, templates, prices, day)
+	if err != nil {
+		return
+	}
+	if message.Keyboard, err = DayKeyboard(&cfg.Messenger, templates, day); err != nil {
+		return
+	}
+	return Deliver(cfg, message.Class+"-"+day.Format("2006-01-02"), message)
+}
+
+// DayReport is the structured day summary,
+// the alerts are the names of the tiers other than normal met in the day and "negative".
+type DayReport struct {
+	Day    time.Time
+	Alerts []string
+	Prices []decimal.Decimal
+	Series models.DayPrices
+	Stats  models.DayStats
+}
+
+func dayStats(prices []decimal.Decimal) (stats models.DayStats) {
+	if len(prices) == 0 {
+		return
+	}
+	stats.Min, stats.Max = prices[0], prices[0]
+	for i, price := range prices {
+		if price.LessThan(stats.Min) {
+			stats.Min, stats.MinHour = price, i
+		}
+		if price.GreaterThan(stats.Max) {
+			stats.Max, stats.MaxHour = price, i
+		}
+	}
+	stats.Average = decimal.Avg(prices[0], prices[1:]...).Round(5)
+	return
+}
+
+// dayStatsFields returns the names and values of the day stats in the language.
+func dayStatsFields(locale *Locale, stats models.DayStats) [][2]string {
+	at := func(price decimal.Decimal, hour int) string {
+		return locale.Price(price) + " " + locale.Word("at") + " " + locale.Hour(hour)
+	}
+	return [][2]string{
+		{locale.Word("lowest"), at(stats.Min, stats.MinHour)},
+		{locale.Word("highest"), at(stats.Max, stats.MaxHour)},
+		{locale.Word("average"), locale.Price(stats.Average)},
+	}
+}
+
+// cheapestWindow returns the start and the average of the first cheapest run of hours prices,
+// there must be at least hours prices.
+func cheapestWindow(prices []decimal.Decimal, hours int) (start int, average decimal.Decimal) {
+	var best decimal.Decimal
+	for i := 0; i+hours <= len(prices); i++ {
+		sum := decimal.Sum(decimal.Zero, prices[i:i+hours]...)
+		if i == 0 || sum.LessThan(best) {
+			start, best = i, sum
+		}
+	}
+	average = best.Div(decimal.NewFromInt(int64(hours)))
+	return
+}
+
+// cheapWindows returns the cheapest windows of the day by their lengths, the longer than the day are skipped.
+func cheapWindows(prices []decimal.Decimal, day time.Time, hours []int) (windows []models.CheapWindow) {
+	for _, length := range hours {
+		if length > len(prices) {
+			continue
+		}
+		start, average := cheapestWindow(prices, length)
+		windows = append(windows, models.CheapWindow{
+			Hours:   length,
+			Start:   day.Add(time.Duration(start) * time.Hour),
+			End:     day.Add(time.Duration(start+length) * time.Hour),
+			Average: average.Round(5),
+		})
+	}
+	return
+}
+
+func dayAlerts(cfg *ConfigAnalytics, prices []decimal.Decimal) (alerts []string) {
+	tiers := cfg.PriceTiers()
+	for i, tier := range tiers {
+		if i == tiers.normal() {
+			continue
+		}
+		if slices.ContainsFunc(prices, func(price decimal.Decimal) bool { return cfg.Tier(price).Name == tier.Name }) {
+			alerts = append(alerts, tier.Name)
+		}
+	}
+	if slices.ContainsFunc(prices, decimal.Decimal.IsNegative) {
+		alerts = append(alerts, AlertNegative)
+	}
+	return
+}
+
+// BuildDayMessage builds the day message with the high/low alerts, the text chart and the PNG chart.
+func BuildDayMessage(cfg *ConfigAnalytics, templates *MessageTemplates, prices []decimal.Decimal, day time.Time) (message Message, err error) {
+	return buildDayMessage(cfg, templates, prices, day, priceAlerts(cfg, prices))
+}
+
+// buildDayMessage renders the title, the alerts and the summary with the templates,
+// the alerts are the names of the raised ones.
+func buildDayMessage(cfg *ConfigAnalytics, templates *MessageTemplates, prices []decimal.Decimal, day time.Time, alerts []string) (message Message, err error) {
+	date := day.Format("2006-01-02")
+	data := dayTemplateData(cfg, prices, day, alerts)
+	var title, alert, summary string
+	if title, err = templates.Render("day.title", data); err != nil {
+		return
+	}
+	if alert, err = templates.Render("day.alert", data); err != nil {
+		return
+	}
+	if summary, err = templates.Render("day.summary", data); err != nil {
+		return
+	}
+	chart, err := ChartText(cfg, templates.Locale(), prices, day)
+	if err != nil {
+		return
+	}
+	image, err := ChartPNG(cfg, prices, day)
+	if err != nil {
+		return
+	}
+
+	message.Class = MessageClassPrices
+	doc := &message.Document
+	doc.Heading(title)
+	if alert != "" {
+		doc.Strong(alert)
+	}
+	if summary != "" {
+		doc.Paragraph(summary)
+	}
+	doc.Add(Block{Kind: BlockFields, Fields: dayStatsFields(templates.Locale(), data.Stats), Detail: true})
+	doc.Add(chart)
+	doc.Add(Block{Kind: BlockContext, Text: strings.Join(cfg.TierLegend(templates.Locale()), "   "), Detail: true})
+	message.Day = &DayReport{
+		Day:    day,
+		Alerts: dayAlerts(cfg, prices),
+		Prices: prices,
+		Series: data.Series,
+		Stats:  data.Stats,
+	}
+	message.Attachments = []Attachment{{
+		Kind:     AttachmentPhoto,
+		Name:     fmt.Sprintf("epex_nl_%s.png", date),
+		MimeType: "image/png",
+		Data:     image,
+	}}
+	return
+}
+
+// priceAlerts tells if there are prices equal or higher than the high price or equal or lower than the low price.
+func priceAlerts(cfg *ConfigAnalytics, prices []decimal.Decimal) (alerts []string) {
+	if slices.ContainsFunc(prices, func(price decimal.Decimal) bool { return price.GreaterThanOrEqual(cfg.HighPrice) }) {
+		alerts = append(alerts, AlertHigh)
+	}
+	if slices.ContainsFunc(prices, func(price decimal.Decimal) bool { return price.LessThanOrEqual(cfg.LowPrice) }) {
+		alerts = append(alerts, AlertLow)
+	}
+	return
+}
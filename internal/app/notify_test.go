@@ -0,0 +1,96 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyDay(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	require.NoError(t, NotifyDay(cfg, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())))
+
+	// The day chart fits into the caption of the photo.
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "sendPhoto", requests[0].Method)
+	assert.Equal(t, "epex_nl_2025-02-28.png", requests[0].File)
+	caption := requests[0].Values["caption"]
+	assert.True(t, strings.HasPrefix(caption, "*EPEX NL Day\\-Ahead 2025\\-02\\-28*\n*There are Low prices*\n`00:00` "), caption)
+	// The stats and the legend are on the chart image.
+	assert.NotContains(t, caption, "Lowest")
+	assert.Contains(t, caption, "🟢 \\-0\\.02\n")
+}
+
+func TestNotifyDay_Error(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = "broken"
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	assert.Error(t, NotifyDay(cfg, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Error for 2025\\-02\\-28", requests[0].Values["text"])
+}
+
+func TestNotifyDay_PartialFailure(t *testing.T) {
+	main, ops := newFakeTelegram(), newFakeTelegram()
+	defer main.Close()
+	defer ops.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	cfg.Messenger.Telegram.APIEndpoint = main.Endpoint()
+	cfg.Messenger.Destinations = MessengerDestinations{{
+		Name:     "ops",
+		Driver:   "telegram",
+		Telegram: ConfigTelegram{Token: "ops", ChatID: 7, APIEndpoint: ops.Endpoint()},
+	}}
+	ops.errors = []string{`{"ok":false,"error_code":500,"description":"Internal Server Error"}`}
+
+	err := NotifyDay(cfg, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	assert.Equal(t, []string{"ops"}, FailedDestinations(err))
+
+	// Only the failed destination gets the error.
+	assert.Len(t, main.Requests(), 1)
+	requests := ops.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "Error for 2025\\-02\\-28", requests[1].Values["text"])
+}
+
+func TestNotifyDay_NoPrices(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	server := generateFakeServerWithBody(`{"Prices":[]}`)
+	defer server.Close()
+	cfg := generateTestConfig()
+	cfg.Loader.API.Endpoint = server.URL
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	assert.Error(t, NotifyDay(cfg, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "No prices for 2025\\-02\\-28", requests[0].Values["text"])
+}
+
+func TestPriceAlert(t *testing.T) {
+	cfg := generateTestConfig()
+
+	assert.Empty(t, priceAlerts(&cfg.Analytics, []decimal.Decimal{decimal.NewFromFloat(0.15)}))
+	assert.Equal(t, []string{AlertLow}, priceAlerts(&cfg.Analytics, []decimal.Decimal{decimal.NewFromFloat(0.1)}))
+	assert.Equal(t, []string{AlertHigh, AlertLow}, priceAlerts(&cfg.Analytics, []decimal.Decimal{
+		decimal.NewFromFloat(0.2), decimal.NewFromFloat(0.1),
+	}))
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var ntfyPriorities = map[int]string{pushPriorityLow: "2", pushPriorityDefault: "3", pushPriorityHigh: "4"}
+
+// ntfyMessenger publishes to a ntfy topic, the attachments go as separate messages.
+type ntfyMessenger struct {
+	cfg       *ConfigNtfy
+	publicURL string
+}
+
+func (messenger *ntfyMessenger) Send(message Message) error {
+	title, text := PlainMessage(message)
+	url := strings.TrimSuffix(messenger.cfg.URL, "/") + "/" + messenger.cfg.Topic
+	header := messenger.header(message, title)
+	if err := pushRequest(http.MethodPost, url, header, strings.NewReader(text)); err != nil {
+		return err
+	}
+
+	if !messenger.cfg.Attachments {
+		return nil
+	}
+	for _, attachment := range message.Attachments {
+		header := messenger.header(message, title)
+		header.Set("Filename", attachment.Name)
+		header.Set("Priority", ntfyPriorities[pushPriorityLow])
+		if err := pushRequest(http.MethodPut, url, header, bytes.NewReader(attachment.Data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (messenger *ntfyMessenger) header(message Message, title string) http.Header {
+	header := http.Header{}
+	// ntfy decodes the RFC 2047 headers, ASCII stays as is.
+	header.Set("Title", mime.QEncoding.Encode("utf-8", title))
+	header.Set("Priority", ntfyPriorities[pushPriority(message)])
+	if tags := pushTags(message); len(tags) > 0 {
+		header.Set("Tags", strings.Join(tags, ","))
+	}
+	if click := pushDayURL(messenger.publicURL, message, ""); click != "" {
+		header.Set("Click", click)
+	}
+	if messenger.cfg.Token != "" {
+		header.Set("Authorization", "Bearer "+messenger.cfg.Token)
+	}
+	return header
+}
@@ -0,0 +1,553 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+)
+
+// Storage collections of the outbox: the queued items, the dead letters and the keys of the sent ones.
+const (
+	outboxCollection     = "outbox"
+	outboxDeadCollection = "outbox-dead"
+	outboxSentCollection = "outbox-sent"
+)
+
+// outboxChatDestination is the destination of the messages to the chats of the Telegram bot subscribers.
+const outboxChatDestination = "chat"
+
+// OutboxItem is a message waiting for the delivery to a destination,
+// the ID is the idempotency key of the message and the destination name.
+type OutboxItem struct {
+	ID          string `json:"id"`
+	Destination string `json:"destination"`
+	ChatID      int64  `json:"chat_id,omitempty"`
+	// Edit is the ID of the sent item whose messages are replaced by this one.
+	Edit        string    `json:"edit,omitempty"`
+	Message     Message   `json:"message"`
+	Attempts    int       `json:"attempts"`
+	Created     time.Time `json:"created"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Receipt has the parts sent before the failure, the next attempt doesn't send them again.
+	Receipt *Receipt `json:"receipt,omitempty"`
+}
+
+type outboxSent struct {
+	Sent time.Time `json:"sent"`
+	// Receipt of the messenger able to edit the sent messages.
+	Receipt *Receipt `json:"receipt,omitempty"`
+}
+
+// Deliver queues the message under the idempotency key, or sends it at once when the outbox is disabled.
+func Deliver(cfg *ConfigApp, key string, message Message) error {
+	return DeliverTo(cfg, key, nil, message)
+}
+
+// DeliverTo delivers the message only to the named destinations, to all of them when none are named.
+// The error names the failed destinations, see FailedDestinations.
+func DeliverTo(cfg *ConfigApp, key string, destinations []string, message Message) error {
+	items, err := cfg.Queue().deliveryItems(key, destinations, message)
+	if err != nil {
+		return err
+	}
+	return deliverItems(cfg, items)
+}
+
+// DeliverOnce delivers the message like DeliverTo, skipping the destinations which already got the key,
+// e.g. the error of the retried job.
+func DeliverOnce(cfg *ConfigApp, key string, destinations []string, message Message) error {
+	items, err := cfg.Queue().deliveryItems(key, destinations, message)
+	if err != nil {
+		return err
+	}
+	var unsent []OutboxItem
+	for _, item := range items {
+		if sent, errSent := cfg.Queue().Sent(key, item.Destination); errSent != nil {
+			return errSent
+		} else if !sent {
+			unsent = append(unsent, item)
+		}
+	}
+	return deliverItems(cfg, unsent)
+}
+
+// DeliverChat queues the message to the chat, or sends it at once when the outbox is disabled.
+func DeliverChat(cfg *ConfigApp, key string, chatID int64, message Message) error {
+	return deliverItems(cfg, []OutboxItem{chatItem(key, chatID, message)})
+}
+
+// DeliverEdit queues the message replacing the one delivered under the original key,
+// or sends it at once when the outbox is disabled.
+func DeliverEdit(cfg *ConfigApp, original, key string, message Message) error {
+	items, err := cfg.Queue().editItems(original, key, message)
+	if err != nil {
+		return err
+	}
+	return deliverItems(cfg, items)
+}
+
+// DeliverChatEdit queues the message replacing the one delivered to the chat under the original key,
+// or sends it at once when the outbox is disabled.
+func DeliverChatEdit(cfg *ConfigApp, original, key string, chatID int64, message Message) error {
+	return deliverItems(cfg, []OutboxItem{chatEditItem(original, key, chatID, message)})
+}
+
+// deliverItems queues the items, or sends them at once when the outbox is disabled.
+// Either way the receipts are kept, so the messages can be edited.
+func deliverItems(cfg *ConfigApp, items []OutboxItem) error {
+	if !cfg.Outbox.Enabled {
+		return cfg.Queue().SendNow(items, time.Now())
+	}
+	_, err := cfg.Queue().addItems(items, time.Now())
+	return err
+}
+
+// Outbox keeps the notifications in the storage until they are delivered,
+// so a failed or interrupted delivery is retried and never repeated once succeeded.
+type Outbox struct {
+	mu        sync.Mutex
+	storage   Storage
+	cfg       *ConfigOutbox
+	messenger *ConfigMessenger
+	// dispatcher returns the destinations of the messenger, created once.
+	dispatcher func() (*Dispatcher, error)
+	// paused are the destinations rate limited until the time.
+	paused map[string]time.Time
+	// report gets the result of every delivery attempt except the rate limited ones, dead when it was the last one.
+	report func(item OutboxItem, cause error, dead bool, now time.Time)
+}
+
+func NewOutbox(
+	storage Storage, cfg *ConfigOutbox, messenger *ConfigMessenger, dispatcher func() (*Dispatcher, error),
+	report func(item OutboxItem, cause error, dead bool, now time.Time),
+) *Outbox {
+	return &Outbox{storage: storage, cfg: cfg, messenger: messenger, dispatcher: dispatcher, paused: map[string]time.Time{}, report: report}
+}
+
+// Enqueue adds the message for every destination accepting its class,
+// the key is skipped when it's already queued, sent or dead-lettered.
+func (outbox *Outbox) Enqueue(key string, message Message, now time.Time) (bool, error) {
+	return outbox.EnqueueTo(key, nil, message, now)
+}
+
+// EnqueueTo adds the message only for the named destinations accepting its class, for all of them when none are named.
+// The error names the destinations failed to be added, see FailedDestinations.
+func (outbox *Outbox) EnqueueTo(key string, destinations []string, message Message, now time.Time) (bool, error) {
+	items, err := outbox.deliveryItems(key, destinations, message)
+	if err != nil {
+		return false, err
+	}
+	return outbox.addItems(items, now)
+}
+
+// EnqueueChat adds the message to the chat of the main Telegram bot.
+func (outbox *Outbox) EnqueueChat(key string, chatID int64, message Message, now time.Time) (bool, error) {
+	return outbox.addItems([]OutboxItem{chatItem(key, chatID, message)}, now)
+}
+
+// EnqueueEdit adds the message replacing the one sent under the original key, for every destination it was sent to.
+// The destinations not able to edit their messages get it as a new one.
+func (outbox *Outbox) EnqueueEdit(original, key string, message Message, now time.Time) (bool, error) {
+	items, err := outbox.editItems(original, key, message)
+	if err != nil {
+		return false, err
+	}
+	return outbox.addItems(items, now)
+}
+
+// EnqueueChatEdit adds the message replacing the one sent to the chat under the original key.
+func (outbox *Outbox) EnqueueChatEdit(original, key string, chatID int64, message Message, now time.Time) (bool, error) {
+	return outbox.addItems([]OutboxItem{chatEditItem(original, key, chatID, message)}, now)
+}
+
+// SendNow sends the items at once instead of queueing them, the edits only when the edited item was sent.
+// The receipts are kept with the sent keys as for the queued items, so the messages can be edited later.
+// The error names the failed destinations, see FailedDestinations.
+func (outbox *Outbox) SendNow(items []OutboxItem, now time.Time) error {
+	dispatcher, err := outbox.dispatcher()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := outbox.sendNow(dispatcher, item, now); err != nil {
+				log.Printf("Error sending %s: %v\n", item.ID, err)
+				errs[i] = &DestinationError{Destination: item.Destination, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (outbox *Outbox) sendNow(dispatcher *Dispatcher, item OutboxItem, now time.Time) error {
+	if ok, err := outbox.editable(item); err != nil || !ok {
+		return err
+	}
+	messenger, err := outbox.itemMessenger(dispatcher, item)
+	var receipt *Receipt
+	if err == nil {
+		receipt, err = outbox.send(messenger, item)
+	}
+	outbox.report(item, err, false, now)
+	if err != nil {
+		return err
+	}
+	log.Printf("Sent %s\n", item.ID)
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	return outbox.recordSent(item, receipt, now)
+}
+
+// Sent tells if the message under the key was delivered to the destination, e.g. so it can be edited.
+func (outbox *Outbox) Sent(key, destination string) (bool, error) {
+	return outbox.storage.Load(outboxSentCollection, key+"."+destination, &outboxSent{})
+}
+
+// MarkSent records the message under the key as delivered to the destination without a receipt,
+// e.g. when it was merged into another one. Its edits are sent as new messages.
+func (outbox *Outbox) MarkSent(key, destination string, now time.Time) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	return outbox.storage.Save(outboxSentCollection, key+"."+destination, outboxSent{Sent: now})
+}
+
+// deliveryItems returns the items of the named destinations accepting the message class, of all when none are named.
+func (outbox *Outbox) deliveryItems(key string, destinations []string, message Message) (items []OutboxItem, err error) {
+	dispatcher, err := outbox.dispatcher()
+	if err != nil {
+		return
+	}
+	for _, destination := range dispatcher.Only(destinations).Destinations(message.Class) {
+		items = append(items, OutboxItem{ID: key + "." + destination, Destination: destination, Message: message})
+	}
+	return
+}
+
+// editItems returns the items replacing the messages of the original key at every destination.
+func (outbox *Outbox) editItems(original, key string, message Message) (items []OutboxItem, err error) {
+	dispatcher, err := outbox.dispatcher()
+	if err != nil {
+		return
+	}
+	for _, destination := range dispatcher.Names() {
+		items = append(items, OutboxItem{ID: key + "." + destination, Destination: destination, Edit: original + "." + destination, Message: message})
+	}
+	return
+}
+
+func chatItem(key string, chatID int64, message Message) OutboxItem {
+	return OutboxItem{ID: key + "." + outboxChatDestination, Destination: outboxChatDestination, ChatID: chatID, Message: message}
+}
+
+func chatEditItem(original, key string, chatID int64, message Message) OutboxItem {
+	item := chatItem(key, chatID, message)
+	item.Edit = original + "." + outboxChatDestination
+	return item
+}
+
+// addItems adds the items, the error names the destinations failed to be added.
+func (outbox *Outbox) addItems(items []OutboxItem, now time.Time) (queued bool, err error) {
+	var errs []error
+	for _, item := range items {
+		ok, errAdd := outbox.add(item, now)
+		queued = queued || ok
+		if errAdd != nil {
+			errs = append(errs, &DestinationError{Destination: item.Destination, Err: errAdd})
+		}
+	}
+	return queued, errors.Join(errs...)
+}
+
+// editable tells if the item can be delivered: it isn't an edit or the edited item was sent.
+func (outbox *Outbox) editable(item OutboxItem) (bool, error) {
+	if item.Edit == "" {
+		return true, nil
+	}
+	return outbox.storage.Load(outboxSentCollection, item.Edit, &outboxSent{})
+}
+
+// add queues the item unless it's already queued, sent or dead-lettered, the edits only when the edited item was sent.
+func (outbox *Outbox) add(item OutboxItem, now time.Time) (bool, error) {
+	if ok, err := outbox.editable(item); err != nil || !ok {
+		return false, err
+	}
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	for _, collection := range []string{outboxCollection, outboxSentCollection, outboxDeadCollection} {
+		ok, err := outbox.storage.Load(collection, item.ID, &struct{}{})
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			log.Printf("Outbox item %s already exists in %s\n", item.ID, collection)
+			return false, nil
+		}
+	}
+	item.Created, item.NextAttempt = now, now
+	return true, outbox.storage.Save(outboxCollection, item.ID, item)
+}
+
+// Run delivers the due items every interval until the context is done.
+func (outbox *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(outbox.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		outbox.Process(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process delivers the due items in the order they were queued, failed items are retried with backoff
+// or moved to the dead letters after the last attempt. It also forgets the sent keys older than the retention.
+func (outbox *Outbox) Process(now time.Time) {
+	items, err := outbox.items(outboxCollection)
+	if err != nil {
+		log.Printf("Error loading outbox: %v\n", err)
+		return
+	}
+	dispatcher, err := outbox.dispatcher()
+	if err != nil {
+		log.Printf("Error creating dispatcher: %v\n", err)
+		return
+	}
+	for _, item := range items {
+		if item.NextAttempt.After(now) || outbox.paused[item.Destination].After(now) {
+			continue
+		}
+		if err = outbox.deliver(dispatcher, item, now); err != nil {
+			log.Printf("Error updating outbox item %s: %v\n", item.ID, err)
+		}
+	}
+	outbox.prune(now)
+}
+
+// itemMessenger returns the messenger of the item destination, the main Telegram bot for the chats.
+func (outbox *Outbox) itemMessenger(dispatcher *Dispatcher, item OutboxItem) (Messenger, error) {
+	if item.Destination == outboxChatDestination {
+		return chatMessenger(outbox.messenger, item.ChatID), nil
+	}
+	return dispatcher.Messenger(item.Destination)
+}
+
+// deliver sends the item and updates the queue, the attempt is reported after that.
+func (outbox *Outbox) deliver(dispatcher *Dispatcher, item OutboxItem, now time.Time) error {
+	messenger, err := outbox.itemMessenger(dispatcher, item)
+	var receipt *Receipt
+	if err == nil {
+		receipt, err = outbox.send(messenger, item)
+	}
+	dead, errUpdate := outbox.update(item, receipt, err, now)
+	if retryAfter(err) == 0 {
+		outbox.report(item, err, dead, now)
+	}
+	return errUpdate
+}
+
+// update removes the delivered item from the queue, the failed one is retried later or moved to the dead letters.
+func (outbox *Outbox) update(item OutboxItem, receipt *Receipt, cause error, now time.Time) (dead bool, err error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	if cause == nil {
+		log.Printf("Delivered outbox item %s\n", item.ID)
+		if err = outbox.recordSent(item, receipt, now); err != nil {
+			return false, err
+		}
+		return false, outbox.storage.Delete(outboxCollection, item.ID)
+	}
+
+	item.LastError = cause.Error()
+	if receipt != nil {
+		item.Receipt = receipt
+	}
+	// The rate limit isn't the failure of the message, the destination is paused for the time asked.
+	if wait := retryAfter(cause); wait > 0 {
+		log.Printf("Destination %s is rate limited for %s\n", item.Destination, wait)
+		outbox.paused[item.Destination] = now.Add(wait)
+		item.NextAttempt = now.Add(wait)
+		return false, outbox.storage.Save(outboxCollection, item.ID, item)
+	}
+	item.Attempts++
+	if item.Attempts >= outbox.cfg.Attempts {
+		log.Printf("Outbox item %s failed %d times, moved to dead letters: %v\n", item.ID, item.Attempts, cause)
+		if err = outbox.storage.Save(outboxDeadCollection, item.ID, item); err != nil {
+			return true, err
+		}
+		return true, outbox.storage.Delete(outboxCollection, item.ID)
+	}
+	item.NextAttempt = now.Add(outbox.backoff(item.Attempts))
+	log.Printf("Outbox item %s failed, retrying at %s: %v\n", item.ID, item.NextAttempt.Format(time.RFC3339), cause)
+	return false, outbox.storage.Save(outboxCollection, item.ID, item)
+}
+
+// recordSent keeps the key of the sent item with the receipt,
+// the edited item gets the receipt too, so the next edits replace the messages of the last delivery.
+func (outbox *Outbox) recordSent(item OutboxItem, receipt *Receipt, now time.Time) error {
+	if err := outbox.storage.Save(outboxSentCollection, item.ID, outboxSent{Sent: now, Receipt: receipt}); err != nil {
+		return err
+	}
+	var sent outboxSent
+	if item.Edit == "" {
+		return nil
+	} else if ok, _ := outbox.storage.Load(outboxSentCollection, item.Edit, &sent); !ok {
+		return nil
+	}
+	sent.Receipt = receipt
+	return outbox.storage.Save(outboxSentCollection, item.Edit, sent)
+}
+
+// send edits the messages of the sent item when the messenger is able to, otherwise sends a new message
+// resuming the failed attempts. The receipt is nil when the messenger can't edit or the edit failed.
+func (outbox *Outbox) send(messenger Messenger, item OutboxItem) (*Receipt, error) {
+	item.Message.Key = item.ID
+	editor, ok := messenger.(Editor)
+	if !ok {
+		return nil, messenger.Send(item.Message)
+	}
+	if item.Edit != "" {
+		var sent outboxSent
+		if ok, err := outbox.storage.Load(outboxSentCollection, item.Edit, &sent); err != nil {
+			return nil, err
+		} else if ok && sent.Receipt != nil {
+			err = editor.Edit(*sent.Receipt, item.Message)
+			if err == nil {
+				return sent.Receipt, nil
+			} else if !errors.Is(err, ErrNotEditable) {
+				return nil, err
+			}
+			log.Printf("Messages of outbox item %s can't be edited, sending a new one\n", item.Edit)
+		}
+	}
+	var resume Receipt
+	if item.Receipt != nil {
+		resume = *item.Receipt
+	}
+	receipt, err := editor.SendReceipt(item.Message, resume)
+	return &receipt, err
+}
+
+// backoff doubles the delay after every attempt up to the maximum.
+func (outbox *Outbox) backoff(attempts int) time.Duration {
+	delay := outbox.cfg.Backoff
+	for i := 1; i < attempts && delay < outbox.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, outbox.cfg.MaxBackoff)
+}
+
+func (outbox *Outbox) prune(now time.Time) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	keys, err := outbox.storage.Keys(outboxSentCollection)
+	if err != nil {
+		log.Printf("Error loading sent outbox items: %v\n", err)
+		return
+	}
+	for _, key := range keys {
+		var sent outboxSent
+		if ok, err := outbox.storage.Load(outboxSentCollection, key, &sent); err != nil || !ok {
+			continue
+		}
+		if now.Sub(sent.Sent) > outbox.cfg.Retention {
+			_ = outbox.storage.Delete(outboxSentCollection, key)
+		}
+	}
+}
+
+// Pending returns the items waiting for the delivery.
+func (outbox *Outbox) Pending() ([]models.OutboxEntry, error) {
+	return outbox.entries(outboxCollection)
+}
+
+// DeadLetters returns the items failed all the attempts.
+func (outbox *Outbox) DeadLetters() ([]models.OutboxEntry, error) {
+	return outbox.entries(outboxDeadCollection)
+}
+
+// Retry moves the dead letter back to the queue with the attempts reset.
+func (outbox *Outbox) Retry(id string, now time.Time) (ok bool, err error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	var item OutboxItem
+	if ok, err = outbox.storage.Load(outboxDeadCollection, id, &item); err != nil || !ok {
+		return
+	}
+	item.Attempts, item.NextAttempt = 0, now
+	if err = outbox.storage.Save(outboxCollection, id, item); err != nil {
+		return
+	}
+	return true, outbox.storage.Delete(outboxDeadCollection, id)
+}
+
+func (outbox *Outbox) entries(collection string) (entries []models.OutboxEntry, err error) {
+	items, err := outbox.items(collection)
+	if err != nil {
+		return
+	}
+	entries = make([]models.OutboxEntry, len(items))
+	for i, item := range items {
+		entries[i] = models.OutboxEntry{
+			ID:          item.ID,
+			Destination: item.Destination,
+			ChatID:      item.ChatID,
+			Class:       item.Message.Class,
+			Title:       item.Message.Document.Title(),
+			Attempts:    item.Attempts,
+			Created:     item.Created,
+			NextAttempt: item.NextAttempt,
+			LastError:   item.LastError,
+		}
+	}
+	return
+}
+
+// items returns the items of the collection sorted by the creation time.
+func (outbox *Outbox) items(collection string) (items []OutboxItem, err error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	keys, err := outbox.storage.Keys(collection)
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		var item OutboxItem
+		ok, err := outbox.storage.Load(collection, key, &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load outbox item %s: %w", key, err)
+		}
+		if ok {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Created.Before(items[j].Created) })
+	return
+}
+
+// retryAfter returns the wait asked by a Telegram or Matrix rate limit response, zero for the other errors.
+func retryAfter(err error) time.Duration {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second
+	}
+	var matrixErr *MatrixError
+	if errors.As(err, &matrixErr) {
+		return matrixErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateOutboxTestConfig(telegram *fakeTelegram) *ConfigApp {
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Outbox = ConfigOutbox{
+		Enabled:    true,
+		Attempts:   3,
+		Backoff:    time.Minute,
+		MaxBackoff: 90 * time.Second,
+		Interval:   time.Second,
+		Retention:  24 * time.Hour,
+	}
+	return cfg
+}
+
+func TestOutbox_Deliver(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	message := Message{Class: MessageClassPrices, Document: TextDocument("prices")}
+
+	queued, err := cfg.Queue().Enqueue("prices-2025-03-01", message, now)
+	require.NoError(t, err)
+	assert.True(t, queued)
+	// The same key isn't queued twice.
+	queued, err = cfg.Queue().Enqueue("prices-2025-03-01", message, now)
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	cfg.Queue().Process(now)
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "prices", requests[0].Values["text"])
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Sent keys are remembered, e.g. after a restart, until the retention is over.
+	queued, err = cfg.Queue().Enqueue("prices-2025-03-01", message, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, queued)
+	cfg.Queue().Process(now.Add(25 * time.Hour))
+	queued, err = cfg.Queue().Enqueue("prices-2025-03-01", message, now.Add(25*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, queued)
+}
+
+func TestOutbox_Resume(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	// The text is too long for the caption, so it goes before the photo.
+	message := Message{
+		Class:       MessageClassPrices,
+		Document:    TextDocument(strings.Repeat("prices ", telegramCaptionLimit/5)),
+		Attachments: []Attachment{{Kind: AttachmentPhoto, Name: "chart.png", MimeType: "image/png", Data: []byte("png")}},
+	}
+	telegram.errors = []string{"", `{"ok":false,"error_code":500,"description":"Internal Server Error"}`}
+
+	_, err := cfg.Queue().Enqueue("prices-2025-03-01", message, now)
+	require.NoError(t, err)
+	cfg.Queue().Process(now)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// The text isn't sent again.
+	cfg.Queue().Process(now.Add(time.Minute))
+	requests := telegram.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, []string{"sendMessage", "sendPhoto", "sendPhoto"}, []string{requests[0].Method, requests[1].Method, requests[2].Method})
+	var sent outboxSent
+	ok, err := cfg.Store().Load(outboxSentCollection, "prices-2025-03-01.main", &sent)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, &Receipt{ChatID: 123, TextIDs: []int{1}, PhotoID: 2}, sent.Receipt)
+}
+
+func TestOutbox_Retry(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	telegram.fail = true
+	cfg := generateOutboxTestConfig(telegram)
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	_, err := cfg.Queue().EnqueueChat("subscription-42-2025-03-01", 42, Message{Document: TextDocument("prices")}, now)
+	require.NoError(t, err)
+
+	cfg.Queue().Process(now)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, int64(42), pending[0].ChatID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.WithinDuration(t, now.Add(time.Minute), pending[0].NextAttempt, 0)
+	assert.Equal(t, "error sending Telegram message: Bad Request", pending[0].LastError)
+
+	// Not due yet.
+	cfg.Queue().Process(now.Add(30 * time.Second))
+	assert.Len(t, telegram.Requests(), 1)
+
+	cfg.Queue().Process(now.Add(time.Minute))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	// The backoff doubles up to the maximum.
+	assert.WithinDuration(t, now.Add(time.Minute+90*time.Second), pending[0].NextAttempt, 0)
+
+	cfg.Queue().Process(now.Add(time.Hour))
+	pending, err = cfg.Queue().Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	dead, err := cfg.Queue().DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "subscription-42-2025-03-01.chat", dead[0].ID)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, "prices", dead[0].Title)
+
+	telegram.fail = false
+	ok, err := cfg.Queue().Retry(dead[0].ID, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	cfg.Queue().Process(now.Add(time.Hour))
+	requests := telegram.Requests()
+	require.Len(t, requests, 4)
+	assert.Equal(t, "42", requests[3].Values["chat_id"])
+	dead, err = cfg.Queue().DeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, dead)
+
+	ok, err = cfg.Queue().Retry("unknown", now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestOutbox_RateLimit(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	telegram.errors = []string{`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`}
+	cfg := generateOutboxTestConfig(telegram)
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	_, err := cfg.Queue().Enqueue("first", Message{Document: TextDocument("first")}, now)
+	require.NoError(t, err)
+	_, err = cfg.Queue().Enqueue("second", Message{Document: TextDocument("second")}, now.Add(time.Second))
+	require.NoError(t, err)
+
+	// The destination is paused for the rest of the items, the rate limit isn't a failed attempt.
+	cfg.Queue().Process(now.Add(time.Second))
+	assert.Len(t, telegram.Requests(), 1)
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 0, pending[0].Attempts)
+	assert.WithinDuration(t, now.Add(31*time.Second), pending[0].NextAttempt, 0)
+
+	cfg.Queue().Process(now.Add(20 * time.Second))
+	assert.Len(t, telegram.Requests(), 1)
+
+	cfg.Queue().Process(now.Add(31 * time.Second))
+	requests := telegram.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, "first", requests[1].Values["text"])
+	assert.Equal(t, "second", requests[2].Values["text"])
+}
+
+func TestOutbox_Destinations(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	cfg.Messenger.Destinations = MessengerDestinations{{
+		Name:     "ops",
+		Driver:   messengerDriverTelegram,
+		Classes:  []string{MessageClassError},
+		Telegram: ConfigTelegram{Token: "ops", ChatID: 456, APIEndpoint: telegram.Endpoint()},
+	}}
+
+	require.NoError(t, Deliver(cfg, "error-2025-03-01", Message{Class: MessageClassError, Document: TextDocument("error")}))
+	require.NoError(t, Deliver(cfg, "prices-2025-03-01", Message{Class: MessageClassPrices, Document: TextDocument("prices")}))
+	pending, err := cfg.Queue().Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	assert.Equal(t, "error-2025-03-01.main", pending[0].ID)
+	assert.Equal(t, "error-2025-03-01.ops", pending[1].ID)
+	assert.Equal(t, "prices-2025-03-01.main", pending[2].ID)
+
+	cfg.Queue().Process(time.Now())
+	assert.Len(t, telegram.Requests(), 3)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const p1ReconnectDelay = 5 * time.Second
+const p1DialTimeout = 10 * time.Second
+
+var (
+	ErrP1Checksum   = errors.New("P1 telegram checksum mismatch")
+	ErrP1NoChecksum = errors.New("P1 telegram without checksum")
+)
+
+// P1Telegram holds the values we need from a DSMR 4/5 telegram,
+// import and export are the sums over both tariffs.
+type P1Telegram struct {
+	Header    string
+	Version   string
+	Timestamp time.Time
+	Import    decimal.Decimal
+	Export    decimal.Decimal
+}
+
+// ParseP1Telegram validates the CRC and parses a raw telegram from "/" up to the "!CRC" line.
+// The telegram without the CRC is refused unless P1_ALLOWNOCRC is set.
+func ParseP1Telegram(raw string, cfg *ConfigP1, location *time.Location) (telegram P1Telegram, err error) {
+	end := strings.LastIndex(raw, "!")
+	if !strings.HasPrefix(raw, "/") || end < 0 {
+		err = errors.New("invalid P1 telegram")
+		return
+	}
+	crc := strings.TrimSpace(raw[end+1:])
+	if crc == "" && !cfg.AllowNoCRC {
+		// A DSMR 4/5 telegram cut before its CRC would be counted with the wrong readings.
+		err = ErrP1NoChecksum
+		return
+	}
+	if crc != "" {
+		expected, errParse := strconv.ParseUint(crc, 16, 16)
+		if errParse != nil {
+			err = fmt.Errorf("invalid P1 telegram CRC: %w", errParse)
+			return
+		}
+		if uint16(expected) != p1CRC16([]byte(raw[:end+1])) {
+			err = ErrP1Checksum
+			return
+		}
+	}
+
+	var hasImport, hasTimestamp bool
+	for i, line := range strings.Split(raw[:end], "\n") {
+		line = strings.TrimSpace(line)
+		if i == 0 {
+			telegram.Header = strings.TrimPrefix(line, "/")
+			continue
+		}
+		open := strings.Index(line, "(")
+		if open < 0 || !strings.HasSuffix(line, ")") {
+			continue
+		}
+		obis, value := line[:open], line[open+1:len(line)-1]
+		// Units are always the last part of the value, e.g. "001234.567*kWh".
+		value = strings.SplitN(value, "*", 2)[0]
+
+		switch obis {
+		case "1-3:0.2.8":
+			telegram.Version = value
+		case "0-0:1.0.0":
+			if telegram.Timestamp, err = parseP1Timestamp(value, location); err != nil {
+				return
+			}
+			hasTimestamp = true
+		case "1-0:1.8.1", "1-0:1.8.2", "1-0:2.8.1", "1-0:2.8.2":
+			reading, errParse := decimal.NewFromString(value)
+			if errParse != nil {
+				err = fmt.Errorf("invalid P1 value %s: %w", obis, errParse)
+				return
+			}
+			if obis[4] == '1' {
+				telegram.Import = telegram.Import.Add(reading)
+				hasImport = true
+			} else {
+				telegram.Export = telegram.Export.Add(reading)
+			}
+		}
+	}
+	if !hasImport {
+		err = errors.New("P1 telegram has no import readings")
+		return
+	}
+	if !hasTimestamp {
+		telegram.Timestamp = time.Now().In(location)
+	}
+
+	return
+}
+
+// parseP1Timestamp parses "YYMMDDhhmmssX" where X is S (summer) or W (winter) time.
+func parseP1Timestamp(value string, location *time.Location) (time.Time, error) {
+	if len(value) != 13 {
+		return time.Time{}, fmt.Errorf("invalid P1 timestamp: %s", value)
+	}
+	ts, err := time.ParseInLocation("060102150405", value[:12], location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid P1 timestamp: %w", err)
+	}
+	// The DST flag resolves the repeated hour when the clock goes back.
+	alt := ts.Add(time.Hour)
+	if value[12] == 'S' {
+		alt = ts.Add(-time.Hour)
+	}
+	if ts.IsDST() != (value[12] == 'S') && alt.IsDST() == (value[12] == 'S') && alt.Hour() == ts.Hour() {
+		ts = alt
+	}
+	return ts, nil
+}
+
+// p1CRC16 is CRC16/ARC (polynomial 0xA001 reversed, no XOR out), as required by DSMR 4 and 5.
+func p1CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// ReadP1Telegrams reads telegrams from the stream until it ends and passes the valid ones to fn.
+func ReadP1Telegrams(r io.Reader, cfg *ConfigP1, location *time.Location, fn func(P1Telegram)) error {
+	reader := bufio.NewReader(r)
+	var raw strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "/") {
+			raw.Reset()
+		}
+		if raw.Len() > 0 || strings.HasPrefix(line, "/") {
+			raw.WriteString(strings.TrimRight(line, "\r\n") + "\r\n")
+		}
+		if strings.HasPrefix(line, "!") && raw.Len() > 0 {
+			// The CRC is calculated over the original CRLF line endings.
+			telegram, errParse := ParseP1Telegram(strings.TrimRight(raw.String(), "\r\n"), cfg, location)
+			raw.Reset()
+			if errParse != nil {
+				log.Printf("Skipping P1 telegram: %v\n", errParse)
+			} else {
+				fn(telegram)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read P1 data: %w", err)
+		}
+	}
+}
+
+// RunP1Reader connects to the meter and keeps reading telegrams until the context is done.
+func RunP1Reader(ctx context.Context, cfg *ConfigP1, location *time.Location, fn func(P1Telegram)) {
+	for {
+		conn, err := openP1(ctx, cfg)
+		if err != nil {
+			log.Printf("Error connecting to P1 %s: %v\n", cfg.Address, err)
+		} else {
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-done:
+				}
+				conn.Close()
+			}()
+			if err = ReadP1Telegrams(conn, cfg, location, fn); err != nil && ctx.Err() == nil {
+				log.Printf("Error reading P1 %s: %v\n", cfg.Address, err)
+			}
+			close(done)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p1ReconnectDelay):
+		}
+	}
+}
+
+func openP1(ctx context.Context, cfg *ConfigP1) (io.ReadCloser, error) {
+	switch cfg.Driver {
+	case p1DriverTCP:
+		dialer := net.Dialer{Timeout: p1DialTimeout}
+		return dialer.DialContext(ctx, "tcp", cfg.Address)
+	case p1DriverSerial:
+		// The serial port must be configured beforehand (e.g. "stty -F /dev/ttyUSB0 115200 raw").
+		return os.Open(cfg.Address)
+	default:
+		return nil, fmt.Errorf("unknown P1_DRIVER: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP1CRC16(t *testing.T) {
+	assert.Equal(t, uint16(0xBB3D), p1CRC16([]byte("123456789")))
+}
+
+func TestParseP1Telegram(t *testing.T) {
+	cfg := generateTestConfig()
+	var telegrams []P1Telegram
+	err := ReadP1Telegrams(openP1Recording(t), &cfg.P1, cfg.Location(), func(telegram P1Telegram) {
+		telegrams = append(telegrams, telegram)
+	})
+	require.NoError(t, err)
+
+	// The broken first telegram and the one with a wrong CRC are skipped.
+	require.Len(t, telegrams, 4)
+	assert.Equal(t, "ISk5\\2MT382-1000", telegrams[0].Header)
+	assert.Equal(t, "50", telegrams[0].Version)
+	assert.Equal(t, "3000", telegrams[0].Import.String())
+	assert.Equal(t, "300", telegrams[0].Export.String())
+	assert.Equal(t, time.Date(2025, 2, 28, 10, 0, 0, 0, cfg.Location()), telegrams[0].Timestamp)
+}
+
+func TestParseP1Telegram_Error(t *testing.T) {
+	cfg := generateTestConfig()
+
+	_, err := ParseP1Telegram("1-0:1.8.1(000001.000*kWh)\r\n!", &cfg.P1, cfg.Location())
+	assert.Error(t, err)
+
+	_, err = ParseP1Telegram("/TEST\r\n\r\n1-0:1.8.1(000001.000*kWh)\r\n!0000", &cfg.P1, cfg.Location())
+	assert.Equal(t, ErrP1Checksum, err)
+
+	cfg.P1.AllowNoCRC = true
+	_, err = ParseP1Telegram("/TEST\r\n\r\n0-0:1.0.0(250228100000W)\r\n!", &cfg.P1, cfg.Location())
+	assert.Error(t, err)
+}
+
+func TestParseP1Telegram_NoCRC(t *testing.T) {
+	cfg := generateTestConfig()
+	raw := "/TEST\r\n\r\n0-0:1.0.0(250228100000W)\r\n1-0:1.8.1(000001.000*kWh)\r\n!"
+
+	// A DSMR 4/5 telegram cut before its CRC isn't counted.
+	_, err := ParseP1Telegram(raw, &cfg.P1, cfg.Location())
+	assert.Equal(t, ErrP1NoChecksum, err)
+
+	// The older meters never send it.
+	cfg.P1.AllowNoCRC = true
+	telegram, err := ParseP1Telegram(raw, &cfg.P1, cfg.Location())
+	require.NoError(t, err)
+	assert.Equal(t, "1", telegram.Import.String())
+}
+
+func TestParseP1Timestamp(t *testing.T) {
+	cfg := generateTestConfig()
+
+	summer, err := parseP1Timestamp("251026023000S", cfg.Location())
+	require.NoError(t, err)
+	winter, err := parseP1Timestamp("251026023000W", cfg.Location())
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, winter.Sub(summer))
+
+	_, err = parseP1Timestamp("2510260230", cfg.Location())
+	assert.Error(t, err)
+}
+
+func TestRunP1Reader(t *testing.T) {
+	cfg := generateTestConfig()
+	data, err := os.ReadFile("testdata/p1_dsmr5.txt")
+	require.NoError(t, err)
+
+	// A local stand-in for ser2net replaying the recorded telegrams.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write(data)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var telegrams []P1Telegram
+	go RunP1Reader(ctx, &ConfigP1{Driver: p1DriverTCP, Address: listener.Addr().String()}, cfg.Location(), func(telegram P1Telegram) {
+		mu.Lock()
+		defer mu.Unlock()
+		telegrams = append(telegrams, telegram)
+	})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(telegrams) == 4
+	}, time.Second, 10*time.Millisecond)
+}
+
+func openP1Recording(t *testing.T) *strings.Reader {
+	data, err := os.ReadFile("testdata/p1_dsmr5.txt")
+	require.NoError(t, err)
+	return strings.NewReader(string(data))
+}
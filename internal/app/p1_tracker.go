@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// p1PricesRetry is the pause before the prices of the day are fetched again after an error.
+const p1PricesRetry = time.Minute
+
+// P1Tracker turns meter readings into the usage and cost per slot, day and month.
+type P1Tracker struct {
+	mu          sync.Mutex
+	location    *time.Location
+	fetch       priceFetcher
+	onDayClosed func(day time.Time, status models.P1Status)
+
+	last   *P1Telegram
+	day    time.Time
+	prices []decimal.Decimal
+	status models.P1Status
+	// nextFetch is the earliest time of the next fetch while the day has no prices.
+	nextFetch time.Time
+}
+
+func NewP1Tracker(cfg *ConfigApp, onDayClosed func(day time.Time, status models.P1Status)) *P1Tracker {
+	return &P1Tracker{
+		location: cfg.Location(),
+		fetch: func(day time.Time) ([]decimal.Decimal, error) {
+			return LoadPrices(cfg, day)
+		},
+		onDayClosed: onDayClosed,
+	}
+}
+
+// Update adds the usage since the previous telegram to the slot of the telegram timestamp.
+func (tracker *P1Tracker) Update(telegram P1Telegram) {
+	var closedDay time.Time
+	var closedStatus models.P1Status
+	ts := telegram.Timestamp.In(tracker.location)
+	day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, tracker.location)
+
+	// The prices are fetched without the lock, so the status stays readable meanwhile.
+	tracker.mu.Lock()
+	fetch := !day.Equal(tracker.day) || tracker.prices == nil && !ts.Before(tracker.nextFetch)
+	tracker.mu.Unlock()
+	var prices []decimal.Decimal
+	if fetch {
+		var err error
+		if prices, err = tracker.fetch(day); err != nil {
+			log.Printf("Error fetching prices for P1 costs on %s, retrying in %s: %v\n", day.Format("2006-01-02"), p1PricesRetry, err)
+		}
+	}
+
+	tracker.mu.Lock()
+	if tracker.last == nil {
+		tracker.startDay(day, true)
+	} else if !day.Equal(tracker.day) {
+		closedDay, closedStatus = tracker.day, tracker.status
+		tracker.startDay(day, day.Month() != tracker.day.Month() || day.Year() != tracker.day.Year())
+	}
+	if fetch {
+		tracker.prices = prices
+		tracker.nextFetch = ts.Add(p1PricesRetry)
+	}
+
+	if tracker.last != nil {
+		usage := models.P1Usage{
+			Import: telegram.Import.Sub(tracker.last.Import),
+			Export: telegram.Export.Sub(tracker.last.Export),
+		}
+		// Negative usage means the meter was replaced, so the reading becomes the new base.
+		if !usage.Import.IsNegative() && !usage.Export.IsNegative() {
+			slot := int(ts.Sub(day) / time.Hour)
+			for len(tracker.status.Slots) <= slot {
+				tracker.status.Slots = append(tracker.status.Slots, models.P1Usage{})
+			}
+			if slot < len(tracker.prices) {
+				tracker.status.Price = tracker.prices[slot]
+				usage.Cost = usage.Import.Sub(usage.Export).Mul(tracker.status.Price)
+			}
+			tracker.status.Slots[slot] = tracker.status.Slots[slot].Add(usage)
+			tracker.status.Day = tracker.status.Day.Add(usage)
+			tracker.status.Month = tracker.status.Month.Add(usage)
+		}
+	}
+	tracker.last = &telegram
+	tracker.status.Timestamp = ts
+	tracker.mu.Unlock()
+
+	if !closedDay.IsZero() && tracker.onDayClosed != nil {
+		tracker.onDayClosed(closedDay, closedStatus)
+	}
+}
+
+// Status returns a copy of the current usage.
+func (tracker *P1Tracker) Status() models.P1Status {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	status := tracker.status
+	status.Slots = append([]models.P1Usage{}, tracker.status.Slots...)
+	return status
+}
+
+func (tracker *P1Tracker) startDay(day time.Time, newMonth bool) {
+	tracker.day = day
+	tracker.prices = nil
+	month := tracker.status.Month
+	if newMonth {
+		month = models.P1Usage{}
+	}
+	tracker.status = models.P1Status{Month: month}
+}
+
+// P1DayText builds the message with the day and month totals.
+func P1DayText(day time.Time, status models.P1Status) string {
+	usageText := func(usage models.P1Usage) string {
+		return fmt.Sprintf(
+			"import %s kWh, export %s kWh, cost %s €",
+			usage.Import.StringFixed(3), usage.Export.StringFixed(3), usage.Cost.StringFixed(2),
+		)
+	}
+	return fmt.Sprintf(
+		"P1 %s\nDay: %s\nMonth: %s", day.Format("2006-01-02"), usageText(status.Day), usageText(status.Month),
+	)
+}
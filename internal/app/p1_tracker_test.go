@@ -0,0 +1,87 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP1Tracker(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Loader.Driver = loaderDriverStub
+	var closedDays []time.Time
+	var closedStatus models.P1Status
+	tracker := NewP1Tracker(cfg, func(day time.Time, status models.P1Status) {
+		closedDays = append(closedDays, day)
+		closedStatus = status
+	})
+
+	require.NoError(t, ReadP1Telegrams(openP1Recording(t), &cfg.P1, cfg.Location(), tracker.Update))
+
+	// 0.5 kWh at 10:00 for 0.08 €, then 1 kWh imported and 0.25 kWh exported at 11:00 for 0.06 €.
+	require.Len(t, closedDays, 1)
+	assert.Equal(t, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()), closedDays[0])
+	assert.Equal(t, "1.5", closedStatus.Day.Import.String())
+	assert.Equal(t, "0.25", closedStatus.Day.Export.String())
+	assert.Equal(t, "0.085", closedStatus.Day.Cost.String())
+	require.Len(t, closedStatus.Slots, 12)
+	assert.Equal(t, "0.04", closedStatus.Slots[10].Cost.String())
+	assert.Equal(t, "0.045", closedStatus.Slots[11].Cost.String())
+
+	// The new month starts from zero.
+	status := tracker.Status()
+	assert.Equal(t, "1", status.Day.Import.String())
+	assert.Equal(t, "0.15", status.Day.Cost.String())
+	assert.Equal(t, status.Day, status.Month)
+	assert.Equal(t, "0.15", status.Price.String())
+}
+
+func TestP1DayText(t *testing.T) {
+	cfg := generateTestConfig()
+	status := models.P1Status{}
+
+	text := P1DayText(time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()), status)
+
+	assert.Contains(t, text, "P1 2025-02-28")
+	assert.Contains(t, text, "import 0.000 kWh")
+}
+
+func TestP1Tracker_FetchRetry(t *testing.T) {
+	cfg := generateTestConfig()
+	stub, _ := generateStub()
+	fetched := make(chan struct{}, 1)
+	failures := 1
+	tracker := &P1Tracker{location: cfg.Location()}
+	tracker.fetch = func(day time.Time) ([]decimal.Decimal, error) {
+		// The status is readable while the prices are fetched.
+		tracker.Status()
+		fetched <- struct{}{}
+		if failures > 0 {
+			failures--
+			return nil, errors.New("timeout")
+		}
+		return stub, nil
+	}
+	reading := func(ts time.Time, kWh float64) P1Telegram {
+		return P1Telegram{Timestamp: ts, Import: decimal.NewFromFloat(kWh)}
+	}
+	start := time.Date(2025, 2, 28, 10, 0, 0, 0, cfg.Location())
+
+	tracker.Update(reading(start, 1))
+	<-fetched
+	tracker.Update(reading(start.Add(10*time.Second), 1.5))
+	assert.Len(t, fetched, 0)
+	assert.True(t, tracker.Status().Day.Cost.IsZero())
+
+	// After the pause the prices are fetched again.
+	tracker.Update(reading(start.Add(time.Minute), 2))
+	<-fetched
+	assert.Equal(t, "0.04", tracker.Status().Day.Cost.String())
+	tracker.Update(reading(start.Add(2*time.Minute), 2.5))
+	assert.Len(t, fetched, 0)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const pushHttpTimeout = 10 * time.Second
+
+// Push priorities, every driver maps them to its own levels.
+const (
+	pushPriorityLow = iota
+	pushPriorityDefault
+	pushPriorityHigh
+)
+
+// pushClassTags are the emoji of the message classes: the ntfy shortcode and the emoji itself.
+var pushClassTags = map[string][2]string{
+	MessageClassPrices:   {"zap", "⚡"},
+	MessageClassNoPrices: {"hourglass", "⏳"},
+	MessageClassError:    {"warning", "⚠️"},
+	MessageClassSummary:  {"bar_chart", "📊"},
+	MessageClassRevision: {"pencil2", "✏️"},
+	MessageClassAdmin:    {"rotating_light", "🚨"},
+}
+
+// pushPriority is high for errors, admin messages and negative prices, default for no prices and day prices with alerts, otherwise low.
+func pushPriority(message Message) int {
+	switch {
+	case message.Class == MessageClassError, message.Class == MessageClassAdmin:
+		return pushPriorityHigh
+	case message.Day != nil && slices.Contains(message.Day.Alerts, AlertNegative):
+		return pushPriorityHigh
+	case message.Class == MessageClassNoPrices, message.Day != nil && len(message.Day.Alerts) > 0:
+		return pushPriorityDefault
+	default:
+		return pushPriorityLow
+	}
+}
+
+// pushTags returns the ntfy shortcode of the class and the alerts of the day.
+func pushTags(message Message) (tags []string) {
+	if tag, ok := pushClassTags[message.Class]; ok {
+		tags = append(tags, tag[0])
+	}
+	if message.Day != nil {
+		tags = append(tags, message.Day.Alerts...)
+	}
+	return
+}
+
+// pushTitle prefixes the title with the emoji of the class.
+func pushTitle(message Message, title string) string {
+	if tag, ok := pushClassTags[message.Class]; ok {
+		return tag[1] + " " + title
+	}
+	return title
+}
+
+// pushDayURL returns the chart page of the day in the format, empty without the day or the public URL.
+func pushDayURL(publicURL string, message Message, format string) string {
+	if publicURL == "" || message.Day == nil {
+		return ""
+	}
+	url := strings.TrimSuffix(publicURL, "/") + "/day-prices/" + message.Day.Day.Format("2006-01-02")
+	if format != "" {
+		url += "?format=" + format
+	}
+	return url
+}
+
+func pushRequest(method, url string, header http.Header, body io.Reader) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pushHttpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send, status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
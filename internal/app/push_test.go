@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePushRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+func generateFakePush(status int) (*httptest.Server, func() []fakePushRequest) {
+	var mu sync.Mutex
+	var requests []fakePushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, fakePushRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return server, func() []fakePushRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fakePushRequest{}, requests...)
+	}
+}
+
+func generateTestDayMessage(t *testing.T) Message {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	return message
+}
+
+func TestPushPriority(t *testing.T) {
+	day := generateTestDayMessage(t)
+	assert.Equal(t, pushPriorityHigh, pushPriority(day))
+	day.Day.Alerts = []string{"high"}
+	assert.Equal(t, pushPriorityDefault, pushPriority(day))
+	day.Day.Alerts = nil
+	assert.Equal(t, pushPriorityLow, pushPriority(day))
+
+	assert.Equal(t, pushPriorityHigh, pushPriority(Message{Class: MessageClassError}))
+	assert.Equal(t, pushPriorityHigh, pushPriority(Message{Class: MessageClassAdmin}))
+	assert.Equal(t, pushPriorityDefault, pushPriority(Message{Class: MessageClassNoPrices}))
+	assert.Equal(t, pushPriorityLow, pushPriority(Message{Class: MessageClassSummary}))
+}
+
+func TestNtfyMessenger(t *testing.T) {
+	server, requests := generateFakePush(http.StatusOK)
+	defer server.Close()
+	messenger, err := NewMessenger(&ConfigMessenger{
+		Driver:    "ntfy",
+		Ntfy:      ConfigNtfy{URL: server.URL + "/", Topic: "prices", Token: "tk", Attachments: true},
+		PublicURL: "https://prices.example.com/",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, messenger.Send(generateTestDayMessage(t)))
+
+	require.Len(t, requests(), 2)
+	text := requests()[0]
+	assert.Equal(t, "POST", text.Method)
+	assert.Equal(t, "/prices", text.Path)
+	assert.Equal(t, "EPEX NL Day-Ahead 2025-02-28", text.Header.Get("Title"))
+	assert.Equal(t, "4", text.Header.Get("Priority"))
+	assert.Equal(t, "zap,low,negative", text.Header.Get("Tags"))
+	assert.Equal(t, "https://prices.example.com/day-prices/2025-02-28", text.Header.Get("Click"))
+	assert.Equal(t, "Bearer tk", text.Header.Get("Authorization"))
+	assert.Contains(t, string(text.Body), "There are Low prices\nLowest: -0.020 at 14:00\n")
+
+	image := requests()[1]
+	assert.Equal(t, "PUT", image.Method)
+	assert.Equal(t, "epex_nl_2025-02-28.png", image.Header.Get("Filename"))
+	assert.Equal(t, "2", image.Header.Get("Priority"))
+	assert.NotEmpty(t, image.Body)
+}
+
+func TestNtfyMessenger_Error(t *testing.T) {
+	server, requests := generateFakePush(http.StatusForbidden)
+	defer server.Close()
+	messenger := &ntfyMessenger{cfg: &ConfigNtfy{URL: server.URL, Topic: "prices"}}
+
+	assert.Error(t, messenger.Send(Message{Class: MessageClassError, Document: TextDocument("Error for 2025-02-28")}))
+	require.Len(t, requests(), 1)
+	assert.Equal(t, "Error for 2025-02-28", string(requests()[0].Body))
+	assert.Equal(t, "warning", requests()[0].Header.Get("Tags"))
+	assert.Empty(t, requests()[0].Header.Get("Click"))
+}
+
+func TestGotifyMessenger(t *testing.T) {
+	server, requests := generateFakePush(http.StatusOK)
+	defer server.Close()
+	messenger, err := NewMessenger(&ConfigMessenger{
+		Driver:    "gotify",
+		Gotify:    ConfigGotify{URL: server.URL, Token: "app-token"},
+		PublicURL: "https://prices.example.com",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, messenger.Send(generateTestDayMessage(t)))
+
+	require.Len(t, requests(), 1)
+	request := requests()[0]
+	assert.Equal(t, "/message", request.Path)
+	assert.Equal(t, "app-token", request.Header.Get("X-Gotify-Key"))
+	var message struct {
+		Title    string
+		Message  string
+		Priority int
+		Extras   map[string]map[string]any
+	}
+	require.NoError(t, json.Unmarshal(request.Body, &message))
+	assert.Equal(t, "⚡ EPEX NL Day-Ahead 2025-02-28", message.Title)
+	assert.Equal(t, 8, message.Priority)
+	assert.Contains(t, message.Message, "Average: 0.109\n")
+	assert.Equal(t, "https://prices.example.com/day-prices/2025-02-28?format=png",
+		message.Extras["client::notification"]["bigImageUrl"])
+	assert.Equal(t, map[string]any{"url": "https://prices.example.com/day-prices/2025-02-28"},
+		message.Extras["client::notification"]["click"])
+}
+
+func TestConfigSelfCheck_Push(t *testing.T) {
+	cfg := generateTestConfig()
+	cfg.Messenger.Destinations = MessengerDestinations{{Name: "phone", Driver: "gotify", Gotify: ConfigGotify{URL: "http://gotify"}}}
+	assert.EqualError(t, cfg.SelfCheck(), "MESSENGER_PHONE_GOTIFY_TOKEN not set")
+	cfg.Messenger.Destinations[0] = ConfigMessenger{Name: "phone", Driver: "ntfy", Ntfy: ConfigNtfy{URL: "https://ntfy.sh"}}
+	assert.EqualError(t, cfg.SelfCheck(), "MESSENGER_PHONE_NTFY_TOPIC not set")
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const heldCollection = "held"
+
+// HeldMessage is a message raised during the quiet hours of the subscriber, kept for the digest.
+type HeldMessage struct {
+	Key string `json:"key"`
+	// Edit is the key of the sent message this one replaces, e.g. by the revised prices.
+	Edit    string    `json:"edit,omitempty"`
+	Message Message   `json:"message"`
+	Held    time.Time `json:"held"`
+}
+
+// DeliverSubscriber sends the message to the subscriber, during the quiet hours it's held for the digest
+// unless it's urgent: its class or one of its alerts is in QUIET_URGENT.
+func DeliverSubscriber(cfg *ConfigApp, subscription *Subscription, key string, message Message, now time.Time) error {
+	return deliverSubscriber(cfg, subscription, HeldMessage{Key: key, Message: message, Held: now})
+}
+
+// DeliverSubscriberEdit replaces the message sent to the subscriber under the original key like DeliverChatEdit,
+// during the quiet hours it's held like DeliverSubscriber, the next edit of the same message replaces it.
+func DeliverSubscriberEdit(cfg *ConfigApp, subscription *Subscription, original, key string, message Message, now time.Time) error {
+	return deliverSubscriber(cfg, subscription, HeldMessage{Key: key, Edit: original, Message: message, Held: now})
+}
+
+func deliverSubscriber(cfg *ConfigApp, subscription *Subscription, item HeldMessage) error {
+	if !subscription.QuietAt(cfg, item.Held) || cfg.Quiet.urgent(item.Message) {
+		return deliverHeld(cfg, subscription.ChatID, item)
+	}
+
+	held, err := loadHeld(cfg, subscription.ChatID)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(held, func(other HeldMessage) bool { return other.Key == item.Key }) {
+		return nil
+	}
+	log.Printf("Holding %s for subscriber %d till the quiet hours end\n", item.Key, subscription.ChatID)
+	if i := slices.IndexFunc(held, func(other HeldMessage) bool { return item.Edit != "" && other.Edit == item.Edit }); i >= 0 {
+		held[i] = item
+	} else {
+		held = append(held, item)
+	}
+	return cfg.Store().Save(heldCollection, strconv.FormatInt(subscription.ChatID, 10), held)
+}
+
+// deliverHeld sends the message to the chat, the edits replace the original one.
+func deliverHeld(cfg *ConfigApp, chatID int64, item HeldMessage) error {
+	if item.Edit != "" {
+		return DeliverChatEdit(cfg, item.Edit, item.Key, chatID, item.Message)
+	}
+	return DeliverChat(cfg, item.Key, chatID, item.Message)
+}
+
+// ReplaceHeld replaces the held message of the key, e.g. by the revised prices, ok is false when it isn't held.
+func ReplaceHeld(cfg *ConfigApp, chatID int64, key string, message Message) (ok bool, err error) {
+	held, err := loadHeld(cfg, chatID)
+	if err != nil {
+		return
+	}
+	i := slices.IndexFunc(held, func(item HeldMessage) bool { return item.Key == key })
+	if i < 0 {
+		return
+	}
+	held[i].Message = message
+	return true, cfg.Store().Save(heldCollection, strconv.FormatInt(chatID, 10), held)
+}
+
+// SendDigests sends the held messages of the subscribers whose quiet hours are over.
+// A single message is sent as it is, so it can still be edited, several ones are merged into one digest.
+func SendDigests(cfg *ConfigApp, subscriptions []Subscription, now time.Time) {
+	chats, err := cfg.Store().Keys(heldCollection)
+	if err != nil {
+		log.Printf("Error loading held messages: %v\n", err)
+		return
+	}
+	for _, chat := range chats {
+		chatID, errParse := strconv.ParseInt(chat, 10, 64)
+		if errParse != nil {
+			continue
+		}
+		i := slices.IndexFunc(subscriptions, func(subscription Subscription) bool { return subscription.ChatID == chatID })
+		if i >= 0 && subscriptions[i].QuietAt(cfg, now) {
+			continue
+		}
+		// The held messages of the unsubscribed chats are dropped.
+		if i >= 0 {
+			if err = sendDigest(cfg, &subscriptions[i]); err != nil {
+				log.Printf("Error sending digest to subscriber %d: %v\n", chatID, err)
+				continue
+			}
+		}
+		if err = cfg.Store().Delete(heldCollection, chat); err != nil {
+			log.Printf("Error deleting held messages of %d: %v\n", chatID, err)
+		}
+	}
+}
+
+func sendDigest(cfg *ConfigApp, subscription *Subscription) error {
+	held, err := loadHeld(cfg, subscription.ChatID)
+	if err != nil || len(held) == 0 {
+		return err
+	}
+	if len(held) == 1 {
+		return deliverHeld(cfg, subscription.ChatID, held[0])
+	}
+	message, err := DigestMessage(cfg.MessageTemplates(subscription.Language), held)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("digest-%d-%s", subscription.ChatID, held[0].Held.UTC().Format("20060102T150405"))
+	if err = DeliverChat(cfg, key, subscription.ChatID, message); err != nil {
+		return err
+	}
+	// The merged messages can't be edited, their revisions are sent as new ones.
+	for _, item := range held {
+		if err = cfg.Queue().MarkSent(item.Key, outboxChatDestination, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DigestMessage merges the held messages under the digest heading, with all their attachments.
+// The keyboards are dropped, their buttons would replace the whole digest.
+func DigestMessage(templates *MessageTemplates, held []HeldMessage) (message Message, err error) {
+	heading, err := templates.Render("digest", TemplateData{Held: len(held)})
+	if err != nil {
+		return
+	}
+	message.Class = MessageClassSummary
+	message.Document.Heading(heading)
+	for _, item := range held {
+		for _, block := range item.Message.Document.Blocks {
+			if block.Kind == BlockHeading {
+				block.Kind = BlockStrong
+			}
+			message.Document.Add(block)
+		}
+		message.Attachments = append(message.Attachments, item.Message.Attachments...)
+	}
+	return
+}
+
+func loadHeld(cfg *ConfigApp, chatID int64) (held []HeldMessage, err error) {
+	_, err = cfg.Store().Load(heldCollection, strconv.FormatInt(chatID, 10), &held)
+	return
+}
+
+// urgent tells if the message goes through the quiet hours.
+func (cfg *ConfigQuiet) urgent(message Message) bool {
+	if slices.Contains(cfg.Urgent, message.Class) {
+		return true
+	}
+	return message.Day != nil && slices.ContainsFunc(message.Day.Alerts, func(alert string) bool {
+		return slices.Contains(cfg.Urgent, alert)
+	})
+}
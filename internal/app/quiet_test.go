@@ -0,0 +1,206 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscription_QuietAt(t *testing.T) {
+	cfg := generateTestConfig()
+	subscription := cfg.NewSubscription(42)
+	now := time.Date(2025, 2, 28, 22, 30, 0, 0, cfg.Location())
+	assert.False(t, subscription.QuietAt(cfg, now))
+
+	require.NoError(t, subscription.Set("quiet", "22:00-07:00"))
+	assert.True(t, subscription.QuietAt(cfg, now))
+	assert.True(t, subscription.QuietAt(cfg, now.Add(8*time.Hour)))
+	assert.False(t, subscription.QuietAt(cfg, now.Add(9*time.Hour)))
+
+	// 21:30 in Amsterdam is 22:30 in Kyiv.
+	require.NoError(t, subscription.Set("timezone", "Europe/Kyiv"))
+	assert.True(t, subscription.QuietAt(cfg, now.Add(-time.Hour)))
+	assert.False(t, subscription.QuietAt(cfg, now.Add(8*time.Hour)))
+
+	require.NoError(t, subscription.Set("quiet", "12:00-14:00"))
+	assert.False(t, subscription.QuietAt(cfg, now))
+	require.NoError(t, subscription.Set("quiet", "off"))
+	assert.Empty(t, subscription.Quiet)
+	require.NoError(t, subscription.Set("timezone", "default"))
+	assert.Empty(t, subscription.Timezone)
+
+	assert.Error(t, subscription.Set("quiet", "22:00"))
+	assert.Error(t, subscription.Set("quiet", "22:00-7"))
+	assert.Error(t, subscription.Set("timezone", "Mars/Olympus"))
+}
+
+func TestSubscription_DayTimezone(t *testing.T) {
+	cfg := generateTestConfig()
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, subscription.Set("timezone", "Europe/Kyiv"))
+	now := time.Date(2025, 2, 28, 7, 30, 0, 0, cfg.Location())
+
+	// 15:00 in Kyiv is 14:00 in Amsterdam, before tomorrow's prices are published.
+	assert.Equal(t, "2025-02-28", subscription.Day(cfg, now).Format("2006-01-02"))
+	subscription.Time = "16:00"
+	assert.Equal(t, "2025-03-01", subscription.Day(cfg, now).Format("2006-01-02"))
+}
+
+func TestNotifySubscribers_Quiet(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	// The stub prices are negative at 14:00.
+	cfg.Quiet.Urgent = []string{MessageClassError}
+
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, subscription.Set("timezone", "Europe/Kyiv"))
+	require.NoError(t, subscription.Set("time", "16:00"))
+	require.NoError(t, subscription.Set("quiet", "15:30-17:00"))
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	NotifySubscribers(cfg, now)
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "extra", Message{Document: TextDocument("Extra")}, now.Add(time.Minute)))
+	NotifySubscribers(cfg, now.Add(59*time.Minute))
+	assert.Empty(t, telegram.Requests())
+	held, err := loadHeld(cfg, 42)
+	require.NoError(t, err)
+	require.Len(t, held, 2)
+	assert.Equal(t, "subscription-42-2025-03-01", held[0].Key)
+
+	// The quiet hours are over at 17:00 in Kyiv.
+	NotifySubscribers(cfg, now.Add(time.Hour))
+	NotifySubscribers(cfg, now.Add(time.Hour+time.Minute))
+	requests := telegram.Requests()
+	require.NotEmpty(t, requests)
+	var texts []string
+	for _, request := range requests {
+		assert.Equal(t, "42", request.Values["chat_id"])
+		texts = append(texts, request.Values["caption"]+request.Values["text"])
+	}
+	text := strings.Join(texts, "\n")
+	assert.True(t, strings.HasPrefix(text, "*During your quiet hours: 2 messages*\n"), text)
+	assert.Contains(t, text, "2025\\-03\\-01")
+	assert.Contains(t, text, "Extra")
+	assert.Equal(t, "sendPhoto", requests[0].Method)
+	held, err = loadHeld(cfg, 42)
+	require.NoError(t, err)
+	assert.Empty(t, held)
+}
+
+func TestNotifySubscribers_QuietDigest(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Quiet.Urgent = []string{MessageClassError}
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, subscription.Set("time", "14:00"))
+	require.NoError(t, subscription.Set("quiet", "15:30-17:00"))
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+	now := time.Date(2025, 2, 28, 14, 0, 0, 0, cfg.Location())
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+
+	NotifySubscribers(cfg, now)
+	sent := len(telegram.Requests())
+	require.NotZero(t, sent)
+	subscription, _, err := cfg.Subscriptions().Load(42)
+	require.NoError(t, err)
+	require.NoError(t, subscription.Set("time", "16:00"))
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+
+	// The revisions of today's message and tomorrow's message are held, the second revision replaces the first one.
+	previous, _ := generateStub()
+	prices := append([]decimal.Decimal{}, previous...)
+	prices[13] = decimal.NewFromFloat(0.01)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now.Add(100*time.Minute)))
+	prices[15] = decimal.NewFromFloat(0.07)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now.Add(110*time.Minute)))
+	NotifySubscribers(cfg, now.Add(2*time.Hour))
+	assert.Len(t, telegram.Requests(), sent)
+	held, err := loadHeld(cfg, 42)
+	require.NoError(t, err)
+	require.Len(t, held, 2)
+	assert.Equal(t, "subscription-42-2025-02-28", held[0].Edit)
+	assert.Equal(t, "subscription-42-2025-03-01", held[1].Key)
+
+	NotifySubscribers(cfg, now.Add(3*time.Hour))
+	requests := telegram.Requests()[sent:]
+	require.NotEmpty(t, requests)
+	var digests int
+	var texts []string
+	for _, request := range requests {
+		assert.Equal(t, "42", request.Values["chat_id"])
+		assert.NotContains(t, request.Method, "edit")
+		text := request.Values["caption"] + request.Values["text"]
+		if strings.Contains(text, "During your quiet hours") {
+			digests++
+		}
+		texts = append(texts, text)
+	}
+	assert.Equal(t, 1, digests)
+	text := strings.Join(texts, "\n")
+	assert.True(t, strings.HasPrefix(text, "*During your quiet hours: 2 messages*\n"), text)
+	assert.Contains(t, text, "Revised: 13:00 0\\.000 → 0\\.010, 15:00 0\\.060 → 0\\.070")
+	assert.Contains(t, text, "2025\\-03\\-01")
+	held, err = loadHeld(cfg, 42)
+	require.NoError(t, err)
+	assert.Empty(t, held)
+}
+
+func TestDeliverSubscriber_Urgent(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	cfg.Quiet.Urgent = []string{AlertNegative, MessageClassError}
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, subscription.Set("quiet", "00:00-23:59"))
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+
+	negative := Message{Document: TextDocument("Negative"), Day: &DayReport{Day: day, Alerts: []string{AlertLow, AlertNegative}}}
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "negative", negative, now))
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "error", Message{Class: MessageClassError, Document: TextDocument("Error")}, now))
+	low := Message{Document: TextDocument("Low"), Day: &DayReport{Day: day, Alerts: []string{AlertLow}}}
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "low", low, now))
+	// The same key is held once.
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "low", low, now))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "Negative", requests[0].Values["text"])
+	assert.Equal(t, "Error", requests[1].Values["text"])
+
+	// A revised message is replaced while held, a single one is sent as it is.
+	ok, err := ReplaceHeld(cfg, 42, "low", Message{Document: TextDocument("Revised low")})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ReplaceHeld(cfg, 42, "unknown", low)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, subscription.Set("quiet", "off"))
+	SendDigests(cfg, []Subscription{subscription}, now)
+	requests = telegram.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, "Revised low", requests[2].Values["text"])
+}
+
+func TestSendDigests_Unsubscribed(t *testing.T) {
+	cfg := generateBotTestConfig()
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, subscription.Set("quiet", "00:00-23:59"))
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	require.NoError(t, DeliverSubscriber(cfg, &subscription, "low", Message{Document: TextDocument("Low")}, now))
+
+	SendDigests(cfg, nil, now)
+	held, err := loadHeld(cfg, 42)
+	require.NoError(t, err)
+	assert.Empty(t, held)
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+const ReportPeriodWeek = "week"
+const ReportPeriodMonth = "month"
+
+// Peak hours are 08:00-20:00 on working days, as for the EPEX peak load products.
+const peakHourFrom = 8
+const peakHourTill = 20
+
+var ErrUnknownReportPeriod = errors.New("unknown report period")
+
+// PeriodBounds returns the week (from Monday) or the calendar month containing the day.
+func PeriodBounds(period string, day time.Time) (from, till time.Time, err error) {
+	location := day.Location()
+	switch period {
+	case ReportPeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		from = time.Date(day.Year(), day.Month(), day.Day()-offset, 0, 0, 0, 0, location)
+		till = from.AddDate(0, 0, 7)
+	case ReportPeriodMonth:
+		from = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, location)
+		till = from.AddDate(0, 1, 0)
+	default:
+		err = ErrUnknownReportPeriod
+	}
+	return
+}
+
+// BuildPeriodReport calculates the stats of the stored days of the period containing the day
+// and compares them with the previous period.
+func BuildPeriodReport(history *PriceHistory, period string, day time.Time) (report models.PeriodReport, err error) {
+	from, till, err := PeriodBounds(period, day)
+	if err != nil {
+		return
+	}
+	report.Period = period
+	if report.Current, err = buildPeriodStats(history, from, till); err != nil {
+		return
+	}
+
+	prevFrom, prevTill, _ := PeriodBounds(period, from.AddDate(0, 0, -1))
+	previous, errPrevious := buildPeriodStats(history, prevFrom, prevTill)
+	if errors.Is(errPrevious, ErrNotEnoughHistory) {
+		return
+	} else if errPrevious != nil {
+		err = errPrevious
+		return
+	}
+	report.Previous = &previous
+	baseChange := report.Current.BaseAverage.Sub(previous.BaseAverage)
+	peakChange := report.Current.PeakAverage.Sub(previous.PeakAverage)
+	report.BaseChange, report.PeakChange = &baseChange, &peakChange
+
+	return
+}
+
+func buildPeriodStats(history *PriceHistory, from, till time.Time) (stats models.PeriodStats, err error) {
+	days, err := history.Range(from, till)
+	if err != nil {
+		return
+	}
+	if len(days) == 0 {
+		err = ErrNotEnoughHistory
+		return
+	}
+
+	stats.From = from.Format("2006-01-02")
+	stats.Till = till.AddDate(0, 0, -1).Format("2006-01-02")
+	stats.Days = len(days)
+	var base, peak []decimal.Decimal
+	var hours [][]decimal.Decimal
+	for _, historyDay := range days {
+		if len(historyDay.Prices) == 0 {
+			continue
+		}
+		dayAverage := models.DayAverage{
+			Date:    historyDay.Day.Format("2006-01-02"),
+			Average: decimal.Avg(historyDay.Prices[0], historyDay.Prices[1:]...).Round(5),
+		}
+		if stats.CheapestDay.Date == "" || dayAverage.Average.LessThan(stats.CheapestDay.Average) {
+			stats.CheapestDay = dayAverage
+		}
+		if stats.MostExpensiveDay.Date == "" || dayAverage.Average.GreaterThan(stats.MostExpensiveDay.Average) {
+			stats.MostExpensiveDay = dayAverage
+		}
+
+		isWorkingDay := historyDay.Day.Weekday() != time.Saturday && historyDay.Day.Weekday() != time.Sunday
+		for h, price := range historyDay.Prices {
+			base = append(base, price)
+			if isWorkingDay && h >= peakHourFrom && h < peakHourTill {
+				peak = append(peak, price)
+			}
+			if price.IsNegative() {
+				stats.NegativeHours++
+			}
+			for len(hours) <= h {
+				hours = append(hours, nil)
+			}
+			hours[h] = append(hours[h], price)
+		}
+	}
+
+	if len(base) > 0 {
+		stats.BaseAverage = decimal.Avg(base[0], base[1:]...).Round(5)
+	}
+	if len(peak) > 0 {
+		stats.PeakAverage = decimal.Avg(peak[0], peak[1:]...).Round(5)
+	}
+	stats.HourAverages = make([]decimal.Decimal, len(hours))
+	for h, prices := range hours {
+		stats.HourAverages[h] = decimal.Avg(prices[0], prices[1:]...).Round(5)
+	}
+
+	return
+}
+
+// PeriodReportDocument builds the message with the stats table and the chart of hour averages,
+// the numbers are formatted with the locale.
+func PeriodReportDocument(cfg *ConfigAnalytics, locale *Locale, report *models.PeriodReport) (doc Document, err error) {
+	current, previous := report.Current, report.Previous
+	if previous == nil {
+		previous = &models.PeriodStats{}
+	}
+	rows := [][3]string{
+		{"", "This", "Prev"},
+		{"Base avg", locale.Price(current.BaseAverage), locale.Price(previous.BaseAverage)},
+		{"Peak avg", locale.Price(current.PeakAverage), locale.Price(previous.PeakAverage)},
+		{"Negative h", fmt.Sprint(current.NegativeHours), fmt.Sprint(previous.NegativeHours)},
+		{"Days", fmt.Sprint(current.Days), fmt.Sprint(previous.Days)},
+	}
+	if report.Previous == nil {
+		for i := 1; i < len(rows); i++ {
+			rows[i][2] = "-"
+		}
+	}
+	var table strings.Builder
+	for _, row := range rows {
+		table.WriteString(fmt.Sprintf("%-11s%8s%8s\n", row[0], row[1], row[2]))
+	}
+	table.WriteString(fmt.Sprintf("Cheapest   %s %s\n", current.CheapestDay.Date, locale.Price(current.CheapestDay.Average)))
+	table.WriteString(fmt.Sprintf("Expensive  %s %s\n", current.MostExpensiveDay.Date, locale.Price(current.MostExpensiveDay.Average)))
+
+	chart, err := drawLinesBarChart(cfg, locale, current.HourAverages, 20)
+	if err != nil {
+		return
+	}
+
+	doc.Heading(fmt.Sprintf("EPEX NL %s %s – %s", report.Period, current.From, current.Till))
+	doc.Add(Block{Kind: BlockCode, Text: table.String()})
+	doc.Add(chart)
+	return
+}
+
+// SendPeriodReport sends the report of the last complete period before now.
+func SendPeriodReport(cfg *ConfigApp, period string, now time.Time) error {
+	report, err := BuildPeriodReport(cfg.History(), period, now.In(cfg.Location()).AddDate(0, 0, -1))
+	if err != nil {
+		return fmt.Errorf("failed to build %s report: %w", period, err)
+	}
+	message, err := PeriodReportMessage(&cfg.Analytics, cfg.MessageTemplates("").Locale(), &report)
+	if err != nil {
+		return err
+	}
+	return Deliver(cfg, "report-"+period+"-"+report.Current.From, message)
+}
+
+// PeriodReportMessage is the report document with the PNG chart of the hour averages attached.
+func PeriodReportMessage(cfg *ConfigAnalytics, locale *Locale, report *models.PeriodReport) (message Message, err error) {
+	if message.Document, err = PeriodReportDocument(cfg, locale, report); err != nil {
+		return
+	}
+	image, err := PeriodChartPNG(cfg, report)
+	if err != nil {
+		return
+	}
+	message.Class = MessageClassSummary
+	message.Attachments = []Attachment{{
+		Kind:     AttachmentPhoto,
+		Name:     fmt.Sprintf("epex_nl_%s_%s.png", report.Period, report.Current.From),
+		MimeType: "image/png",
+		Data:     image,
+	}}
+	return
+}
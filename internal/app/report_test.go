@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	from, till, err := PeriodBounds(ReportPeriodWeek, day)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 2, 24, 0, 0, 0, 0, cfg.Location()), from)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, cfg.Location()), till)
+
+	from, till, err = PeriodBounds(ReportPeriodMonth, day)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, cfg.Location()), from)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location()), till)
+
+	_, _, err = PeriodBounds("decade", day)
+	assert.Equal(t, ErrUnknownReportPeriod, err)
+}
+
+func TestBuildPeriodReport(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, cfg.Location())
+	saveTestHistory(t, cfg, day, 14)
+
+	report, err := BuildPeriodReport(cfg.History(), ReportPeriodWeek, day.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, "2025-02-24", report.Current.From)
+	assert.Equal(t, "2025-03-02", report.Current.Till)
+	assert.Equal(t, 7, report.Current.Days)
+	assert.Equal(t, "2025-02-28", report.Current.MostExpensiveDay.Date)
+	assert.Equal(t, "0.215", report.Current.MostExpensiveDay.Average.String())
+	assert.Equal(t, "0.115", report.Current.CheapestDay.Average.String())
+	assert.Len(t, report.Current.HourAverages, 24)
+	assert.Equal(t, 0, report.Current.NegativeHours)
+
+	// Peak is 08:00-20:00 on the working days only: 0.135 on 4 days and 0.235 on Friday.
+	assert.Equal(t, "0.155", report.Current.PeakAverage.String())
+	require.NotNil(t, report.Previous)
+	assert.Equal(t, "0", report.BaseChange.String())
+
+	_, err = BuildPeriodReport(cfg.History(), ReportPeriodWeek, day)
+	assert.Equal(t, ErrNotEnoughHistory, err)
+}
+
+func TestPeriodReportDocument(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, cfg.Location())
+	saveTestHistory(t, cfg, day, 7)
+
+	report, err := BuildPeriodReport(cfg.History(), ReportPeriodWeek, day.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Nil(t, report.Previous)
+
+	doc, err := PeriodReportDocument(&cfg.Analytics, LocaleFor("en"), &report)
+	require.NoError(t, err)
+	message := doc.Format(FormatMarkdownV2)
+	assert.Contains(t, message, "*EPEX NL week 2025\\-02\\-24 – 2025\\-03\\-02*")
+	assert.Contains(t, message, "Base avg      0.129       -")
+	assert.Contains(t, message, "`23:00`")
+}
+
+func TestPeriodReportMessage(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, cfg.Location())
+	saveTestHistory(t, cfg, day, 7)
+
+	report, err := BuildPeriodReport(cfg.History(), ReportPeriodWeek, day.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	message, err := PeriodReportMessage(&cfg.Analytics, LocaleFor("en"), &report)
+	require.NoError(t, err)
+	assert.Equal(t, MessageClassSummary, message.Class)
+	require.Len(t, message.Attachments, 1)
+	assert.Equal(t, "epex_nl_week_2025-02-24.png", message.Attachments[0].Name)
+	img, err := png.Decode(bytes.NewReader(message.Attachments[0].Data))
+	require.NoError(t, err)
+	assert.Equal(t, chartImageWidth, img.Bounds().Dx())
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// revisionChangesLimit is the number of the revised slots listed in the note.
+const revisionChangesLimit = 4
+
+// PriceChange is a revised slot of the day.
+type PriceChange struct {
+	Start    time.Time
+	Previous decimal.Decimal
+	Price    decimal.Decimal
+}
+
+// priceChanges returns the slots with different prices, the missing ones are zero.
+func priceChanges(previous, prices []decimal.Decimal, day time.Time) (changes []PriceChange) {
+	for i := 0; i < max(len(previous), len(prices)); i++ {
+		var old, price decimal.Decimal
+		if i < len(previous) {
+			old = previous[i]
+		}
+		if i < len(prices) {
+			price = prices[i]
+		}
+		if !old.Equal(price) {
+			changes = append(changes, PriceChange{Start: day.Add(time.Duration(i) * time.Hour), Previous: old, Price: price})
+		}
+	}
+	return
+}
+
+// CheckRevisions fetches today's and tomorrow's stored prices again
+// and replaces the sent messages of the corrected days.
+func CheckRevisions(cfg *ConfigApp, now time.Time) {
+	now = now.In(cfg.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, cfg.Location())
+	var errs []error
+	defer func() {
+		var errReport error
+		if len(errs) > 0 {
+			errReport = ReportFailure(cfg, FailureSourceRevisions, errors.Join(errs...), now)
+		} else {
+			errReport = ReportSuccess(cfg, FailureSourceRevisions)
+		}
+		if errReport != nil {
+			log.Printf("Error reporting revisions check: %v\n", errReport)
+		}
+	}()
+	for _, day := range []time.Time{today, today.AddDate(0, 0, 1)} {
+		previous, prices, revised, err := RevisePrices(cfg, day)
+		if err != nil {
+			log.Printf("Error checking revised prices for %s: %v\n", day.Format("2006-01-02"), err)
+			errs = append(errs, err)
+			continue
+		}
+		if !revised {
+			continue
+		}
+		log.Printf("Prices for %s are revised\n", day.Format("2006-01-02"))
+		if err = NotifyRevision(cfg, day, previous, prices, now); err != nil {
+			log.Printf("Error notifying revised prices for %s: %v\n", day.Format("2006-01-02"), err)
+		}
+	}
+}
+
+// NotifyRevision replaces the day messages sent to the destinations and the subscribers with the revised ones,
+// the subscribers get them after their quiet hours.
+func NotifyRevision(cfg *ConfigApp, day time.Time, previous, prices []decimal.Decimal, now time.Time) error {
+	date := day.Format("2006-01-02")
+	// Every revision of the day has its own key, the same prices are never sent twice.
+	hash := sha256.Sum256([]byte(fmt.Sprint(prices)))
+	key := fmt.Sprintf("%s-%s-%s", MessageClassRevision, date, hex.EncodeToString(hash[:4]))
+
+	templates := cfg.MessageTemplates("")
+	message, err := BuildDayMessage(&cfg.Analytics, templates, prices, day)
+	if err != nil {
+		return err
+	}
+	if message.Keyboard, err = DayKeyboard(&cfg.Messenger, templates, day); err != nil {
+		return err
+	}
+	if err = addRevisionNote(&message, templates, previous, prices, day); err != nil {
+		return err
+	}
+	if err = DeliverEdit(cfg, MessageClassPrices+"-"+date, key, message); err != nil {
+		return err
+	}
+
+	subscriptions, err := cfg.Subscriptions().All()
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		original := fmt.Sprintf("subscription-%d-%s", subscription.ChatID, date)
+		if delivered, errDelivered := subscriptionDelivered(cfg, subscription.ChatID, original); errDelivered != nil {
+			log.Printf("Error checking message of subscriber %d: %v\n", subscription.ChatID, errDelivered)
+			continue
+		} else if !delivered {
+			continue
+		}
+		message, ok, err := SubscriptionMessage(cfg, &subscription, prices, day)
+		if err != nil {
+			log.Printf("Error building revised message for subscriber %d: %v\n", subscription.ChatID, err)
+			continue
+		} else if !ok {
+			continue
+		}
+		templates := cfg.MessageTemplates(subscription.Language)
+		previous, prices := subscription.Prices(&cfg.Tariff, previous), subscription.Prices(&cfg.Tariff, prices)
+		if err = addRevisionNote(&message, templates, previous, prices, day); err != nil {
+			return err
+		}
+		// The message held during the quiet hours isn't sent yet, so it's revised in place.
+		if held, errHeld := ReplaceHeld(cfg, subscription.ChatID, original, message); errHeld != nil || held {
+			if errHeld != nil {
+				log.Printf("Error revising held message of subscriber %d: %v\n", subscription.ChatID, errHeld)
+			}
+			continue
+		}
+		if err = DeliverSubscriberEdit(cfg, &subscription, original, fmt.Sprintf("%s-%d", key, subscription.ChatID), message, now); err != nil {
+			log.Printf("Error notifying subscriber %d: %v\n", subscription.ChatID, err)
+		}
+	}
+	return nil
+}
+
+// subscriptionDelivered tells if the message under the key was sent to the subscriber, directly or in a digest,
+// or is held during the quiet hours.
+func subscriptionDelivered(cfg *ConfigApp, chatID int64, key string) (bool, error) {
+	if sent, err := cfg.Queue().Sent(key, outboxChatDestination); err != nil || sent {
+		return sent, err
+	}
+	held, err := loadHeld(cfg, chatID)
+	return slices.ContainsFunc(held, func(item HeldMessage) bool { return item.Key == key }), err
+}
+
+// addRevisionNote marks the message as revised with the changed slots under the heading.
+func addRevisionNote(message *Message, templates *MessageTemplates, previous, prices []decimal.Decimal, day time.Time) error {
+	changes := priceChanges(previous, prices, day)
+	data := TemplateData{Day: day, Changes: changes, More: max(len(changes)-revisionChangesLimit, 0)}
+	data.Changes = changes[:len(changes)-data.More]
+	note, err := templates.Render("revision", data)
+	if err != nil {
+		return err
+	}
+
+	message.Class = MessageClassRevision
+	doc := &message.Document
+	block := Block{Kind: BlockContext, Text: note}
+	if doc.hasHeading() {
+		doc.Blocks = append(doc.Blocks[:1], append([]Block{block}, doc.Blocks[1:]...)...)
+	} else {
+		doc.Add(block)
+	}
+	return nil
+}
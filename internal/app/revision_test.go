@@ -0,0 +1,160 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevisePrices(t *testing.T) {
+	cfg := generateBotTestConfig()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+	stub, _ := generateStub()
+
+	// Not stored days aren't fetched.
+	_, _, revised, err := RevisePrices(cfg, day)
+	require.NoError(t, err)
+	assert.False(t, revised)
+	_, ok, err := cfg.History().Load(day)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cfg.History().Save(day, stub))
+	_, _, revised, err = RevisePrices(cfg, day)
+	require.NoError(t, err)
+	assert.False(t, revised)
+
+	stored := append([]decimal.Decimal{}, stub...)
+	stored[13] = decimal.NewFromFloat(0.01)
+	require.NoError(t, cfg.History().Save(day, stored))
+	previous, prices, revised, err := RevisePrices(cfg, day)
+	require.NoError(t, err)
+	assert.True(t, revised)
+	assert.Equal(t, "0.01", previous[13].String())
+	assert.Equal(t, "0", prices[13].String())
+	stored, _, err = cfg.History().Load(day)
+	require.NoError(t, err)
+	assert.Equal(t, "0", stored[13].String())
+}
+
+func TestPriceChanges(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+	previous := []decimal.Decimal{decimal.NewFromFloat(0.1), decimal.NewFromFloat(0.2)}
+	prices := []decimal.Decimal{decimal.NewFromFloat(0.1), decimal.NewFromFloat(0.25), decimal.NewFromFloat(0.3)}
+
+	changes := priceChanges(previous, prices, day)
+	require.Len(t, changes, 2)
+	assert.Equal(t, day.Add(time.Hour), changes[0].Start)
+	assert.Equal(t, "0.2", changes[0].Previous.String())
+	assert.Equal(t, "0.25", changes[0].Price.String())
+	assert.Equal(t, "0", changes[1].Previous.String())
+	assert.Empty(t, priceChanges(prices, prices, day))
+}
+
+func TestNotifyRevision(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateOutboxTestConfig(telegram)
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+	subscription := cfg.NewSubscription(42)
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	require.NoError(t, NotifyDay(cfg, day))
+	NotifySubscribers(cfg, now)
+	cfg.Queue().Process(time.Now())
+	requests := telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "sendPhoto", requests[0].Method)
+	assert.Equal(t, "sendPhoto", requests[1].Method)
+	assert.Equal(t, "42", requests[1].Values["chat_id"])
+
+	previous, _ := generateStub()
+	prices := append([]decimal.Decimal{}, previous...)
+	prices[13] = decimal.NewFromFloat(0.01)
+	prices[14] = decimal.NewFromFloat(-0.03)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now))
+	// The same revision isn't queued twice.
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now))
+	cfg.Queue().Process(time.Now())
+
+	requests = telegram.Requests()
+	require.Len(t, requests, 4)
+	assert.Equal(t, "editMessageMedia", requests[2].Method)
+	assert.Equal(t, "123", requests[2].Values["chat_id"])
+	assert.Equal(t, "1", requests[2].Values["message_id"])
+	assert.Contains(t, requests[2].Values["media"], `Revised: 13:00 0\\.000 → 0\\.010, 14:00 \\-0\\.020 → \\-0\\.030`)
+	assert.Equal(t, "editMessageMedia", requests[3].Method)
+	assert.Equal(t, "42", requests[3].Values["chat_id"])
+	assert.Equal(t, "2", requests[3].Values["message_id"])
+
+	// The next revision edits the same messages.
+	prices[15] = decimal.NewFromFloat(0.07)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now))
+	cfg.Queue().Process(time.Now())
+	requests = telegram.Requests()
+	require.Len(t, requests, 6)
+	assert.Equal(t, "1", requests[4].Values["message_id"])
+}
+
+func TestNotifyRevision_WithoutOutbox(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+	require.NoError(t, cfg.Subscriptions().Save(cfg.NewSubscription(42)))
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+
+	require.NoError(t, NotifyDay(cfg, day))
+	NotifySubscribers(cfg, now)
+	require.Len(t, telegram.Requests(), 2)
+	// The revision follows the sent message, not the last sent day of the subscription.
+	subscription, _, err := cfg.Subscriptions().Load(42)
+	require.NoError(t, err)
+	subscription.LastSent = ""
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+
+	previous, _ := generateStub()
+	prices := append([]decimal.Decimal{}, previous...)
+	prices[13] = decimal.NewFromFloat(0.01)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now))
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 4)
+	assert.Equal(t, "editMessageMedia", requests[2].Method)
+	assert.Equal(t, "123", requests[2].Values["chat_id"])
+	assert.Equal(t, "editMessageMedia", requests[3].Method)
+	assert.Equal(t, "42", requests[3].Values["chat_id"])
+	assert.Equal(t, "2", requests[3].Values["message_id"])
+
+	// Nothing is revised for the chats which didn't get the day.
+	require.NoError(t, cfg.Subscriptions().Save(cfg.NewSubscription(7)))
+	prices[14] = decimal.NewFromFloat(0.02)
+	require.NoError(t, NotifyRevision(cfg, day, previous, prices, now))
+	requests = telegram.Requests()
+	require.Len(t, requests, 6)
+	assert.NotEqual(t, "7", requests[5].Values["chat_id"])
+}
+
+func TestAddRevisionNote(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, cfg.Location())
+	previous, _ := generateStub()
+	prices := make([]decimal.Decimal, len(previous))
+	for i := range prices {
+		prices[i] = previous[i].Add(decimal.NewFromFloat(0.01))
+	}
+
+	message := Message{Document: TextDocument("2025-03-01: there are Low prices")}
+	require.NoError(t, addRevisionNote(&message, cfg.MessageTemplates("nl"), previous, prices, day))
+	assert.Equal(t, MessageClassRevision, message.Class)
+	assert.Equal(t,
+		"2025-03-01: there are Low prices\nHerzien: 00:00 0,150 → 0,160, 01:00 0,130 → 0,140, 02:00 0,120 → 0,130, 03:00 0,110 → 0,120 en nog 20",
+		message.Document.Format(FormatPlain),
+	)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const schedulerInterval = time.Minute
+
+// Scheduler runs jobs when they are due, checking them once a minute in the config location.
+type Scheduler struct {
+	mu       sync.Mutex
+	location *time.Location
+	jobs     []scheduledJob
+}
+
+type scheduledJob struct {
+	name    string
+	due     func(now time.Time) bool
+	run     func(now time.Time)
+	lastRun time.Time
+}
+
+func NewScheduler(location *time.Location) *Scheduler {
+	return &Scheduler{location: location}
+}
+
+func (scheduler *Scheduler) Add(name string, due func(now time.Time) bool, run func(now time.Time)) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.jobs = append(scheduler.jobs, scheduledJob{name: name, due: due, run: run})
+}
+
+// Run checks the jobs at the start of every minute until the context is done.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	for {
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(schedulerInterval).Add(schedulerInterval).Sub(now)):
+			scheduler.Tick(time.Now())
+		}
+	}
+}
+
+// Tick runs the due jobs, every job runs at most once a minute.
+func (scheduler *Scheduler) Tick(now time.Time) {
+	now = now.In(scheduler.location).Truncate(schedulerInterval)
+	scheduler.mu.Lock()
+	var jobs []scheduledJob
+	for i := range scheduler.jobs {
+		job := &scheduler.jobs[i]
+		if job.lastRun.Equal(now) || !job.due(now) {
+			continue
+		}
+		job.lastRun = now
+		jobs = append(jobs, *job)
+	}
+	scheduler.mu.Unlock()
+
+	for _, job := range jobs {
+		log.Printf("Running scheduled job %s\n", job.name)
+		job.run(now)
+	}
+}
+
+// Daily is due every day at the clock time.
+func Daily(clock string) (func(now time.Time) bool, error) {
+	hour, minute, err := parseClock(clock)
+	if err != nil {
+		return nil, err
+	}
+	return func(now time.Time) bool {
+		return now.Hour() == hour && now.Minute() == minute
+	}, nil
+}
+
+// Hourly is due at the start of every hour.
+func Hourly(now time.Time) bool {
+	return now.Minute() == 0
+}
+
+// Weekly is due on the weekday at the clock time.
+func Weekly(weekday time.Weekday, clock string) (func(now time.Time) bool, error) {
+	daily, err := Daily(clock)
+	if err != nil {
+		return nil, err
+	}
+	return func(now time.Time) bool {
+		return now.Weekday() == weekday && daily(now)
+	}, nil
+}
+
+// Monthly is due on the day of the month at the clock time.
+func Monthly(day int, clock string) (func(now time.Time) bool, error) {
+	daily, err := Daily(clock)
+	if err != nil {
+		return nil, err
+	}
+	return func(now time.Time) bool {
+		return now.Day() == day && daily(now)
+	}, nil
+}
+
+// parseClock parses "HH:MM".
+func parseClock(clock string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		err = fmt.Errorf("invalid time %q, expected HH:MM", clock)
+		return
+	}
+	return t.Hour(), t.Minute(), nil
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerTick(t *testing.T) {
+	cfg := generateTestConfig()
+	scheduler := NewScheduler(cfg.Location())
+	due, err := Daily("09:30")
+	require.NoError(t, err)
+	var runs []time.Time
+	scheduler.Add("test", due, func(now time.Time) {
+		runs = append(runs, now)
+	})
+
+	day := time.Date(2025, 2, 28, 9, 30, 0, 0, cfg.Location())
+	scheduler.Tick(day.Add(-time.Minute))
+	scheduler.Tick(day)
+	scheduler.Tick(day.Add(20 * time.Second))
+	scheduler.Tick(day.AddDate(0, 0, 1))
+
+	require.Len(t, runs, 2)
+	assert.Equal(t, day, runs[0])
+}
+
+func TestWeeklyMonthly(t *testing.T) {
+	cfg := generateTestConfig()
+	monday := time.Date(2025, 3, 3, 9, 0, 0, 0, cfg.Location())
+
+	weekly, err := Weekly(time.Monday, "09:00")
+	require.NoError(t, err)
+	assert.True(t, weekly(monday))
+	assert.False(t, weekly(monday.AddDate(0, 0, 1)))
+
+	assert.True(t, Hourly(monday))
+	assert.False(t, Hourly(monday.Add(time.Minute)))
+
+	monthly, err := Monthly(1, "09:00")
+	require.NoError(t, err)
+	assert.True(t, monthly(monday.AddDate(0, 0, -2)))
+	assert.False(t, monthly(monday))
+
+	_, err = Daily("25:00")
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const slackHttpTimeout = 10 * time.Second
+
+// Slack limits the text of a section block, in characters.
+const slackSectionLimit = 3000
+
+var slackReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessenger posts to a Slack incoming webhook,
+// the attachments are skipped as the webhooks can't upload files.
+type slackMessenger struct {
+	cfg *ConfigSlack
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks,omitempty"`
+}
+
+func (messenger *slackMessenger) Send(message Message) error {
+	payload := slackDocumentPayload(message.Document)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), slackHttpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, messenger.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to post to Slack, status %s: %s", resp.Status, respBody)
+	}
+	return nil
+}
+
+// slackDocumentPayload lays the document with the heading out as blocks: the heading as a header,
+// the fields as a section, the chart and the code in code blocks and the context as a context block.
+// Other documents go as the text.
+func slackDocumentPayload(doc Document) slackPayload {
+	if !doc.hasHeading() {
+		return slackPayload{Text: FormatSlack.Format(doc)}
+	}
+	payload := slackPayload{Text: slackEscape(doc.Title())}
+	for _, block := range doc.Blocks {
+		switch block.Kind {
+		case BlockHeading:
+			payload.Blocks = append(payload.Blocks, slackBlock{
+				Type: "header",
+				Text: &slackText{Type: "plain_text", Text: block.Text},
+			})
+		case BlockFields:
+			fields := slackBlock{Type: "section"}
+			for _, field := range block.Fields {
+				fields.Fields = append(fields.Fields, slackText{Type: "mrkdwn", Text: "*" + slackEscape(field[0]) + "*\n" + slackEscape(field[1])})
+			}
+			payload.Blocks = append(payload.Blocks, fields)
+		case BlockChart, BlockCode:
+			text := FormatPlain.Format(Document{Blocks: []Block{block}})
+			for _, part := range splitTelegramText(slackEscape(text)+"\n", slackSectionLimit-8) {
+				payload.Blocks = append(payload.Blocks, slackBlock{
+					Type: "section",
+					Text: &slackText{Type: "mrkdwn", Text: "```\n" + part + "```"},
+				})
+			}
+		case BlockContext:
+			payload.Blocks = append(payload.Blocks, slackBlock{
+				Type:     "context",
+				Elements: []slackText{{Type: "mrkdwn", Text: slackEscape(block.Text)}},
+			})
+		default:
+			payload.Blocks = append(payload.Blocks, slackBlock{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: FormatSlack.Format(Document{Blocks: []Block{block}})},
+			})
+		}
+	}
+	return payload
+}
+
+// slackEscape escapes the control characters of Slack mrkdwn.
+func slackEscape(text string) string {
+	return slackReplacer.Replace(text)
+}
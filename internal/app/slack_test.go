@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFakeSlack(status int, payloads *[]slackPayload) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload slackPayload
+		if err := json.Unmarshal(body, &payload); err == nil {
+			*payloads = append(*payloads, payload)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok"))
+	}))
+}
+
+func TestSlackMessenger_Day(t *testing.T) {
+	var payloads []slackPayload
+	server := generateFakeSlack(http.StatusOK, &payloads)
+	defer server.Close()
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+
+	messenger, err := NewMessenger(&ConfigMessenger{Driver: "slack", Slack: ConfigSlack{WebhookURL: server.URL}})
+	require.NoError(t, err)
+	require.NoError(t, messenger.Send(message))
+
+	require.Len(t, payloads, 1)
+	blocks := payloads[0].Blocks
+	require.Len(t, blocks, 5)
+	assert.Equal(t, "header", blocks[0].Type)
+	assert.Equal(t, "EPEX NL Day-Ahead 2025-02-28", blocks[0].Text.Text)
+	assert.Equal(t, "*There are Low prices*", blocks[1].Text.Text)
+	assert.Equal(t, "*Lowest*\n-0.020 at 14:00", blocks[2].Fields[0].Text)
+	assert.Equal(t, "*Highest*\n0.180 at 19:00", blocks[2].Fields[1].Text)
+	assert.Equal(t, "*Average*\n0.109", blocks[2].Fields[2].Text)
+	assert.True(t, strings.HasPrefix(blocks[3].Text.Text, "```\n00:00 "))
+	assert.Contains(t, blocks[3].Text.Text, "🟢 -0.02\n")
+	assert.NotContains(t, blocks[3].Text.Text, "\\")
+	assert.Equal(t, "context", blocks[4].Type)
+	assert.Equal(t, "🟢 Low ≤ 0.10   Normal   🔴 High ≥ 0.20", blocks[4].Elements[0].Text)
+}
+
+func TestSlackMessenger_Text(t *testing.T) {
+	var payloads []slackPayload
+	server := generateFakeSlack(http.StatusOK, &payloads)
+	defer server.Close()
+	messenger := &slackMessenger{cfg: &ConfigSlack{WebhookURL: server.URL}}
+
+	require.NoError(t, messenger.Send(Message{Class: MessageClassError, Document: TextDocument("Error for 2025-02-28 <api>")}))
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "Error for 2025-02-28 &lt;api&gt;", payloads[0].Text)
+	assert.Empty(t, payloads[0].Blocks)
+}
+
+func TestSlackMessenger_Error(t *testing.T) {
+	var payloads []slackPayload
+	server := generateFakeSlack(http.StatusNotFound, &payloads)
+	defer server.Close()
+	messenger := &slackMessenger{cfg: &ConfigSlack{WebhookURL: server.URL}}
+
+	assert.Error(t, messenger.Send(Message{Document: TextDocument("test")}))
+}
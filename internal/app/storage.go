@@ -0,0 +1,152 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage keeps JSON documents by collection and key.
+type Storage interface {
+	Load(collection, key string, v any) (bool, error)
+	Save(collection, key string, v any) error
+	Delete(collection, key string) error
+	Keys(collection string) ([]string, error)
+}
+
+func NewStorage(cfg *ConfigStorage) (Storage, error) {
+	switch cfg.Driver {
+	case storageDriverMemory:
+		return &memoryStorage{data: map[string]map[string][]byte{}}, nil
+	case storageDriverFile:
+		if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+		return &fileStorage{path: cfg.Path}, nil
+	default:
+		return nil, errors.New("unknown storage driver")
+	}
+}
+
+type memoryStorage struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+}
+
+func (s *memoryStorage) Load(collection, key string, v any) (bool, error) {
+	s.mu.RLock()
+	data, ok := s.data[collection][key]
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (s *memoryStorage) Save(collection, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[collection] == nil {
+		s.data[collection] = map[string][]byte{}
+	}
+	s.data[collection][key] = data
+	return nil
+}
+
+func (s *memoryStorage) Delete(collection, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data[collection], key)
+	return nil
+}
+
+func (s *memoryStorage) Keys(collection string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.data[collection]))
+	for key := range s.data[collection] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// fileStorage keeps every document in its own file: <path>/<collection>/<key>.json.
+type fileStorage struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *fileStorage) Load(collection, key string, v any) (bool, error) {
+	s.mu.Lock()
+	data, err := os.ReadFile(s.filename(collection, key))
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s/%s: %w", collection, key, err)
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (s *fileStorage) Save(collection, key string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(filepath.Join(s.path, collection), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", collection, err)
+	}
+	// Write and rename, so a crash never leaves a half-written document.
+	filename := s.filename(collection, key)
+	if err = os.WriteFile(filename+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", collection, key, err)
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+func (s *fileStorage) Delete(collection, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.filename(collection, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s/%s: %w", collection, key, err)
+	}
+	return nil
+}
+
+func (s *fileStorage) Keys(collection string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(filepath.Join(s.path, collection))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", collection, err)
+	}
+	var keys []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") {
+			keys = append(keys, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *fileStorage) filename(collection, key string) string {
+	return filepath.Join(s.path, collection, filepath.Base(key)+".json")
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	for _, cfg := range []ConfigStorage{
+		{Driver: storageDriverMemory},
+		{Driver: storageDriverFile, Path: t.TempDir()},
+	} {
+		t.Run(cfg.Driver, func(t *testing.T) {
+			storage, err := NewStorage(&cfg)
+			require.NoError(t, err)
+
+			var value map[string]int
+			ok, err := storage.Load("test", "a", &value)
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			require.NoError(t, storage.Save("test", "b", map[string]int{"x": 2}))
+			require.NoError(t, storage.Save("test", "a", map[string]int{"x": 1}))
+			ok, err = storage.Load("test", "a", &value)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, 1, value["x"])
+
+			keys, err := storage.Keys("test")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, keys)
+
+			require.NoError(t, storage.Delete("test", "a"))
+			require.NoError(t, storage.Delete("test", "a"))
+			keys, err = storage.Keys("test")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b"}, keys)
+
+			keys, err = storage.Keys("empty")
+			require.NoError(t, err)
+			assert.Empty(t, keys)
+		})
+	}
+}
+
+func TestNewStorage_Error(t *testing.T) {
+	_, err := NewStorage(&ConfigStorage{Driver: "tape"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,387 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const subscriptionsCollection = "subscriptions"
+
+// Alerts a subscriber can choose.
+const (
+	AlertDay      = "day"
+	AlertHigh     = "high"
+	AlertLow      = "low"
+	AlertNegative = "negative"
+)
+
+// Tariff profiles: the bare day-ahead price or the all-in dynamic price with the markup and the energy tax.
+const (
+	TariffProfileEPEX    = "epex"
+	TariffProfileDynamic = "dynamic"
+)
+
+var subscriptionAlerts = []string{AlertDay, AlertHigh, AlertLow, AlertNegative}
+var subscriptionTariffs = []string{TariffProfileEPEX, TariffProfileDynamic}
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// Subscription keeps the preferences of a chat, the thresholds are the config ones when not set.
+type Subscription struct {
+	ChatID    int64            `json:"chat_id"`
+	HighPrice *decimal.Decimal `json:"high_price,omitempty"`
+	LowPrice  *decimal.Decimal `json:"low_price,omitempty"`
+	Time      string           `json:"time"`
+	Language  string           `json:"language"`
+	Tariff    string           `json:"tariff"`
+	Alerts    []string         `json:"alerts"`
+	// Timezone of the time and the quiet hours, the config one when empty.
+	Timezone string `json:"timezone,omitempty"`
+	// Quiet hours as "22:00-07:00", the messages are held for the digest meanwhile.
+	Quiet string `json:"quiet,omitempty"`
+	// LastSent is the last day sent to the chat.
+	LastSent string `json:"last_sent,omitempty"`
+}
+
+// Subscriptions keeps the subscriptions by chat ID.
+type Subscriptions struct {
+	storage Storage
+}
+
+func NewSubscriptions(storage Storage) *Subscriptions {
+	return &Subscriptions{storage: storage}
+}
+
+func (subscriptions *Subscriptions) Load(chatID int64) (subscription Subscription, ok bool, err error) {
+	ok, err = subscriptions.storage.Load(subscriptionsCollection, strconv.FormatInt(chatID, 10), &subscription)
+	return
+}
+
+func (subscriptions *Subscriptions) Save(subscription Subscription) error {
+	return subscriptions.storage.Save(subscriptionsCollection, strconv.FormatInt(subscription.ChatID, 10), subscription)
+}
+
+func (subscriptions *Subscriptions) Delete(chatID int64) error {
+	return subscriptions.storage.Delete(subscriptionsCollection, strconv.FormatInt(chatID, 10))
+}
+
+func (subscriptions *Subscriptions) All() (res []Subscription, err error) {
+	keys, err := subscriptions.storage.Keys(subscriptionsCollection)
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		var subscription Subscription
+		if _, err = subscriptions.storage.Load(subscriptionsCollection, key, &subscription); err != nil {
+			return
+		}
+		res = append(res, subscription)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ChatID < res[j].ChatID })
+	return
+}
+
+// NewSubscription returns the default subscription: all alerts when tomorrow's prices are published.
+func (cfg *ConfigApp) NewSubscription(chatID int64) Subscription {
+	return Subscription{
+		ChatID:   chatID,
+		Time:     fmt.Sprintf("%02d:00", cfg.TomorrowHourMin()),
+		Language: languages[0],
+		Tariff:   TariffProfileEPEX,
+		Alerts:   append([]string{}, subscriptionAlerts...),
+	}
+}
+
+// Set changes the setting by its name, as in "/set high 0.25".
+func (subscription *Subscription) Set(name, value string) error {
+	value = strings.TrimSpace(value)
+	if name == "timezone" {
+		if strings.EqualFold(value, "default") {
+			value = ""
+		} else if _, err := time.LoadLocation(value); err != nil || value == "" || value == "Local" {
+			return fmt.Errorf("unknown timezone %q, expected e.g. Europe/Amsterdam", value)
+		}
+		subscription.Timezone = value
+		return nil
+	}
+	value = strings.ToLower(value)
+	switch name {
+	case "high", "low":
+		var price *decimal.Decimal
+		if value != "" && value != "default" {
+			d, err := decimal.NewFromString(value)
+			if err != nil {
+				return fmt.Errorf("invalid price %q", value)
+			}
+			price = &d
+		}
+		if name == "high" {
+			subscription.HighPrice = price
+		} else {
+			subscription.LowPrice = price
+		}
+	case "time":
+		hour, minute, err := parseClock(value)
+		if err != nil {
+			return err
+		}
+		// "9:00" is stored as "09:00" like the clock it's compared with.
+		subscription.Time = fmt.Sprintf("%02d:%02d", hour, minute)
+	case "quiet":
+		if value == "off" || value == "" {
+			subscription.Quiet = ""
+			break
+		}
+		if _, _, err := parseQuietHours(value); err != nil {
+			return err
+		}
+		subscription.Quiet = value
+	case "language":
+		if !slices.Contains(languages, value) {
+			return fmt.Errorf("unknown language %q, expected one of %s", value, strings.Join(languages, ", "))
+		}
+		subscription.Language = value
+	case "tariff":
+		if !slices.Contains(subscriptionTariffs, value) {
+			return fmt.Errorf("unknown tariff %q, expected one of %s", value, strings.Join(subscriptionTariffs, ", "))
+		}
+		subscription.Tariff = value
+	case "alerts":
+		alerts := []string{}
+		for _, alert := range strings.Split(value, ",") {
+			alert = strings.TrimSpace(alert)
+			if alert == "" || alert == "none" || slices.Contains(alerts, alert) {
+				continue
+			}
+			if !slices.Contains(subscriptionAlerts, alert) {
+				return fmt.Errorf("unknown alert %q, expected %s", alert, strings.Join(subscriptionAlerts, ", "))
+			}
+			alerts = append(alerts, alert)
+		}
+		subscription.Alerts = alerts
+	default:
+		return ErrUnknownSetting
+	}
+	return nil
+}
+
+// Analytics returns the analytics config with the subscription thresholds,
+// the configured tiers give way to low/normal/high when a threshold is set.
+func (subscription *Subscription) Analytics(cfg *ConfigAnalytics) ConfigAnalytics {
+	analytics := *cfg
+	if subscription.HighPrice != nil {
+		analytics.HighPrice, analytics.Tiers = *subscription.HighPrice, nil
+	}
+	if subscription.LowPrice != nil {
+		analytics.LowPrice, analytics.Tiers = *subscription.LowPrice, nil
+	}
+	return analytics
+}
+
+// Prices converts the day-ahead prices to the subscription tariff.
+func (subscription *Subscription) Prices(cfg *ConfigTariff, prices []decimal.Decimal) []decimal.Decimal {
+	if subscription.Tariff != TariffProfileDynamic {
+		return prices
+	}
+	res := make([]decimal.Decimal, len(prices))
+	for i, price := range prices {
+		res[i] = price.Add(cfg.DynamicMarkup).Add(cfg.EnergyTax)
+	}
+	return res
+}
+
+// Location returns the timezone of the subscriber.
+func (subscription *Subscription) Location(cfg *ConfigApp) *time.Location {
+	if subscription.Timezone != "" {
+		if location, err := time.LoadLocation(subscription.Timezone); err == nil {
+			return location
+		}
+	}
+	return cfg.Location()
+}
+
+// Day returns the day to send at the subscription time: tomorrow once its prices are published, otherwise today.
+func (subscription *Subscription) Day(cfg *ConfigApp, now time.Time) time.Time {
+	at := now.In(subscription.Location(cfg))
+	if hour, minute, err := parseClock(subscription.Time); err == nil {
+		at = time.Date(at.Year(), at.Month(), at.Day(), hour, minute, 0, 0, at.Location())
+	}
+	return publishedDay(cfg, at)
+}
+
+// Due returns the day to send and tells if it's time: from the subscription time until the next day
+// is published, so a missed minute is retried but never sends the previous day after it.
+func (subscription *Subscription) Due(cfg *ConfigApp, now time.Time) (day time.Time, due bool) {
+	day = subscription.Day(cfg, now)
+	local := now.In(subscription.Location(cfg))
+	if hour, minute, err := parseClock(subscription.Time); err != nil ||
+		local.Before(time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, local.Location())) {
+		return day, false
+	}
+	return day, subscription.LastSent != day.Format("2006-01-02") && publishedDay(cfg, now).Equal(day)
+}
+
+// publishedDay returns the latest day with the prices published at the time.
+func publishedDay(cfg *ConfigApp, at time.Time) time.Time {
+	at = at.In(cfg.Location())
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, cfg.Location())
+	if at.Hour() >= cfg.TomorrowHourMin() {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// QuietAt tells if the time is in the quiet hours of the subscriber.
+func (subscription *Subscription) QuietAt(cfg *ConfigApp, now time.Time) bool {
+	from, till, err := parseQuietHours(subscription.Quiet)
+	if subscription.Quiet == "" || err != nil {
+		return false
+	}
+	local := now.In(subscription.Location(cfg))
+	minute := local.Hour()*60 + local.Minute()
+	if from <= till {
+		return minute >= from && minute < till
+	}
+	return minute >= from || minute < till
+}
+
+// parseQuietHours returns the minutes of the day the quiet hours "22:00-07:00" start and end at.
+func parseQuietHours(value string) (from, till int, err error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", value)
+	}
+	for _, clock := range []struct {
+		value   string
+		minutes *int
+	}{{start, &from}, {end, &till}} {
+		hour, minute, errClock := parseClock(strings.TrimSpace(clock.value))
+		if errClock != nil {
+			return 0, 0, errClock
+		}
+		*clock.minutes = hour*60 + minute
+	}
+	return
+}
+
+// SubscriptionText describes the settings.
+func SubscriptionText(cfg *ConfigAnalytics, subscription *Subscription) string {
+	analytics := subscription.Analytics(cfg)
+	alerts := strings.Join(subscription.Alerts, ", ")
+	if alerts == "" {
+		alerts = "none"
+	}
+	timezone := subscription.Timezone
+	if timezone == "" {
+		timezone = timeLocation
+	}
+	quiet := subscription.Quiet
+	if quiet == "" {
+		quiet = "off"
+	}
+	lines := []string{
+		fmt.Sprintf("High: %s", analytics.HighPrice.StringFixed(3)),
+		fmt.Sprintf("Low: %s", analytics.LowPrice.StringFixed(3)),
+		fmt.Sprintf("Time: %s", subscription.Time),
+		fmt.Sprintf("Timezone: %s", timezone),
+		fmt.Sprintf("Quiet hours: %s", quiet),
+		fmt.Sprintf("Language: %s", subscription.Language),
+		fmt.Sprintf("Tariff: %s", subscription.Tariff),
+		fmt.Sprintf("Alerts: %s", alerts),
+		"",
+		"Change with /set high 0.25, /set low 0.05, /set time 16:00, /set timezone Europe/Kyiv, " +
+			"/set quiet 22:00-07:00 (or off), /set language nl, /set tariff dynamic or /set alerts day,high,low,negative",
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SubscriptionMessage builds the personalised day message,
+// ok is false when the subscriber doesn't want the day prices and no chosen alert is raised.
+func SubscriptionMessage(cfg *ConfigApp, subscription *Subscription, prices []decimal.Decimal, day time.Time) (message Message, ok bool, err error) {
+	analytics := subscription.Analytics(&cfg.Analytics)
+	prices = subscription.Prices(&cfg.Tariff, prices)
+
+	var alerts []string
+	for _, alert := range []struct {
+		name   string
+		raised func(price decimal.Decimal) bool
+	}{
+		{AlertHigh, func(price decimal.Decimal) bool { return price.GreaterThanOrEqual(analytics.HighPrice) }},
+		{AlertLow, func(price decimal.Decimal) bool { return price.LessThanOrEqual(analytics.LowPrice) }},
+		{AlertNegative, decimal.Decimal.IsNegative},
+	} {
+		if slices.Contains(subscription.Alerts, alert.name) && slices.ContainsFunc(prices, alert.raised) {
+			alerts = append(alerts, alert.name)
+		}
+	}
+
+	templates := cfg.MessageTemplates(subscription.Language)
+	if !slices.Contains(subscription.Alerts, AlertDay) {
+		if len(alerts) == 0 {
+			return
+		}
+		text, err := templates.Render("alerts", TemplateData{Day: day, Alerts: alerts})
+		if err != nil {
+			return message, false, err
+		}
+		message.Class = MessageClassPrices
+		message.Document = TextDocument(text)
+		message.Day = &DayReport{Day: day, Alerts: alerts, Prices: prices}
+		return message, true, nil
+	}
+
+	if message, err = buildDayMessage(&analytics, templates, prices, day, alerts); err != nil {
+		return
+	}
+	if message.Keyboard, err = DayKeyboard(&cfg.Messenger, templates, day); err != nil {
+		return
+	}
+	return message, true, nil
+}
+
+// NotifySubscribers sends the personalised day message to the subscribers whose time has come,
+// the failed ones are retried the next minutes, and the digests of the ones whose quiet hours are over.
+func NotifySubscribers(cfg *ConfigApp, now time.Time) {
+	subscriptions, err := cfg.Subscriptions().All()
+	if err != nil {
+		log.Printf("Error loading subscriptions: %v\n", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		day, due := subscription.Due(cfg, now)
+		if !due {
+			continue
+		}
+		if err := notifySubscriber(cfg, &subscription, day, now); err != nil {
+			log.Printf("Error notifying subscriber %d: %v\n", subscription.ChatID, err)
+		}
+	}
+	SendDigests(cfg, subscriptions, now)
+}
+
+func notifySubscriber(cfg *ConfigApp, subscription *Subscription, day, now time.Time) error {
+	prices, err := LoadPrices(cfg, day)
+	if err != nil {
+		return err
+	}
+	message, ok, err := SubscriptionMessage(cfg, subscription, prices, day)
+	if err != nil {
+		return err
+	}
+	if ok {
+		key := fmt.Sprintf("subscription-%d-%s", subscription.ChatID, day.Format("2006-01-02"))
+		if err = DeliverSubscriber(cfg, subscription, key, message, now); err != nil {
+			return err
+		}
+	}
+	subscription.LastSent = day.Format("2006-01-02")
+	return cfg.Subscriptions().Save(*subscription)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscription_Set(t *testing.T) {
+	cfg := generateTestConfig()
+	subscription := cfg.NewSubscription(42)
+	assert.Equal(t, "15:00", subscription.Time)
+	assert.Equal(t, []string{"day", "high", "low", "negative"}, subscription.Alerts)
+
+	require.NoError(t, subscription.Set("high", "0.25"))
+	assert.Equal(t, "0.25", subscription.HighPrice.String())
+	require.NoError(t, subscription.Set("high", "default"))
+	assert.Nil(t, subscription.HighPrice)
+	require.NoError(t, subscription.Set("time", "07:30"))
+	assert.Equal(t, "07:30", subscription.Time)
+	require.NoError(t, subscription.Set("time", "9:00"))
+	assert.Equal(t, "09:00", subscription.Time)
+	require.NoError(t, subscription.Set("language", "NL"))
+	assert.Equal(t, "nl", subscription.Language)
+	require.NoError(t, subscription.Set("alerts", "negative, high,high"))
+	assert.Equal(t, []string{"negative", "high"}, subscription.Alerts)
+	require.NoError(t, subscription.Set("alerts", "none"))
+	assert.Empty(t, subscription.Alerts)
+
+	assert.Error(t, subscription.Set("low", "cheap"))
+	assert.Error(t, subscription.Set("time", "25:00"))
+	assert.Error(t, subscription.Set("language", "fr"))
+	assert.Error(t, subscription.Set("tariff", "fixed"))
+	assert.Error(t, subscription.Set("alerts", "day,storm"))
+	assert.Equal(t, ErrUnknownSetting, subscription.Set("colour", "red"))
+}
+
+func TestSubscription_Day(t *testing.T) {
+	cfg := generateTestConfig()
+	subscription := cfg.NewSubscription(42)
+	now := time.Date(2025, 2, 28, 7, 30, 0, 0, cfg.Location())
+
+	assert.Equal(t, "2025-03-01", subscription.Day(cfg, now).Format("2006-01-02"))
+	subscription.Time = "07:30"
+	assert.Equal(t, "2025-02-28", subscription.Day(cfg, now).Format("2006-01-02"))
+}
+
+func TestSubscriptionMessage(t *testing.T) {
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	subscription := cfg.NewSubscription(42)
+
+	message, ok, err := SubscriptionMessage(cfg, &subscription, prices, day)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Contains(t, message.Document.Format(FormatMarkdownV2), "*\n*There are Low/Negative prices*\n")
+	assert.Len(t, message.Attachments, 1)
+
+	// The dynamic tariff adds 0.12, so the personal high threshold is reached.
+	high := decimal.NewFromFloat(0.25)
+	subscription.HighPrice = &high
+	subscription.Tariff = TariffProfileDynamic
+	subscription.Alerts = []string{AlertHigh}
+	message, ok, err = SubscriptionMessage(cfg, &subscription, prices, day)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2025\\-02\\-28: there are High prices", message.Document.Format(FormatMarkdownV2))
+	assert.Empty(t, message.Attachments)
+
+	subscription.Language = "nl"
+	message, ok, err = SubscriptionMessage(cfg, &subscription, prices, day)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "28\\-02\\-2025: er zijn hoge prijzen", message.Document.Format(FormatMarkdownV2))
+
+	subscription.Tariff = TariffProfileEPEX
+	_, ok, err = SubscriptionMessage(cfg, &subscription, prices, day)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The stats of the day message are formatted for the language too.
+	subscription.Alerts = []string{AlertDay}
+	message, ok, err = SubscriptionMessage(cfg, &subscription, prices, day)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Contains(t, message.Document.Format(FormatPlain), "\nLaagste: -0,020 om 14:00\n")
+}
+
+func TestNotifySubscribers(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	early := cfg.NewSubscription(1)
+	early.Time = "07:00"
+	require.NoError(t, cfg.Subscriptions().Save(early))
+	require.NoError(t, cfg.Subscriptions().Save(cfg.NewSubscription(2)))
+
+	now := time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location())
+	NotifySubscribers(cfg, now)
+	// Sent once a day.
+	NotifySubscribers(cfg, now)
+
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "2", requests[0].Values["chat_id"])
+	assert.Contains(t, requests[0].Values["caption"], "2025\\-03\\-01")
+
+	subscription, ok, err := cfg.Subscriptions().Load(2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2025-03-01", subscription.LastSent)
+}
+
+func TestNotifySubscribers_SingleDigitTime(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	subscription := cfg.NewSubscription(1)
+	require.NoError(t, subscription.Set("time", "9:00"))
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+
+	NotifySubscribers(cfg, time.Date(2025, 2, 28, 9, 0, 0, 0, cfg.Location()))
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Contains(t, requests[0].Values["caption"], "2025\\-02\\-28")
+}
+
+func TestNotifySubscribers_Retry(t *testing.T) {
+	telegram := newFakeTelegram()
+	defer telegram.Close()
+	cfg := generateBotTestConfig()
+	cfg.Messenger.Telegram.APIEndpoint = telegram.Endpoint()
+
+	subscription := cfg.NewSubscription(1)
+	subscription.Time = "09:00"
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+
+	// Not yet, then the missed minute is caught up.
+	NotifySubscribers(cfg, time.Date(2025, 2, 28, 8, 59, 0, 0, cfg.Location()))
+	assert.Empty(t, telegram.Requests())
+	NotifySubscribers(cfg, time.Date(2025, 2, 28, 9, 7, 0, 0, cfg.Location()))
+	NotifySubscribers(cfg, time.Date(2025, 2, 28, 9, 8, 0, 0, cfg.Location()))
+	requests := telegram.Requests()
+	require.Len(t, requests, 1)
+	assert.Contains(t, requests[0].Values["caption"], "2025\\-02\\-28")
+
+	// Once tomorrow is published the missed day is skipped rather than sent late.
+	subscription.LastSent = ""
+	require.NoError(t, cfg.Subscriptions().Save(subscription))
+	NotifySubscribers(cfg, time.Date(2025, 2, 28, 15, 0, 0, 0, cfg.Location()))
+	NotifySubscribers(cfg, time.Date(2025, 3, 1, 8, 0, 0, 0, cfg.Location()))
+	assert.Len(t, telegram.Requests(), 1)
+	NotifySubscribers(cfg, time.Date(2025, 3, 1, 9, 30, 0, 0, cfg.Location()))
+	requests = telegram.Requests()
+	require.Len(t, requests, 2)
+	assert.Contains(t, requests[1].Values["caption"], "2025\\-03\\-01")
+}
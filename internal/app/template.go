@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// Lengths in hours of the cheapest windows given to the templates.
+var templateWindowHours = []int{1, 2, 3, 4}
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// TemplateData is given to the message templates, the day fields are set for the day messages.
+type TemplateData struct {
+	Day     time.Time
+	Prices  []decimal.Decimal
+	Series  models.DayPrices
+	Stats   models.DayStats
+	Windows []models.CheapWindow
+	// Window is the cheapest window of the keyboard button, only its hours are set on the button label.
+	Window models.CheapWindow
+	// Alerts are the names of the raised alerts: high, low, negative.
+	Alerts []string
+	// Changes are the first revised slots of the day, More is the number of the rest.
+	Changes []PriceChange
+	More    int
+	// Source failed Failures times in a row since Since, Error is the last error.
+	Source   string
+	Failures int
+	Since    time.Time
+	Error    string
+	// Held is the number of the messages in the quiet hours digest.
+	Held int
+}
+
+// MessageTemplates renders the message texts in one language,
+// the built-in templates are overridden by the ones defined in <path>/<language>.tmpl.
+type MessageTemplates struct {
+	path   string
+	locale *Locale
+
+	once   sync.Once
+	parsed *template.Template
+	err    error
+}
+
+func NewMessageTemplates(cfg *ConfigTemplates, language string) *MessageTemplates {
+	if language == "" {
+		language = cfg.Language
+	}
+	return &MessageTemplates{path: cfg.Path, locale: LocaleFor(language)}
+}
+
+// MessageTemplates returns the templates of the language, the configured one when empty.
+// They are parsed once per language, the edited files are used after a restart.
+func (cfg *ConfigApp) MessageTemplates(language string) *MessageTemplates {
+	templates := NewMessageTemplates(&cfg.Templates, language)
+	cfg.templatesMu.Lock()
+	defer cfg.templatesMu.Unlock()
+	if cached, ok := cfg.templates[templates.locale.Language]; ok {
+		return cached
+	}
+	if cfg.templates == nil {
+		cfg.templates = map[string]*MessageTemplates{}
+	}
+	cfg.templates[templates.locale.Language] = templates
+	return templates
+}
+
+func (templates *MessageTemplates) Locale() *Locale {
+	return templates.locale
+}
+
+// parse loads the templates on the first call, the next ones return the same result.
+func (templates *MessageTemplates) parse() (*template.Template, error) {
+	templates.once.Do(func() {
+		templates.parsed, templates.err = templates.load()
+	})
+	return templates.parsed, templates.err
+}
+
+func (templates *MessageTemplates) load() (*template.Template, error) {
+	locale := templates.locale
+	res := template.New(locale.Language).Funcs(template.FuncMap{
+		"price":   locale.Price,
+		"number":  locale.Number,
+		"date":    locale.Date,
+		"weekday": locale.Weekday,
+		"hour":    locale.Hour,
+		"clock":   func(t time.Time) string { return t.Format("15:04") },
+		"word":    locale.Word,
+		"join":    strings.Join,
+	})
+	name := locale.Language + ".tmpl"
+	builtin, err := builtinTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if res, err = res.Parse(string(builtin)); err != nil {
+		return nil, fmt.Errorf("invalid built-in template %s: %w", name, err)
+	}
+	if templates.path == "" {
+		return res, nil
+	}
+	custom, err := os.ReadFile(filepath.Join(templates.path, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if res, err = res.Parse(string(custom)); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	return res, nil
+}
+
+// Render executes the named template, the result is plain text without the surrounding spaces.
+func (templates *MessageTemplates) Render(name string, data TemplateData) (string, error) {
+	parsed, err := templates.parse()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = parsed.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// dayTemplateData returns the day data with the cheapest windows.
+func dayTemplateData(cfg *ConfigAnalytics, prices []decimal.Decimal, day time.Time, alerts []string) TemplateData {
+	return TemplateData{
+		Day:     day,
+		Prices:  prices,
+		Series:  DayPricesPayload(cfg, day, prices, nil, nil),
+		Stats:   dayStats(prices),
+		Windows: cheapWindows(prices, day, templateWindowHours),
+		Alerts:  alerts,
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	data := TemplateData{Day: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), Alerts: []string{AlertHigh, AlertNegative}}
+
+	for language, expected := range map[string][2]string{
+		"en": {"EPEX NL Day-Ahead 2025-02-28", "There are High/Negative prices"},
+		"nl": {"EPEX NL day-ahead prijzen vrijdag 28-02-2025", "Er zijn hoge/negatieve prijzen"},
+		"uk": {"EPEX NL ціни на добу наперед, пʼятниця 28.02.2025", "Є високі/відʼємні ціни"},
+	} {
+		templates := NewMessageTemplates(&ConfigTemplates{Language: "en"}, language)
+		title, err := templates.Render("day.title", data)
+		require.NoError(t, err)
+		assert.Equal(t, expected[0], title, language)
+		alert, err := templates.Render("day.alert", data)
+		require.NoError(t, err)
+		assert.Equal(t, expected[1], alert, language)
+	}
+
+	alert, err := NewMessageTemplates(&ConfigTemplates{}, "en").Render("day.alert", TemplateData{})
+	require.NoError(t, err)
+	assert.Equal(t, "", alert)
+}
+
+func TestMessageTemplates_Override(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "en.tmpl"), []byte(
+		`{{define "day.summary"}}{{range .Windows}}{{if eq .Hours 3}}Cheapest 3h from {{clock .Start}}, {{price .Average}}{{end}}{{end}}{{end}}`,
+	), 0o644))
+	cfg := generateTestConfig()
+	cfg.Templates.Path = path
+	prices, _ := generateStub()
+
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	// The built-in templates are kept when not overridden.
+	assert.Contains(t, message.Document.Format(FormatMarkdownV2), "*EPEX NL Day\\-Ahead 2025\\-02\\-28*\n*There are Low prices*\nCheapest 3h from 12:00, 0\\.007\n")
+
+	// Other languages have no file, so they are built-in only.
+	_, err = cfg.MessageTemplates("nl").Render("day.summary", TemplateData{})
+	require.NoError(t, err)
+
+	// The templates are parsed once, the edited file is used after a restart.
+	assert.Same(t, cfg.MessageTemplates(""), cfg.MessageTemplates("en"))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "en.tmpl"), []byte(`{{define "day.title"}}{{.Missing}}{{end}}`), 0o644))
+	_, err = BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+	cfg = generateTestConfig()
+	cfg.Templates.Path = path
+	_, err = BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, "en.tmpl"), []byte(`{{define "day.title"}}`), 0o644))
+	cfg = generateTestConfig()
+	cfg.Templates.Path = path
+	assert.Error(t, cfg.SelfCheck())
+}
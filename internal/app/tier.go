@@ -0,0 +1,156 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// PriceTiers are ordered from cheap to expensive around the only tier without a boundary (normal):
+// a cheaper tier takes prices equal or lower than its boundary,
+// an expensive tier takes prices equal or higher than its boundary.
+// They are decoded from "name|boundary|color|emoji|label;..." (ANALYTICS_TIERS).
+type PriceTiers []models.PriceTier
+
+func (tiers *PriceTiers) Decode(value string) error {
+	var res PriceTiers
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		fields := strings.Split(item, "|")
+		if len(fields) != 5 {
+			return fmt.Errorf("invalid tier %q, expected name|boundary|color|emoji|label", item)
+		}
+		tier := models.PriceTier{
+			Name:  strings.TrimSpace(fields[0]),
+			Color: strings.TrimSpace(fields[2]),
+			Emoji: strings.TrimSpace(fields[3]),
+			Label: strings.TrimSpace(fields[4]),
+		}
+		if boundary := strings.TrimSpace(fields[1]); boundary != "" {
+			d, err := decimal.NewFromString(boundary)
+			if err != nil {
+				return fmt.Errorf("invalid tier %s boundary: %w", tier.Name, err)
+			}
+			tier.Boundary = &d
+		}
+		res = append(res, tier)
+	}
+	if err := res.validate(); err != nil {
+		return err
+	}
+	*tiers = res
+	return nil
+}
+
+func (tiers PriceTiers) validate() error {
+	normal := -1
+	var last *decimal.Decimal
+	for i, tier := range tiers {
+		if tier.Name == "" {
+			return errors.New("tier name not set")
+		}
+		// The colour goes into the SVG and HTML charts, only the ones the PNG chart draws are allowed.
+		if _, ok := chartColor(tier.Color); tier.Color != "" && !ok {
+			return fmt.Errorf("invalid tier %s color %q, expected a name or #rrggbb", tier.Name, tier.Color)
+		}
+		if tier.Boundary == nil {
+			if normal >= 0 {
+				return errors.New("only one tier can be without a boundary")
+			}
+			normal = i
+		} else {
+			if last != nil && !tier.Boundary.GreaterThan(*last) {
+				return fmt.Errorf("tier %s boundary must be higher than the previous one", tier.Name)
+			}
+			last = tier.Boundary
+		}
+	}
+	if len(tiers) > 0 && normal < 0 {
+		return errors.New("one tier must be without a boundary")
+	}
+	return nil
+}
+
+// normal returns the index of the tier without a boundary.
+func (tiers PriceTiers) normal() int {
+	for i, tier := range tiers {
+		if tier.Boundary == nil {
+			return i
+		}
+	}
+	return 0
+}
+
+// PriceTiers returns the configured tiers or the low/normal/high ones from the low and high prices.
+func (cfg *ConfigAnalytics) PriceTiers() PriceTiers {
+	if len(cfg.Tiers) > 0 {
+		return cfg.Tiers
+	}
+	low, high := cfg.LowPrice, cfg.HighPrice
+	return PriceTiers{
+		{Name: "low", Boundary: &low, Color: "green", Emoji: "🟢", Label: "Low"},
+		{Name: "normal", Label: "Normal"},
+		{Name: "high", Boundary: &high, Color: "red", Emoji: "🔴", Label: "High"},
+	}
+}
+
+// Tier classifies the price.
+func (cfg *ConfigAnalytics) Tier(price decimal.Decimal) models.PriceTier {
+	tiers := cfg.PriceTiers()
+	normal := tiers.normal()
+	for i := 0; i < normal; i++ {
+		if price.LessThanOrEqual(*tiers[i].Boundary) {
+			return tiers[i]
+		}
+	}
+	for i := len(tiers) - 1; i > normal; i-- {
+		if price.GreaterThanOrEqual(*tiers[i].Boundary) {
+			return tiers[i]
+		}
+	}
+	return tiers[normal]
+}
+
+// TierLegend describes every tier with the decimal separator of the locale, e.g. "🟢 Low ≤ 0.05".
+func (cfg *ConfigAnalytics) TierLegend(locale *Locale) []string {
+	tiers := cfg.PriceTiers()
+	normal := tiers.normal()
+	legend := make([]string, len(tiers))
+	for i, tier := range tiers {
+		item := strings.TrimSpace(tier.Emoji + " " + tier.Label)
+		if i < normal {
+			item += " ≤ " + locale.Number(*tier.Boundary, 2)
+		} else if i > normal {
+			item += " ≥ " + locale.Number(*tier.Boundary, 2)
+		}
+		legend[i] = item
+	}
+	return legend
+}
+
+// DayPricesPayload classifies the prices for the API, lower and upper are set for the forecast only.
+func DayPricesPayload(cfg *ConfigAnalytics, day time.Time, prices, lower, upper []decimal.Decimal) models.DayPrices {
+	payload := models.DayPrices{
+		Date:     day.Format("2006-01-02"),
+		Estimate: lower != nil,
+		Slots:    make([]models.PriceSlot, len(prices)),
+		Tiers:    cfg.PriceTiers(),
+	}
+	for i, price := range prices {
+		payload.Slots[i] = models.PriceSlot{
+			Start: day.Add(time.Duration(i) * time.Hour),
+			Price: price,
+			Tier:  cfg.Tier(price).Name,
+		}
+		if i < len(lower) && i < len(upper) {
+			payload.Slots[i].Lower, payload.Slots[i].Upper = &lower[i], &upper[i]
+		}
+	}
+	return payload
+}
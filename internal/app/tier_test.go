@@ -0,0 +1,71 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTiers = "very-cheap|0|darkgreen|🟢|Very cheap;cheap|0.10|green|🟩|Cheap;normal||||Normal;" +
+	"expensive|0.25|orange|🟧|Expensive;very-expensive|0.35|red|🟥|Very expensive"
+
+func TestPriceTiersDecode(t *testing.T) {
+	var tiers PriceTiers
+	require.NoError(t, tiers.Decode(testTiers))
+	require.Len(t, tiers, 5)
+	assert.Equal(t, "cheap", tiers[1].Name)
+	assert.Equal(t, "0.1", tiers[1].Boundary.String())
+	assert.Nil(t, tiers[2].Boundary)
+	assert.Equal(t, "🟥", tiers[4].Emoji)
+
+	assert.Error(t, tiers.Decode("cheap|0.10|green|🟩"))
+	assert.Error(t, tiers.Decode("cheap|cheap|green|🟩|Cheap;normal||||Normal"))
+	assert.Error(t, tiers.Decode("cheap|0.10|green|🟩|Cheap;expensive|0.20|red|🟥|Expensive"))
+	assert.Error(t, tiers.Decode("cheap|0.10|green|🟩|Cheap;normal||||Normal;other||||Other"))
+	assert.Error(t, tiers.Decode("cheap|0.10|green|🟩|Cheap;normal||||Normal;expensive|0.05|red|🟥|Expensive"))
+	assert.Error(t, tiers.Decode(`cheap|0.10|red"/><script>|🟩|Cheap;normal||||Normal`))
+	assert.Error(t, tiers.Decode("cheap|0.10|5470c6|🟩|Cheap;normal||||Normal"))
+	assert.NoError(t, tiers.Decode("cheap|0.10|#5470C6|🟩|Cheap;normal||||Normal"))
+}
+
+func TestTier(t *testing.T) {
+	cfg := generateTestConfig()
+
+	// The default tiers come from the low and high prices.
+	assert.Equal(t, "low", cfg.Analytics.Tier(decimal.NewFromFloat(0.1)).Name)
+	assert.Equal(t, "normal", cfg.Analytics.Tier(decimal.NewFromFloat(0.15)).Name)
+	assert.Equal(t, "high", cfg.Analytics.Tier(decimal.NewFromFloat(0.2)).Name)
+	assert.Equal(t, []string{"🟢 Low ≤ 0.10", "Normal", "🔴 High ≥ 0.20"}, cfg.Analytics.TierLegend(LocaleFor("en")))
+	assert.Equal(t, []string{"🟢 Low ≤ 0,10", "Normal", "🔴 High ≥ 0,20"}, cfg.Analytics.TierLegend(LocaleFor("uk")))
+
+	require.NoError(t, cfg.Analytics.Tiers.Decode(testTiers))
+	assert.Equal(t, "very-cheap", cfg.Analytics.Tier(decimal.NewFromFloat(-0.01)).Name)
+	assert.Equal(t, "cheap", cfg.Analytics.Tier(decimal.NewFromFloat(0.1)).Name)
+	assert.Equal(t, "normal", cfg.Analytics.Tier(decimal.NewFromFloat(0.24)).Name)
+	assert.Equal(t, "expensive", cfg.Analytics.Tier(decimal.NewFromFloat(0.25)).Name)
+	assert.Equal(t, "very-expensive", cfg.Analytics.Tier(decimal.NewFromFloat(0.5)).Name)
+	assert.Equal(t, "🟧 Expensive ≥ 0.25", cfg.Analytics.TierLegend(LocaleFor("en"))[3])
+}
+
+func TestDayPricesPayload(t *testing.T) {
+	cfg := generateTestConfig()
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	prices := []decimal.Decimal{decimal.NewFromFloat(0.05), decimal.NewFromFloat(0.3)}
+
+	payload := DayPricesPayload(&cfg.Analytics, day, prices, nil, nil)
+	assert.Equal(t, "2025-02-28", payload.Date)
+	assert.False(t, payload.Estimate)
+	require.Len(t, payload.Slots, 2)
+	assert.Equal(t, day.Add(time.Hour), payload.Slots[1].Start)
+	assert.Equal(t, "low", payload.Slots[0].Tier)
+	assert.Equal(t, "high", payload.Slots[1].Tier)
+	assert.Nil(t, payload.Slots[0].Lower)
+	assert.Len(t, payload.Tiers, 3)
+
+	payload = DayPricesPayload(&cfg.Analytics, day, prices, prices, prices)
+	assert.True(t, payload.Estimate)
+	assert.NotNil(t, payload.Slots[1].Upper)
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+)
+
+const webhookHttpTimeout = 10 * time.Second
+
+// The first retry waits webhookBackoff, every next one twice as long.
+const webhookBackoff = time.Second
+
+// The zone of the prices in the payload.
+const priceZone = "NL"
+
+const (
+	webhookHeaderSignature = "X-Signature"
+	webhookHeaderTimestamp = "X-Signature-Timestamp"
+	webhookHeaderDelivery  = "X-Delivery-ID"
+	cloudEventsSource      = "/day-ahead-prices-notificator"
+	cloudEventsTypePrefix  = "day-ahead-prices."
+)
+
+var ErrWebhookRejected = errors.New("webhook rejected the request")
+
+// webhookMessenger posts the JSON payload to the URLs. The signature is "sha256=" with the hex HMAC-SHA256
+// of "<timestamp>.<body>", the receivers should reject old timestamps against replays.
+type webhookMessenger struct {
+	cfg     *ConfigWebhook
+	now     func() time.Time
+	backoff time.Duration
+}
+
+func (messenger *webhookMessenger) Send(message Message) error {
+	delivery := webhookDeliveryID()
+	body, err := messenger.body(message, delivery)
+	if err != nil {
+		return err
+	}
+	contentType := "application/json"
+	if messenger.cfg.CloudEvents {
+		contentType = "application/cloudevents+json"
+	}
+
+	var errs []error
+	for _, url := range messenger.cfg.URLs {
+		if err := messenger.post(url, contentType, delivery, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (messenger *webhookMessenger) body(message Message, delivery string) ([]byte, error) {
+	payload := WebhookPayload(message)
+	if !messenger.cfg.CloudEvents {
+		return json.Marshal(payload)
+	}
+	return json.Marshal(models.CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              delivery,
+		Source:          cloudEventsSource,
+		Type:            cloudEventsTypePrefix + payload.Class,
+		Time:            messenger.now().UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data:            payload,
+	})
+}
+
+// post retries the network errors, 429 and 5xx with the exponential backoff.
+func (messenger *webhookMessenger) post(url, contentType, delivery string, body []byte) (err error) {
+	backoff := messenger.backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = messenger.postOnce(url, contentType, delivery, body); err == nil || !retry {
+			return
+		}
+		if attempt >= messenger.cfg.Retries {
+			return
+		}
+		log.Printf("Error posting webhook %s, retrying in %s: %v\n", url, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (messenger *webhookMessenger) postOnce(url, contentType, delivery string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookHttpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(webhookHeaderDelivery, delivery)
+	if messenger.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(messenger.now().Unix(), 10)
+		req.Header.Set(webhookHeaderTimestamp, timestamp)
+		req.Header.Set(webhookHeaderSignature, webhookSignature(messenger.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%w: status %s", ErrWebhookRejected, resp.Status)
+}
+
+// WebhookPayload converts the message, the day prices are structured, other messages go as plain text.
+func WebhookPayload(message Message) models.WebhookPayload {
+	payload := models.WebhookPayload{
+		Version: models.WebhookPayloadVersion,
+		Class:   message.Class,
+		Zone:    priceZone,
+	}
+	if day := message.Day; day != nil {
+		stats := day.Stats
+		payload.Date = day.Series.Date
+		payload.Series = day.Series.Slots
+		payload.Stats = &stats
+		payload.Tiers = day.Series.Tiers
+		payload.Alerts = day.Alerts
+	} else {
+		payload.Text = FormatPlain.Format(message.Document)
+	}
+	return payload
+}
+
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks the signature and that the timestamp is within the tolerance, as a receiver should.
+func verifyWebhookSignature(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return errors.New("timestamp out of tolerance")
+	}
+	if !hmac.Equal([]byte(signature), []byte(webhookSignature(secret, timestamp, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func webhookDeliveryID() string {
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	return hex.EncodeToString(random)
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWebhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// generateFakeWebhook answers with the statuses in turn, the last one repeats.
+func generateFakeWebhook(statuses ...int) (*httptest.Server, func() []fakeWebhookRequest) {
+	var mu sync.Mutex
+	var requests []fakeWebhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, fakeWebhookRequest{Header: r.Header, Body: body})
+		status := statuses[min(len(requests), len(statuses))-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return server, func() []fakeWebhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fakeWebhookRequest{}, requests...)
+	}
+}
+
+func generateTestWebhookMessenger(cfg *ConfigWebhook) *webhookMessenger {
+	return &webhookMessenger{
+		cfg:     cfg,
+		now:     func() time.Time { return time.Date(2025, 2, 27, 15, 0, 0, 0, time.UTC) },
+		backoff: time.Millisecond,
+	}
+}
+
+func TestWebhookMessenger_Day(t *testing.T) {
+	server, requests := generateFakeWebhook(http.StatusNoContent)
+	defer server.Close()
+	cfg := generateTestConfig()
+	prices, _ := generateStub()
+	message, err := BuildDayMessage(&cfg.Analytics, cfg.MessageTemplates(""), prices, time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location()))
+	require.NoError(t, err)
+
+	messenger := generateTestWebhookMessenger(&ConfigWebhook{URLs: []string{server.URL}, Secret: "secret"})
+	require.NoError(t, messenger.Send(message))
+
+	require.Len(t, requests(), 1)
+	request := requests()[0]
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Len(t, request.Header.Get("X-Delivery-ID"), 32)
+	assert.Equal(t, "1740668400", request.Header.Get("X-Signature-Timestamp"))
+	assert.NoError(t, verifyWebhookSignature(
+		"secret", request.Header.Get("X-Signature-Timestamp"), request.Header.Get("X-Signature"), request.Body,
+		time.Date(2025, 2, 27, 15, 1, 0, 0, time.UTC), 5*time.Minute,
+	))
+
+	var payload models.WebhookPayload
+	require.NoError(t, json.Unmarshal(request.Body, &payload))
+	assert.Equal(t, 1, payload.Version)
+	assert.Equal(t, "prices", payload.Class)
+	assert.Equal(t, "NL", payload.Zone)
+	assert.Equal(t, "2025-02-28", payload.Date)
+	require.Len(t, payload.Series, 24)
+	assert.Equal(t, "-0.02", payload.Series[14].Price.String())
+	assert.Equal(t, "low", payload.Series[14].Tier)
+	assert.Equal(t, "0.18", payload.Stats.Max.String())
+	assert.Equal(t, 19, payload.Stats.MaxHour)
+	assert.Len(t, payload.Tiers, 3)
+	assert.Equal(t, []string{"low", "negative"}, payload.Alerts)
+	assert.Empty(t, payload.Text)
+}
+
+func TestWebhookMessenger_CloudEvents(t *testing.T) {
+	server, requests := generateFakeWebhook(http.StatusOK)
+	defer server.Close()
+
+	messenger := generateTestWebhookMessenger(&ConfigWebhook{URLs: []string{server.URL}, CloudEvents: true})
+	require.NoError(t, messenger.Send(Message{Class: MessageClassNoPrices, Document: TextDocument("No prices for 2025-02-28")}))
+
+	require.Len(t, requests(), 1)
+	request := requests()[0]
+	assert.Equal(t, "application/cloudevents+json", request.Header.Get("Content-Type"))
+	assert.Empty(t, request.Header.Get("X-Signature"))
+	var event models.CloudEvent
+	require.NoError(t, json.Unmarshal(request.Body, &event))
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.Equal(t, request.Header.Get("X-Delivery-ID"), event.ID)
+	assert.Equal(t, "day-ahead-prices.no-prices", event.Type)
+	assert.Equal(t, "2025-02-27T15:00:00Z", event.Time)
+	assert.Equal(t, "No prices for 2025-02-28", event.Data.Text)
+}
+
+func TestWebhookMessenger_Retries(t *testing.T) {
+	server, requests := generateFakeWebhook(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	defer server.Close()
+
+	messenger := generateTestWebhookMessenger(&ConfigWebhook{URLs: []string{server.URL}, Retries: 3})
+	require.NoError(t, messenger.Send(Message{Document: TextDocument("test")}))
+
+	// The delivery ID stays the same for the receiver to deduplicate.
+	require.Len(t, requests(), 3)
+	assert.Equal(t, requests()[0].Header.Get("X-Delivery-ID"), requests()[2].Header.Get("X-Delivery-ID"))
+}
+
+func TestWebhookMessenger_Rejected(t *testing.T) {
+	failing, failingRequests := generateFakeWebhook(http.StatusInternalServerError)
+	defer failing.Close()
+	rejecting, rejectingRequests := generateFakeWebhook(http.StatusBadRequest)
+	defer rejecting.Close()
+	ok, okRequests := generateFakeWebhook(http.StatusOK)
+	defer ok.Close()
+
+	messenger := generateTestWebhookMessenger(&ConfigWebhook{URLs: []string{failing.URL, rejecting.URL, ok.URL}, Retries: 2})
+	err := messenger.Send(Message{Document: TextDocument("test")})
+	assert.True(t, errors.Is(err, ErrWebhookRejected))
+
+	assert.Len(t, failingRequests(), 3)
+	assert.Len(t, rejectingRequests(), 1)
+	assert.Len(t, okRequests(), 1)
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1740668400, 0)
+	body := []byte(`{"version":1}`)
+	signature := webhookSignature("secret", "1740668400", body)
+
+	assert.NoError(t, verifyWebhookSignature("secret", "1740668400", signature, body, now, time.Minute))
+	assert.Error(t, verifyWebhookSignature("other", "1740668400", signature, body, now, time.Minute))
+	assert.Error(t, verifyWebhookSignature("secret", "1740668400", signature, []byte(`{}`), now, time.Minute))
+	assert.Error(t, verifyWebhookSignature("secret", "1740668400", signature, body, now.Add(2*time.Minute), time.Minute))
+	assert.Error(t, verifyWebhookSignature("secret", "yesterday", signature, body, now, time.Minute))
+}
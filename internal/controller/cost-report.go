@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+)
+
+const maxUploadSize = 10 << 20
+
+// CostReportHandler calculates the cost of an uploaded consumption CSV.
+// The file is sent as the "file" field of a multipart form or as the raw request body,
+// the column mapping from the config can be overridden by query parameters.
+func CostReportHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := r.Context().Value("config").(*app.ConfigApp)
+
+	csvCfg := cfg.Consumption
+	query := r.URL.Query()
+	if v := query.Get("time_column"); v != "" {
+		csvCfg.TimeColumn = v
+	}
+	if v := query.Get("value_column"); v != "" {
+		csvCfg.ValueColumn = v
+	}
+	if v := query.Get("time_layout"); v != "" {
+		csvCfg.TimeLayout = v
+	}
+	if v := query.Get("delimiter"); v != "" {
+		if len([]rune(v)) != 1 {
+			http.Error(w, "Invalid delimiter value", http.StatusBadRequest)
+			return
+		}
+		csvCfg.Delimiter = v
+	}
+	for name, value := range map[string]*bool{"decimal_comma": &csvCfg.DecimalComma, "cumulative": &csvCfg.Cumulative} {
+		if v := query.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" value", http.StatusBadRequest)
+				return
+			}
+			*value = b
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Invalid file value", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	consumption, err := app.ParseConsumptionCSV(&csvCfg, body, cfg.Location())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := app.CalculateCost(cfg, consumption)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if query.Get("format") == "html" {
+		html, err := app.ChartCostHtml(&cfg.Analytics, &report)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(html)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConsumptionCSV = "timestamp;usage\n2025-02-28 00:00;1\n2025-02-28 14:00;2\n"
+
+func TestCostReportHandler(t *testing.T) {
+	req, err := http.NewRequest("POST", "/cost-report?delimiter=%3B", strings.NewReader(testConsumptionCSV))
+	require.NoError(t, err)
+
+	rr := serveCostReport(req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	report := models.CostReport{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Len(t, report.Days, 1)
+	assert.Equal(t, "3", report.Total.Usage.String())
+}
+
+func TestCostReportHandler_Multipart(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "usage.csv")
+	require.NoError(t, err)
+	_, _ = part.Write([]byte(testConsumptionCSV))
+	require.NoError(t, form.Close())
+	req, err := http.NewRequest("POST", "/cost-report?delimiter=%3B&format=html", &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	rr := serveCostReport(req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "2025-02-28")
+}
+
+func TestCostReportHandler_Error(t *testing.T) {
+	req, err := http.NewRequest("POST", "/cost-report", strings.NewReader(testConsumptionCSV))
+	require.NoError(t, err)
+
+	rr := serveCostReport(req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func serveCostReport(req *http.Request) *httptest.ResponseRecorder {
+	cfg := &app.ConfigApp{
+		Loader:  app.ConfigLoader{Driver: "stub"},
+		Storage: app.ConfigStorage{Driver: "memory"},
+		Consumption: app.ConfigConsumption{
+			Delimiter:   ",",
+			TimeColumn:  "timestamp",
+			ValueColumn: "usage",
+			TimeLayout:  "2006-01-02 15:04",
+		},
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(CostReportHandler)
+	handler.ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), "config", cfg)))
+	return rr
+}
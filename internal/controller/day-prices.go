@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/shopspring/decimal"
 	"net/http"
 	"time"
 )
@@ -18,19 +21,40 @@ func DayPricesHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Day is in the future after tomorrow", http.StatusNotFound)
 		return
 	} else if day.Equal(tomorrow) && time.Now().In(cfg.Location()).Hour() < cfg.TomorrowHourMin() {
-		http.Error(w, "Day is tomorrow but it's too early", http.StatusNotFound)
-		return
+		_, ok, err := cfg.History().Load(day)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			if !cfg.Forecast.Enabled {
+				http.Error(w, "Day is tomorrow but it's too early", http.StatusNotFound)
+				return
+			}
+			forecastHandler(w, r, cfg, day)
+			return
+		}
 	}
 
 	// Fetch prices
-	prices, err := app.FetchPrices(&cfg.Loader, day)
+	prices, err := app.LoadPrices(cfg, day)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(app.DayPricesPayload(&cfg.Analytics, day, prices, nil, nil))
+		return
+	case "png", "svg":
+		chartImageHandler(w, r, cfg, prices, day)
+		return
+	}
+
 	// Draw and send the chart
-	_, err = app.ChartText(&cfg.Analytics, prices, day)
+	_, err = app.ChartText(&cfg.Analytics, cfg.MessageTemplates("").Locale(), prices, day)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -50,3 +74,42 @@ func DayPricesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(html)
 }
+
+// forecastHandler shows the estimate until the real prices are loaded.
+func forecastHandler(w http.ResponseWriter, r *http.Request, cfg *app.ConfigApp, day time.Time) {
+	forecast, err := app.ForecastPrices(&cfg.Forecast, cfg.History(), day)
+	if errors.Is(err, app.ErrNotEnoughHistory) {
+		http.Error(w, "Day is tomorrow but it's too early", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(app.DayPricesPayload(&cfg.Analytics, day, forecast.Prices, forecast.Lower, forecast.Upper))
+		return
+	}
+
+	html, err := app.ChartForecastHtml(&cfg.Analytics, &forecast)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(html)
+}
+
+func chartImageHandler(w http.ResponseWriter, r *http.Request, cfg *app.ConfigApp, prices []decimal.Decimal, day time.Time) {
+	render, contentType := app.ChartPNG, "image/png"
+	if r.URL.Query().Get("format") == "svg" {
+		render, contentType = app.ChartSVG, "image/svg+xml"
+	}
+	image, err := render(&cfg.Analytics, prices, day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(image)
+}
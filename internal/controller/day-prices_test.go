@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDayPricesHandler(t *testing.T) {
+	for format, contentType := range map[string]string{"png": "image/png", "svg": "image/svg+xml", "json": "application/json"} {
+		rr := serveDayPrices(t, "/day-prices/2025-02-28?format="+format, "2025-02-28")
+
+		assert.Equal(t, http.StatusOK, rr.Code, format)
+		assert.Equal(t, contentType, rr.Header().Get("Content-Type"), format)
+	}
+
+	rr := serveDayPrices(t, "/day-prices/2025-02-28?format=json", "2025-02-28")
+	payload := models.DayPrices{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &payload))
+	assert.Len(t, payload.Slots, 24)
+	assert.Equal(t, "high", payload.Slots[19].Tier)
+}
+
+func TestDayPricesHandler_Future(t *testing.T) {
+	rr := serveDayPrices(t, "/day-prices/2125-02-28", "2125-02-28")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func serveDayPrices(t *testing.T, url string, date string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	cfg := &app.ConfigApp{
+		Analytics: app.ConfigAnalytics{HighPrice: decimal.NewFromFloat(0.18), LowPrice: decimal.NewFromFloat(0.05)},
+		Loader:    app.ConfigLoader{Driver: "stub"},
+		Storage:   app.ConfigStorage{Driver: "memory"},
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", date, cfg.Location())
+	require.NoError(t, err)
+
+	ctx := context.WithValue(req.Context(), "config", cfg)
+	ctx = context.WithValue(ctx, "day", day)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(DayPricesHandler).ServeHTTP(rr, req.WithContext(ctx))
+	return rr
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"net/http"
+	"time"
+)
+
+// OutboxHandler lists the dead letters of the outbox, or the pending items with ?status=pending.
+func OutboxHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := r.Context().Value("config").(*app.ConfigApp)
+
+	var entries []models.OutboxEntry
+	var err error
+	if r.URL.Query().Get("status") == "pending" {
+		entries, err = cfg.Queue().Pending()
+	} else {
+		entries, err = cfg.Queue().DeadLetters()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// OutboxRetryHandler moves the dead letter back to the queue.
+func OutboxRetryHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := r.Context().Value("config").(*app.ConfigApp)
+
+	ok, err := cfg.Queue().Retry(chi.URLParam(r, "id"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	appMiddleware "github.com/oitimon/day-ahead-prices-notificator/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxHandler(t *testing.T) {
+	cfg := &app.ConfigApp{
+		Server:    app.ConfigServer{AdminToken: "secret"},
+		Messenger: app.ConfigMessenger{Driver: "webhook", Webhook: app.ConfigWebhook{URLs: []string{"http://127.0.0.1:1"}}},
+		Storage:   app.ConfigStorage{Driver: "memory"},
+		Outbox:    app.ConfigOutbox{Enabled: true, Attempts: 1, Backoff: time.Second, MaxBackoff: time.Second},
+	}
+	_, err := cfg.Queue().Enqueue("error-2025-03-01", app.Message{Class: app.MessageClassError, Document: app.TextDocument("No prices")}, time.Now())
+	require.NoError(t, err)
+
+	rr := serveOutbox(t, cfg, "GET", "/admin/outbox?status=pending", "secret")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"id":"error-2025-03-01.main"`)
+
+	cfg.Queue().Process(time.Now())
+	rr = serveOutbox(t, cfg, "GET", "/admin/outbox", "secret")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"title":"No prices"`)
+	assert.Contains(t, rr.Body.String(), `"attempts":1`)
+
+	rr = serveOutbox(t, cfg, "POST", "/admin/outbox/error-2025-03-01.main/retry", "secret")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = serveOutbox(t, cfg, "POST", "/admin/outbox/error-2025-03-01.main/retry", "secret")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveOutbox(t, cfg, "GET", "/admin/outbox", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	cfg.Server.AdminToken = ""
+	rr = serveOutbox(t, cfg, "GET", "/admin/outbox", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func serveOutbox(t *testing.T, cfg *app.ConfigApp, method, url, token string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	r := chi.NewRouter()
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(appMiddleware.AdminMiddleware(cfg.Server.AdminToken))
+		r.Get("/outbox", OutboxHandler)
+		r.Post("/outbox/{id}/retry", OutboxRetryHandler)
+	})
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), "config", cfg)))
+	return rr
+}
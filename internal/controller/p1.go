@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"net/http"
+)
+
+func P1Handler(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := r.Context().Value("p1").(*app.P1Tracker)
+	if !ok || tracker == nil {
+		http.Error(w, "P1 reader is not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tracker.Status())
+}
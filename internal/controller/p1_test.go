@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP1Handler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/p1", nil)
+	require.NoError(t, err)
+	tracker := app.NewP1Tracker(&app.ConfigApp{
+		Loader:  app.ConfigLoader{Driver: "stub"},
+		Storage: app.ConfigStorage{Driver: "memory"},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(P1Handler).ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), "p1", tracker)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"month"`)
+}
+
+func TestP1Handler_NotConfigured(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/p1", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(P1Handler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
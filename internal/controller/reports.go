@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"net/http"
+	"time"
+)
+
+// ReportsHandler shows the week or month report of the stored prices,
+// the period contains the "date" query parameter or yesterday by default.
+func ReportsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := r.Context().Value("config").(*app.ConfigApp)
+
+	day := time.Now().In(cfg.Location()).AddDate(0, 0, -1)
+	if v := r.URL.Query().Get("date"); v != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", v, cfg.Location()); err != nil {
+			http.Error(w, "Invalid date value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := app.BuildPeriodReport(cfg.History(), chi.URLParam(r, "period"), day)
+	if errors.Is(err, app.ErrUnknownReportPeriod) || errors.Is(err, app.ErrNotEnoughHistory) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	html, err := app.ChartPeriodHtml(&cfg.Analytics, &report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(html)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportsHandler(t *testing.T) {
+	cfg := &app.ConfigApp{Storage: app.ConfigStorage{Driver: "memory"}}
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, cfg.Location())
+	require.NoError(t, cfg.History().Save(day, []decimal.Decimal{decimal.NewFromFloat(0.1), decimal.NewFromFloat(-0.1)}))
+
+	rr := serveReports(t, cfg, "/reports/week?date=2025-02-28&format=json")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"negativeHours":1`)
+
+	rr = serveReports(t, cfg, "/reports/month?date=2025-02-01")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "EPEX NL month 2025-02-01")
+
+	rr = serveReports(t, cfg, "/reports/year?date=2025-02-28")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveReports(t, cfg, "/reports/week?date=tomorrow")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func serveReports(t *testing.T, cfg *app.ConfigApp, url string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Get("/reports/{period}", ReportsHandler)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), "config", cfg)))
+	return rr
+}
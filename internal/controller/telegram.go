@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"log"
+	"net/http"
+)
+
+// TelegramWebhookHandler queues the update for the bot workers and answers at once,
+// Telegram repeats the update when the answer isn't 200.
+func TelegramWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	bot, ok := r.Context().Value("bot").(*app.Bot)
+	if !ok || bot == nil {
+		http.Error(w, "Telegram webhook is not configured", http.StatusNotFound)
+		return
+	}
+	if !bot.WebhookSecret(chi.URLParam(r, "secret")) {
+		http.NotFound(w, r)
+		return
+	}
+	if !bot.WebhookSecret(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")) {
+		http.Error(w, "Invalid secret token", http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid update", http.StatusBadRequest)
+		return
+	}
+	if err := bot.Enqueue(update); err != nil {
+		log.Printf("Telegram update %d is refused: %v\n", update.UpdateID, err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
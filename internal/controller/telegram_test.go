@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	appMiddleware "github.com/oitimon/day-ahead-prices-notificator/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelegramWebhookHandler(t *testing.T) {
+	telegram := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}}`))
+	}))
+	defer telegram.Close()
+	bot, err := app.NewBot(&app.ConfigApp{
+		Messenger: app.ConfigMessenger{Telegram: app.ConfigTelegram{
+			Token:         "token",
+			APIEndpoint:   telegram.URL + "/bot%s/%s",
+			Updates:       "webhook",
+			WebhookSecret: "secret",
+			Workers:       1,
+		}},
+		Storage: app.ConfigStorage{Driver: "memory"},
+	})
+	require.NoError(t, err)
+
+	update := `{"update_id":1,"message":{"message_id":1,"date":0,"chat":{"id":42,"type":"private"},"text":"/now"}}`
+	rr := serveWebhook(bot, "/telegram/webhook/secret", "secret", update)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = serveWebhook(bot, "/telegram/webhook/other", "secret", update)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = serveWebhook(bot, "/telegram/webhook/secret", "", update)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = serveWebhook(bot, "/telegram/webhook/secret", "secret", "{")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Nobody handles the queue, so it gets full.
+	for rr.Code != http.StatusServiceUnavailable {
+		rr = serveWebhook(bot, "/telegram/webhook/secret", "secret", update)
+		require.Contains(t, []int{http.StatusOK, http.StatusServiceUnavailable}, rr.Code)
+	}
+}
+
+func TestTelegramWebhookHandler_NotConfigured(t *testing.T) {
+	rr := serveWebhook(nil, "/telegram/webhook/secret", "secret", "{}")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func serveWebhook(bot *app.Bot, path string, secret string, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.With(appMiddleware.BotMiddleware(bot)).Post("/telegram/webhook/{secret}", TelegramWebhookHandler)
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if secret != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
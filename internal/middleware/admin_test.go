@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveAdmin(token, authorization string) *httptest.ResponseRecorder {
+	handler := AdminMiddleware(token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest("GET", "/admin/outbox", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminMiddleware_NotConfigured(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, serveAdmin("", "Bearer ").Code)
+	assert.Equal(t, http.StatusNotFound, serveAdmin("", "").Code)
+}
+
+func TestAdminMiddleware_MissingToken(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin("secret", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin("secret", "secret").Code)
+}
+
+func TestAdminMiddleware_WrongToken(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin("secret", "Bearer wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin("secret", "Bearer secret2").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin("secret", "Basic secret").Code)
+}
+
+func TestAdminMiddleware_ValidToken(t *testing.T) {
+	assert.Equal(t, http.StatusNoContent, serveAdmin("secret", "Bearer secret").Code)
+}
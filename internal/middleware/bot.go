@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"context"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"net/http"
+)
+
+// Middleware to add Telegram bot to context
+func BotMiddleware(bot *app.Bot) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "bot", bot)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotMiddleware(t *testing.T) {
+	bot := &app.Bot{}
+	var got *app.Bot
+	handler := BotMiddleware(bot)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value("bot").(*app.Bot)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/telegram/webhook/secret", nil))
+	assert.Same(t, bot, got)
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"context"
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"net/http"
+)
+
+// Middleware to add P1 tracker to context
+func P1Middleware(tracker *app.P1Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "p1", tracker)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oitimon/day-ahead-prices-notificator/internal/app"
+	"github.com/oitimon/day-ahead-prices-notificator/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP1Middleware(t *testing.T) {
+	tracker := app.NewP1Tracker(&app.ConfigApp{}, func(time.Time, models.P1Status) {})
+	var got *app.P1Tracker
+	handler := P1Middleware(tracker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value("p1").(*app.P1Tracker)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/p1", nil))
+	assert.Same(t, tracker, got)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ConsumptionEntry is a single metered interval of a consumption profile.
+type ConsumptionEntry struct {
+	Start time.Time       `json:"start"`
+	Usage decimal.Decimal `json:"usage"`
+}
+
+type CostReportDay struct {
+	Date        string          `json:"date"`
+	Usage       decimal.Decimal `json:"usage"`
+	DynamicCost decimal.Decimal `json:"dynamicCost"`
+	FixedCost   decimal.Decimal `json:"fixedCost"`
+	Difference  decimal.Decimal `json:"difference"`
+}
+
+// CostReport struct to return the cost of a consumption profile
+// under dynamic and fixed tariffs.
+type CostReport struct {
+	Days  []CostReportDay `json:"days"`
+	Total CostReportDay   `json:"total"`
+}
+
+// Add accumulates the day values into the report total.
+func (report *CostReport) Add(day CostReportDay) {
+	report.Days = append(report.Days, day)
+	report.Total.Usage = report.Total.Usage.Add(day.Usage)
+	report.Total.DynamicCost = report.Total.DynamicCost.Add(day.DynamicCost)
+	report.Total.FixedCost = report.Total.FixedCost.Add(day.FixedCost)
+	report.Total.Difference = report.Total.Difference.Add(day.Difference)
+}
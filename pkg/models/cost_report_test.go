@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostReportAdd(t *testing.T) {
+	report := &CostReport{}
+	report.Add(CostReportDay{
+		Date:        "2025-02-27",
+		Usage:       decimal.NewFromFloat(10),
+		DynamicCost: decimal.NewFromFloat(2.5),
+		FixedCost:   decimal.NewFromFloat(3),
+		Difference:  decimal.NewFromFloat(-0.5),
+	})
+	report.Add(CostReportDay{
+		Date:        "2025-02-28",
+		Usage:       decimal.NewFromFloat(5),
+		DynamicCost: decimal.NewFromFloat(2),
+		FixedCost:   decimal.NewFromFloat(1.5),
+		Difference:  decimal.NewFromFloat(0.5),
+	})
+
+	assert.Len(t, report.Days, 2)
+	assert.Equal(t, "15", report.Total.Usage.String())
+	assert.Equal(t, "4.5", report.Total.DynamicCost.String())
+	assert.Equal(t, "4.5", report.Total.FixedCost.String())
+	assert.Equal(t, "0", report.Total.Difference.String())
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceTier is a named price class with its boundary, nil for the normal tier.
+type PriceTier struct {
+	Name     string           `json:"name"`
+	Boundary *decimal.Decimal `json:"boundary"`
+	Color    string           `json:"color"`
+	Emoji    string           `json:"emoji"`
+	Label    string           `json:"label"`
+}
+
+type PriceSlot struct {
+	Start time.Time        `json:"start"`
+	Price decimal.Decimal  `json:"price"`
+	Lower *decimal.Decimal `json:"lower,omitempty"`
+	Upper *decimal.Decimal `json:"upper,omitempty"`
+	Tier  string           `json:"tier"`
+}
+
+// DayPrices struct to return the classified day prices, estimates come from the forecast.
+type DayPrices struct {
+	Date     string      `json:"date"`
+	Estimate bool        `json:"estimate"`
+	Slots    []PriceSlot `json:"slots"`
+	Tiers    []PriceTier `json:"tiers"`
+}
+
+// DayStats are the lowest, highest and average prices with the hours of the first lowest and highest.
+type DayStats struct {
+	Min     decimal.Decimal `json:"min"`
+	MinHour int             `json:"minHour"`
+	Max     decimal.Decimal `json:"max"`
+	MaxHour int             `json:"maxHour"`
+	Average decimal.Decimal `json:"average"`
+}
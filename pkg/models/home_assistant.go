@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HADevice groups the entities of the notificator in Home Assistant.
+type HADevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// HADiscoveryConfig struct is the Home Assistant MQTT discovery config of a sensor or a binary sensor.
+type HADiscoveryConfig struct {
+	Name                      string   `json:"name"`
+	UniqueID                  string   `json:"unique_id"`
+	ObjectID                  string   `json:"object_id"`
+	StateTopic                string   `json:"state_topic"`
+	ValueTemplate             string   `json:"value_template,omitempty"`
+	JSONAttributesTopic       string   `json:"json_attributes_topic,omitempty"`
+	UnitOfMeasurement         string   `json:"unit_of_measurement,omitempty"`
+	StateClass                string   `json:"state_class,omitempty"`
+	SuggestedDisplayPrecision int      `json:"suggested_display_precision,omitempty"`
+	Icon                      string   `json:"icon,omitempty"`
+	Device                    HADevice `json:"device"`
+}
+
+// CheapWindow is the cheapest run of hours of the day, active when the current hour is in it.
+type CheapWindow struct {
+	Hours   int             `json:"hours"`
+	Active  bool            `json:"active"`
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Average decimal.Decimal `json:"average"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OutboxEntry describes a queued or dead-lettered notification for a destination.
+type OutboxEntry struct {
+	ID          string    `json:"id"`
+	Destination string    `json:"destination"`
+	ChatID      int64     `json:"chatId,omitempty"`
+	Class       string    `json:"class"`
+	Title       string    `json:"title"`
+	Attempts    int       `json:"attempts"`
+	Created     time.Time `json:"created"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// P1Usage is the imported and exported energy (kWh) of a period and its cost.
+type P1Usage struct {
+	Import decimal.Decimal `json:"import"`
+	Export decimal.Decimal `json:"export"`
+	Cost   decimal.Decimal `json:"cost"`
+}
+
+func (usage P1Usage) Add(other P1Usage) P1Usage {
+	return P1Usage{
+		Import: usage.Import.Add(other.Import),
+		Export: usage.Export.Add(other.Export),
+		Cost:   usage.Cost.Add(other.Cost),
+	}
+}
+
+// P1Status struct to return the live meter usage, slots are the hours of the current day.
+type P1Status struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Price     decimal.Decimal `json:"price"`
+	Slots     []P1Usage       `json:"slots"`
+	Day       P1Usage         `json:"day"`
+	Month     P1Usage         `json:"month"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP1UsageAdd(t *testing.T) {
+	usage := P1Usage{Import: decimal.NewFromFloat(1.5), Export: decimal.NewFromFloat(0.5), Cost: decimal.NewFromFloat(0.2)}
+
+	res := usage.Add(P1Usage{Import: decimal.NewFromFloat(1), Cost: decimal.NewFromFloat(-0.1)})
+
+	assert.Equal(t, "2.5", res.Import.String())
+	assert.Equal(t, "0.5", res.Export.String())
+	assert.Equal(t, "0.1", res.Cost.String())
+	assert.Equal(t, "1.5", usage.Import.String())
+}
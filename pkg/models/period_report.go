@@ -0,0 +1,30 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type DayAverage struct {
+	Date    string          `json:"date"`
+	Average decimal.Decimal `json:"average"`
+}
+
+// PeriodStats struct to return the price statistics of a week or a month.
+type PeriodStats struct {
+	From             string            `json:"from"`
+	Till             string            `json:"till"`
+	Days             int               `json:"days"`
+	BaseAverage      decimal.Decimal   `json:"baseAverage"`
+	PeakAverage      decimal.Decimal   `json:"peakAverage"`
+	CheapestDay      DayAverage        `json:"cheapestDay"`
+	MostExpensiveDay DayAverage        `json:"mostExpensiveDay"`
+	HourAverages     []decimal.Decimal `json:"hourAverages"`
+	NegativeHours    int               `json:"negativeHours"`
+}
+
+// PeriodReport compares the period with the previous one, which is nil when it isn't stored.
+type PeriodReport struct {
+	Period     string           `json:"period"`
+	Current    PeriodStats      `json:"current"`
+	Previous   *PeriodStats     `json:"previous"`
+	BaseChange *decimal.Decimal `json:"baseChange"`
+	PeakChange *decimal.Decimal `json:"peakChange"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// WebhookPayloadVersion is increased on any incompatible change of WebhookPayload.
+const WebhookPayloadVersion = 1
+
+// WebhookPayload struct to post the notifications to the webhooks,
+// the day fields are set for the day prices, the text for the other messages.
+type WebhookPayload struct {
+	Version int         `json:"version"`
+	Class   string      `json:"class"`
+	Zone    string      `json:"zone"`
+	Date    string      `json:"date,omitempty"`
+	Series  []PriceSlot `json:"series,omitempty"`
+	Stats   *DayStats   `json:"stats,omitempty"`
+	Tiers   []PriceTier `json:"tiers,omitempty"`
+	Alerts  []string    `json:"alerts,omitempty"`
+	Text    string      `json:"text,omitempty"`
+}
+
+// CloudEvent struct is the structured CloudEvents 1.0 envelope.
+type CloudEvent struct {
+	SpecVersion     string         `json:"specversion"`
+	ID              string         `json:"id"`
+	Source          string         `json:"source"`
+	Type            string         `json:"type"`
+	Time            string         `json:"time"`
+	DataContentType string         `json:"datacontenttype"`
+	Data            WebhookPayload `json:"data"`
+}